@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
-	"path/filepath"
 	"syscall"
 
 	"github.com/LdDl/esia-potato/cryptopro"
@@ -88,32 +87,51 @@ func main() {
 
 	// Save to file if requested
 	if output != "" {
-		outFile := output + "_primary.bin"
-		if err := os.WriteFile(outFile, keyData.PrivateKey, 0600); err != nil {
+		if err := saveKey(keyData, output+"_primary"); err != nil {
 			slog.Error("failed to save key", "error", err)
 			os.Exit(1)
 		}
-		slog.Info("key saved", "file", outFile)
-
-		// Also save hex version
-		hexFile := output + "_primary.hex"
-		hexData := []byte(hex.EncodeToString(keyData.PrivateKey))
-		if err := os.WriteFile(hexFile, hexData, 0600); err != nil {
-			slog.Error("failed to save hex", "error", err)
-			os.Exit(1)
-		}
-		slog.Info("hex saved", "file", hexFile)
 	}
 
 	// Try secondary key
-	masks2Path := filepath.Join(containerPath, "masks2.key")
-	primary2Path := filepath.Join(containerPath, "primary2.key")
+	if container.HasSecondaryKey() {
+		secondaryData, err := container.ExtractSecondaryKey(password)
+		if err != nil {
+			slog.Error("failed to extract secondary key", "error", err)
+			os.Exit(1)
+		}
 
-	if _, err := os.Stat(masks2Path); err == nil {
-		if _, err := os.Stat(primary2Path); err == nil {
-			slog.Warn("secondary key found but not extracted", "masks", "masks2.key", "primary", "primary2.key")
+		slog.Info("secondary key extracted",
+			"curve_oid", secondaryData.CurveOID,
+			"fingerprint", hex.EncodeToString(secondaryData.Fingerprint),
+			"private_key", hex.EncodeToString(secondaryData.PrivateKey),
+		)
+
+		if output != "" {
+			if err := saveKey(secondaryData, output+"_secondary"); err != nil {
+				slog.Error("failed to save secondary key", "error", err)
+				os.Exit(1)
+			}
 		}
 	}
 
 	slog.Info("done")
 }
+
+// saveKey writes private key as raw bytes (<prefix>.bin) and hex (<prefix>.hex)
+func saveKey(keyData *cryptopro.KeyData, prefix string) error {
+	outFile := prefix + ".bin"
+	if err := os.WriteFile(outFile, keyData.PrivateKey, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outFile, err)
+	}
+	slog.Info("key saved", "file", outFile)
+
+	// Also save hex version
+	hexFile := prefix + ".hex"
+	hexData := []byte(hex.EncodeToString(keyData.PrivateKey))
+	if err := os.WriteFile(hexFile, hexData, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", hexFile, err)
+	}
+	slog.Info("hex saved", "file", hexFile)
+	return nil
+}
@@ -6,23 +6,96 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"time"
 
+	"github.com/LdDl/esia-potato/gostx509"
 	"github.com/LdDl/esia-potato/httpapi"
+	"github.com/LdDl/esia-potato/keystore"
+	"github.com/LdDl/esia-potato/ocsp"
 )
 
+// Environment variable with passphrase for file key store
+const passphraseEnv = "KEYSTORE_PASSPHRASE"
+
 func main() {
 	var host string
 	var port int
 	flag.StringVar(&host, "host", "0.0.0.0", "HTTP server host")
 	flag.IntVar(&port, "port", 8080, "HTTP server port")
+	var storeType, storeDir string
+	flag.StringVar(&storeType, "keystore", "memory", "Key store backend: memory, file or none")
+	flag.StringVar(&storeDir, "keystore-dir", "keys", "Directory for file key store (passphrase is read from "+passphraseEnv+")")
+	var trustStoreDir string
+	flag.StringVar(&trustStoreDir, "truststore", "", "Directory with trusted root and intermediate CA certificates (DER or PEM). If set, certificate chain is validated before signing")
+	var crlDir string
+	var crlFetch bool
+	flag.StringVar(&crlDir, "crl-dir", "", "Directory with CRLs (DER or PEM) to check signer certificate chain against (requires -truststore)")
+	flag.BoolVar(&crlFetch, "crl-fetch", false, "Download CRLs from certificate distribution points (requires -truststore)")
+	var ocspEnabled bool
+	var ocspURL string
+	flag.BoolVar(&ocspEnabled, "ocsp", false, "Check signer certificate chain with OCSP responders from certificates, CRLs are used as fallback (requires -truststore)")
+	flag.StringVar(&ocspURL, "ocsp-url", "", "OCSP responder URL overriding URLs from certificates (implies -ocsp)")
 	flag.Parse()
 
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
 	slog.SetDefault(logger)
 
+	store, err := newKeyStore(storeType, storeDir)
+	if err != nil {
+		slog.Error("failed to create key store", "error", err)
+		os.Exit(1)
+	}
+	server := httpapi.NewServer(store)
+	if trustStoreDir != "" {
+		server.TrustStore, err = gostx509.LoadTrustStore(trustStoreDir)
+		if err != nil {
+			slog.Error("failed to load trust store", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("trust store loaded",
+			"dir", trustStoreDir,
+			"roots", server.TrustStore.Roots.Len(),
+			"intermediates", server.TrustStore.Intermediates.Len(),
+		)
+	}
+	var checkers gostx509.RevocationCheckers
+	if ocspEnabled || ocspURL != "" {
+		if server.TrustStore == nil {
+			slog.Error("OCSP checking requires -truststore")
+			os.Exit(1)
+		}
+		client := ocsp.NewClient()
+		client.HTTPClient = &http.Client{Timeout: 30 * time.Second}
+		client.URL = ocspURL
+		checkers = append(checkers, client)
+		slog.Info("OCSP checking enabled", "url", ocspURL)
+	}
+	if crlDir != "" || crlFetch {
+		if server.TrustStore == nil {
+			slog.Error("CRL checking requires -truststore")
+			os.Exit(1)
+		}
+		checker := gostx509.NewCRLChecker(nil)
+		if crlFetch {
+			checker.Fetcher = &gostx509.HTTPCRLFetcher{Client: &http.Client{Timeout: 30 * time.Second}}
+		}
+		if crlDir != "" {
+			if err := checker.LoadDir(crlDir); err != nil {
+				slog.Error("failed to load CRLs", "error", err)
+				os.Exit(1)
+			}
+		}
+		checkers = append(checkers, checker)
+		slog.Info("CRL checking enabled", "dir", crlDir, "fetch", crlFetch)
+	}
+	if len(checkers) > 0 {
+		server.RevocationChecker = checkers
+	}
+
 	mux := http.NewServeMux()
-	mux.HandleFunc("/api/v1/extract", httpapi.HandleExtract)
-	mux.HandleFunc("/api/v1/sign", httpapi.HandleSign)
+	mux.HandleFunc("/api/v1/extract", server.HandleExtract)
+	mux.HandleFunc("/api/v1/sign", server.HandleSign)
+	mux.HandleFunc("/api/v1/keys", server.HandleKeys)
 	mux.HandleFunc("/health", httpapi.HandleHealth)
 	mux.HandleFunc("/docs", httpapi.HandleDocsUI)
 	mux.HandleFunc("/docs/swagger.json", httpapi.HandleDocsJSON)
@@ -34,3 +107,18 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+func newKeyStore(storeType, dir string) (keystore.Store, error) {
+	switch storeType {
+	case "none":
+		return nil, nil
+	case "memory":
+		slog.Info("using in-memory key store")
+		return keystore.NewMemoryStore(), nil
+	case "file":
+		slog.Info("using file key store", "dir", dir)
+		return keystore.NewFileStore(dir, os.Getenv(passphraseEnv))
+	default:
+		return nil, fmt.Errorf("unknown key store type: %s", storeType)
+	}
+}
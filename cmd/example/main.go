@@ -1,29 +1,24 @@
 package main
 
 import (
-	"encoding/base64"
 	"encoding/hex"
 	"log/slog"
 	"net/http"
-	"net/url"
 	"os"
-	"time"
 
 	"github.com/LdDl/esia-potato/cms"
+	"github.com/LdDl/esia-potato/esia"
 	"github.com/ddulesov/gogost/gost3410"
 	"github.com/google/uuid"
 )
 
 const (
-	ESIATest = "https://esia-portal1.test.gosuslugi.ru"
 	certPath = "test_container/certificate.cer"
 
 	clientID    = "775607_DP"
 	redirectURI = "https://ya.ru"
 	scope       = "openid"
 
-	tmLayout = "2006.01.02 15:04:05 -0700"
-
 	// Aquire hex via `cryptopro_extract` CLI first
 	keyHex = "169ccdf3bf9333905778d8a49bc6945f736ecfc50ce10355f4558f4bdd9bfecd"
 )
@@ -59,40 +54,26 @@ func main() {
 		os.Exit(1)
 	}
 
-	// extra oAuth parameters
-	state := uuid.New().String()
-	timestamp := time.Now().UTC().Format(tmLayout)
-
-	// Message to sign: scope + timestamp + clientID + state
-	message := scope + timestamp + clientID + state
-	slog.Info("message prepared", "message", message)
-
-	// Sign
-	cmsDER, err := signer.Sign([]byte(message))
+	// Create ESIA client
+	esiaClient, err := esia.NewClient(esia.Config{
+		ClientID:    clientID,
+		RedirectURI: redirectURI,
+		Scopes:      []string{scope},
+		BaseURL:     esia.TestBaseURL,
+		Signer:      signer,
+	})
 	if err != nil {
-		slog.Error("failed to sign", "error", err)
+		slog.Error("failed to create ESIA client", "error", err)
 		os.Exit(1)
 	}
 
-	// URL-safe Base64
-	clientSecret := base64.URLEncoding.EncodeToString(cmsDER)
-	slog.Info("signature created",
-		"signature_bytes", len(cmsDER),
-		"base64_chars", len(clientSecret),
-	)
-
-	// prepare authorization URL
-	params := url.Values{}
-	params.Set("client_id", clientID)
-	params.Set("client_secret", clientSecret)
-	params.Set("redirect_uri", redirectURI)
-	params.Set("scope", scope)
-	params.Set("response_type", "code")
-	params.Set("state", state)
-	params.Set("timestamp", timestamp)
-	params.Set("access_type", "offline")
-
-	authURL := ESIATest + "/aas/oauth2/ac?" + params.Encode()
+	// Signed message: scope + timestamp + clientID + state
+	state := uuid.New().String()
+	authURL, err := esiaClient.AuthCodeURL(state)
+	if err != nil {
+		slog.Error("failed to prepare authorization URL", "error", err)
+		os.Exit(1)
+	}
 	slog.Info("authorization URL prepared", "url", authURL)
 
 	// prepare and execute request
@@ -116,7 +97,7 @@ func main() {
 		"location", loc,
 	)
 
-	if loc == "/login" || loc == ESIATest+"/login" {
+	if loc == "/login" || loc == esia.TestBaseURL+"/login" {
 		slog.Info("signature accepted by ESIA")
 	}
 }
@@ -4,18 +4,17 @@ import (
 	"archive/tar"
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/base64"
-	"encoding/json"
-	"fmt"
-	"io"
 	"log/slog"
-	"mime/multipart"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/LdDl/esia-potato/httpapi"
+	"github.com/LdDl/esia-potato/httpapi/apiclient"
 	"github.com/google/uuid"
 )
 
@@ -38,39 +37,24 @@ const (
 	tmLayout = "2006.01.02 15:04:05 -0700"
 )
 
-// ExtractResponse matches /api/v1/extract response
-type ExtractResponse struct {
-	PrivateKeyHex     string `json:"private_key_hex"`
-	PublicKeyHex      string `json:"public_key_hex"`
-	Fingerprint       string `json:"fingerprint"`
-	CurveOID          string `json:"curve_oid"`
-	CertificateBase64 string `json:"certificate_base64"`
-}
-
-// SignRequest matches /api/v1/sign request
-type SignRequest struct {
-	PrivateKeyHex  string `json:"private_key_hex"`
-	CertificateB64 string `json:"certificate_base64"`
-	Message        string `json:"message"`
-}
-
-// SignResponse matches /api/v1/sign response
-type SignResponse struct {
-	SignatureB64 string `json:"signature_base64"`
-}
-
-// ErrorResponse matches error response
-type ErrorResponse struct {
-	Error string `json:"error"`
-}
-
 func main() {
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
 	slog.SetDefault(logger)
 
 	// Step 1: Extract key from container via API
 	slog.Info("extracting key from container via API", "path", containerPath)
-	extractResp, err := extractKey(containerPath, containerPIN)
+	api := apiclient.NewClient(APIServer)
+	ctx := context.Background()
+	tarData, err := createTarGz(containerPath)
+	if err != nil {
+		slog.Error("failed to create tar.gz", "error", err)
+		os.Exit(1)
+	}
+	extractResp, err := api.Extract(ctx, &apiclient.ExtractRequest{
+		Archive:  bytes.NewReader(tarData),
+		Filename: "container.tar.gz",
+		PIN:      containerPIN,
+	})
 	if err != nil {
 		slog.Error("failed to extract key", "error", err)
 		os.Exit(1)
@@ -89,20 +73,20 @@ func main() {
 
 	// Step 3: Sign message via API
 	slog.Info("signing message via API")
-	signResp, err := signMessage(extractResp.PrivateKeyHex, extractResp.CertificateBase64, message)
+	sigBytes, err := api.Sign(ctx, &httpapi.SignRequest{
+		PrivateKeyHex:  extractResp.PrivateKeyHex,
+		CertificateB64: extractResp.CertificateBase64,
+		CurveOID:       extractResp.CurveOID,
+		Message:        message,
+	})
 	if err != nil {
 		slog.Error("failed to sign message", "error", err)
 		os.Exit(1)
 	}
-	slog.Info("message signed", "signature_base64_len", len(signResp.SignatureB64))
+	slog.Info("message signed", "signature_len", len(sigBytes))
 
 	// Step 4: Convert signature to URL-safe base64
-	// API returns standard base64, ESIA needs URL-safe base64
-	sigBytes, err := base64.StdEncoding.DecodeString(signResp.SignatureB64)
-	if err != nil {
-		slog.Error("failed to decode signature", "error", err)
-		os.Exit(1)
-	}
+	// ESIA needs URL-safe base64
 	clientSecret := base64.URLEncoding.EncodeToString(sigBytes)
 
 	// Step 5: Prepare authorization URL
@@ -145,105 +129,6 @@ func main() {
 	}
 }
 
-// extractKey calls /api/v1/extract to extract key from container
-func extractKey(containerPath, pin string) (*ExtractResponse, error) {
-	// Create multipart form
-	var buf bytes.Buffer
-	writer := multipart.NewWriter(&buf)
-
-	// Add PIN field
-	if err := writer.WriteField("pin", pin); err != nil {
-		return nil, fmt.Errorf("failed to write pin field: %w", err)
-	}
-
-	// Create tar.gz of container directory
-	tarData, err := createTarGz(containerPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create tar.gz: %w", err)
-	}
-
-	// Add file field
-	part, err := writer.CreateFormFile("file", "container.tar.gz")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create form file: %w", err)
-	}
-	if _, err := part.Write(tarData); err != nil {
-		return nil, fmt.Errorf("failed to write file data: %w", err)
-	}
-
-	if err := writer.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close writer: %w", err)
-	}
-
-	resp, err := http.Post(
-		APIServer+"/api/v1/extract",
-		writer.FormDataContentType(),
-		&buf,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		var errResp ErrorResponse
-		if err := json.Unmarshal(body, &errResp); err == nil {
-			return nil, fmt.Errorf("API error: %s", errResp.Error)
-		}
-		return nil, fmt.Errorf("unexpected status: %d", resp.StatusCode)
-	}
-
-	var result ExtractResponse
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	return &result, nil
-}
-
-// signMessage calls /api/v1/sign to sign a message
-func signMessage(privateKeyHex, certificateB64, message string) (*SignResponse, error) {
-	reqBody := SignRequest{
-		PrivateKeyHex:  privateKeyHex,
-		CertificateB64: certificateB64,
-		Message:        message,
-	}
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-	resp, err := http.Post(
-		APIServer+"/api/v1/sign",
-		"application/json",
-		bytes.NewReader(jsonData),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
-	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-	if resp.StatusCode != http.StatusOK {
-		var errResp ErrorResponse
-		if err := json.Unmarshal(body, &errResp); err == nil {
-			return nil, fmt.Errorf("API error: %s", errResp.Error)
-		}
-		return nil, fmt.Errorf("unexpected status: %d", resp.StatusCode)
-	}
-	var result SignResponse
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
-	return &result, nil
-}
-
 // createTarGz creates a tar.gz archive from a directory
 func createTarGz(dir string) ([]byte, error) {
 	var buf bytes.Buffer
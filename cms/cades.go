@@ -0,0 +1,153 @@
+package cms
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"hash"
+	"math/big"
+
+	"github.com/LdDl/esia-potato/gostx509"
+	"github.com/pkg/errors"
+)
+
+// Sentinel errors of CAdES attributes
+var (
+	ErrSigningCertificateMissing  = fmt.Errorf("signingCertificateV2 attribute missing")
+	ErrSigningCertificateMismatch = fmt.Errorf("signingCertificateV2 attribute does not match signer certificate")
+)
+
+var (
+	// id-aa-signingCertificateV2 (RFC 5035)
+	OIDAttributeSigningCertificateV2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+	// SHA-256, default hash algorithm of ESSCertIDv2
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+)
+
+// Profile selects set of signed attributes
+type Profile int
+
+const (
+	// ProfileCMS is plain CMS: contentType, signingTime and messageDigest (OpenSSL-compatible)
+	ProfileCMS Profile = iota
+	// ProfileCAdESBES adds ESS signingCertificateV2 with Streebog hash of signer certificate (CAdES-BES)
+	ProfileCAdESBES
+)
+
+func (p Profile) String() string {
+	switch p {
+	case ProfileCMS:
+		return "CMS"
+	case ProfileCAdESBES:
+		return "CAdES-BES"
+	default:
+		return fmt.Sprintf("Profile(%d)", int(p))
+	}
+}
+
+// signingCertificateV2 is ESS SigningCertificateV2
+type signingCertificateV2 struct {
+	Certs    []essCertIDv2
+	Policies asn1.RawValue `asn1:"optional"`
+}
+
+type essCertIDv2 struct {
+	// DEFAULT id-sha256
+	HashAlgorithm pkix.AlgorithmIdentifier `asn1:"optional"`
+	CertHash      []byte
+	IssuerSerial  issuerSerial `asn1:"optional"`
+}
+
+type issuerSerial struct {
+	// GeneralNames with directoryName [4] of issuer
+	Issuer       []asn1.RawValue
+	SerialNumber *big.Int
+}
+
+// Tag of directoryName in GeneralName
+const generalNameDirectory = 4
+
+// signingCertificateV2Attribute builds signingCertificateV2 attribute of cert hashed with alg
+func signingCertificateV2Attribute(cert *gostx509.Certificate, alg algorithm) (Attribute, error) {
+	h := alg.newHash()
+	h.Write(cert.Raw)
+
+	value, err := asn1.Marshal(signingCertificateV2{
+		Certs: []essCertIDv2{{
+			HashAlgorithm: pkix.AlgorithmIdentifier{
+				Algorithm:  alg.digestOID,
+				Parameters: asn1.NullRawValue,
+			},
+			CertHash: h.Sum(nil),
+			IssuerSerial: issuerSerial{
+				Issuer: []asn1.RawValue{{
+					Class:      asn1.ClassContextSpecific,
+					Tag:        generalNameDirectory,
+					IsCompound: true,
+					Bytes:      cert.RawIssuer,
+				}},
+				SerialNumber: cert.SerialNumber,
+			},
+		}},
+	})
+	if err != nil {
+		return Attribute{}, errors.Wrap(err, "failed to marshal signingCertificateV2")
+	}
+	return Attribute{
+		Type: OIDAttributeSigningCertificateV2,
+		Values: asn1.RawValue{
+			Class:      asn1.ClassUniversal,
+			Tag:        asn1.TagSet,
+			IsCompound: true,
+			Bytes:      value,
+		},
+	}, nil
+}
+
+// checkSigningCertificateV2 ensures the first ESSCertIDv2 of attribute value refers to cert
+func checkSigningCertificateV2(value []byte, cert *gostx509.Certificate) error {
+	var attr signingCertificateV2
+	if _, err := asn1.Unmarshal(value, &attr); err != nil {
+		return errors.Wrap(err, "failed to parse signingCertificateV2 attribute")
+	}
+	if len(attr.Certs) == 0 {
+		return errors.Wrap(ErrSigningCertificateMismatch, "no certificate identifiers")
+	}
+	// The first identifier is the signer certificate, others may refer to its chain
+	id := attr.Certs[0]
+
+	var h hash.Hash
+	switch oid := id.HashAlgorithm.Algorithm; {
+	case len(oid) == 0 || oid.Equal(oidSHA256):
+		h = sha256.New()
+	default:
+		alg, err := algorithmForDigestOID(oid)
+		if err != nil {
+			return err
+		}
+		h = alg.newHash()
+	}
+	h.Write(cert.Raw)
+	if !bytes.Equal(h.Sum(nil), id.CertHash) {
+		return errors.Wrap(ErrSigningCertificateMismatch, "certificate hash")
+	}
+
+	if id.IssuerSerial.SerialNumber != nil {
+		if id.IssuerSerial.SerialNumber.Cmp(cert.SerialNumber) != 0 {
+			return errors.Wrap(ErrSigningCertificateMismatch, "serial number")
+		}
+		found := false
+		for _, name := range id.IssuerSerial.Issuer {
+			if name.Class == asn1.ClassContextSpecific && name.Tag == generalNameDirectory && bytes.Equal(name.Bytes, cert.RawIssuer) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errors.Wrap(ErrSigningCertificateMismatch, "issuer")
+		}
+	}
+	return nil
+}
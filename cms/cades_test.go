@@ -0,0 +1,159 @@
+package cms
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/asn1"
+	"testing"
+
+	"github.com/LdDl/esia-potato/gostx509"
+	"github.com/LdDl/esia-potato/internal/gosttest"
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/ddulesov/gogost/gost34112012512"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signedAttributes extracts signed attributes of the first signer
+func signedAttributes(t *testing.T, cmsDER []byte) []Attribute {
+	signedData, err := parseSignedData(cmsDER)
+	require.NoError(t, err)
+	require.Len(t, signedData.SignerInfos, 1)
+
+	attrsDER := append([]byte{}, signedData.SignerInfos[0].SignedAttrs.FullBytes...)
+	attrsDER[0] = 0x31
+	attrs, err := parseAttributes(attrsDER)
+	require.NoError(t, err)
+	return attrs
+}
+
+// go test -timeout 30s -run ^TestSignCAdESBES$ github.com/LdDl/esia-potato/cms
+func TestSignCAdESBES(t *testing.T) {
+	prv := createTestPrivateKey(t)
+	certDER := gosttest.SelfSigned(t, prv, "Test Signer", 7)
+	signer, err := NewSigner(prv, certDER, WithProfile(ProfileCAdESBES))
+	require.NoError(t, err)
+
+	message := []byte("test message")
+	cmsDER, err := signer.Sign(message)
+	require.NoError(t, err)
+
+	attrs := signedAttributes(t, cmsDER)
+	require.Len(t, attrs, 4)
+	assert.True(t, attrs[0].Type.Equal(OIDAttributeContentType))
+	assert.True(t, attrs[1].Type.Equal(OIDAttributeSigningTime))
+	assert.True(t, attrs[2].Type.Equal(OIDAttributeMessageDigest))
+	assert.True(t, attrs[3].Type.Equal(OIDAttributeSigningCertificateV2))
+
+	var attr signingCertificateV2
+	_, err = asn1.Unmarshal(attrs[3].Values.Bytes, &attr)
+	require.NoError(t, err)
+	require.Len(t, attr.Certs, 1)
+	h := gost34112012256.New()
+	h.Write(certDER)
+	assert.True(t, attr.Certs[0].HashAlgorithm.Algorithm.Equal(OIDGostR341112256))
+	assert.Equal(t, h.Sum(nil), attr.Certs[0].CertHash)
+	assert.Equal(t, int64(7), attr.Certs[0].IssuerSerial.SerialNumber.Int64())
+	require.Len(t, attr.Certs[0].IssuerSerial.Issuer, 1)
+	assert.Equal(t, signer.ParsedCertificate().RawIssuer, attr.Certs[0].IssuerSerial.Issuer[0].Bytes)
+
+	result, err := Verify(cmsDER, message, WithProfile(ProfileCAdESBES))
+	require.NoError(t, err)
+	assert.True(t, result.SigningCertificateV2)
+}
+
+// go test -timeout 30s -run ^TestSignCAdESBES512$ github.com/LdDl/esia-potato/cms
+func TestSignCAdESBES512(t *testing.T) {
+	prv := gosttest.PrivateKey512(t)
+
+	certDER := gosttest.SelfSigned(t, prv, "Test Signer 512", 8)
+	signer, err := NewSigner(prv, certDER, WithProfile(ProfileCAdESBES))
+	require.NoError(t, err)
+
+	cmsDER, err := signer.Sign([]byte("test message"))
+	require.NoError(t, err)
+
+	attrs := signedAttributes(t, cmsDER)
+	require.Len(t, attrs, 4)
+	var attr signingCertificateV2
+	_, err = asn1.Unmarshal(attrs[3].Values.Bytes, &attr)
+	require.NoError(t, err)
+	h := gost34112012512.New()
+	h.Write(certDER)
+	assert.True(t, attr.Certs[0].HashAlgorithm.Algorithm.Equal(OIDGostR341112512))
+	assert.Equal(t, h.Sum(nil), attr.Certs[0].CertHash)
+
+	result, err := Verify(cmsDER, []byte("test message"))
+	require.NoError(t, err)
+	assert.True(t, result.SigningCertificateV2)
+}
+
+// go test -timeout 30s -run ^TestPlainProfile$ github.com/LdDl/esia-potato/cms
+func TestPlainProfile(t *testing.T) {
+	signer := createTestSigner(t)
+
+	message := []byte("test message")
+	cmsDER, err := signer.Sign(message)
+	require.NoError(t, err)
+
+	attrs := signedAttributes(t, cmsDER)
+	require.Len(t, attrs, 3)
+	assert.True(t, attrs[0].Type.Equal(OIDAttributeContentType))
+	assert.True(t, attrs[1].Type.Equal(OIDAttributeSigningTime))
+	assert.True(t, attrs[2].Type.Equal(OIDAttributeMessageDigest))
+
+	result, err := Verify(cmsDER, message)
+	require.NoError(t, err)
+	assert.False(t, result.SigningCertificateV2)
+
+	_, err = Verify(cmsDER, message, WithProfile(ProfileCAdESBES))
+	assert.ErrorIs(t, err, ErrSigningCertificateMissing)
+}
+
+// go test -timeout 30s -run ^TestCheckSigningCertificateV2$ github.com/LdDl/esia-potato/cms
+func TestCheckSigningCertificateV2(t *testing.T) {
+	prv := createTestPrivateKey(t)
+	cert, err := gostx509.ParseCertificate(gosttest.SelfSigned(t, prv, "Test Signer", 1))
+	require.NoError(t, err)
+	other, err := gostx509.ParseCertificate(gosttest.SelfSigned(t, prv, "Test Signer", 2))
+	require.NoError(t, err)
+
+	attr, err := signingCertificateV2Attribute(cert, algorithm256)
+	require.NoError(t, err)
+
+	assert.NoError(t, checkSigningCertificateV2(attr.Values.Bytes, cert))
+	assert.ErrorIs(t, checkSigningCertificateV2(attr.Values.Bytes, other), ErrSigningCertificateMismatch)
+
+	// Hash algorithm defaults to SHA-256
+	certHash := sha256.Sum256(cert.Raw)
+	sha256Attr, err := asn1.Marshal(signingCertificateV2{Certs: []essCertIDv2{{CertHash: certHash[:]}}})
+	require.NoError(t, err)
+	assert.NoError(t, checkSigningCertificateV2(sha256Attr, cert))
+}
+
+// fakeTimestamper returns signature value wrapped into OCTET STRING instead of real token
+type fakeTimestamper struct{}
+
+func (fakeTimestamper) Timestamp(_ context.Context, signature []byte) ([]byte, error) {
+	return asn1.Marshal(signature)
+}
+
+// go test -timeout 30s -run ^TestTimestamper$ github.com/LdDl/esia-potato/cms
+func TestTimestamper(t *testing.T) {
+	prv := createTestPrivateKey(t)
+	contentType := asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	signer, err := NewSigner(prv, gosttest.SelfSigned(t, prv, "Test Signer", 1),
+		WithTimestamper(fakeTimestamper{}), WithContentType(contentType))
+	require.NoError(t, err)
+
+	cmsDER, err := signer.SignAttached([]byte("content"))
+	require.NoError(t, err)
+
+	result, err := Verify(cmsDER, nil)
+	require.NoError(t, err)
+	assert.True(t, result.ContentType.Equal(contentType))
+
+	expected, err := asn1.Marshal(result.Signature)
+	require.NoError(t, err)
+	assert.Equal(t, expected, result.TimestampToken)
+}
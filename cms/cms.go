@@ -2,25 +2,35 @@
 package cms
 
 import (
-	"crypto/rand"
+	"bytes"
+	"context"
+	"crypto"
 	"crypto/x509/pkix"
 	"encoding/asn1"
+	"encoding/hex"
 	"fmt"
+	"hash"
+	"io"
 	"math/big"
+	"strings"
 	"time"
 
-	"github.com/LdDl/esia-potato/utils"
+	"github.com/LdDl/esia-potato/gostx509"
 	"github.com/ddulesov/gogost/gost3410"
 	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/ddulesov/gogost/gost34112012512"
 	"github.com/pkg/errors"
 )
 
 // Sentinel errors
 var (
-	ErrCertificateParse  = fmt.Errorf("failed to parse certificate")
-	ErrSignedAttributes  = fmt.Errorf("failed to create signed attributes")
-	ErrSign              = fmt.Errorf("failed to sign")
-	ErrMarshalSignedData = fmt.Errorf("failed to marshal SignedData")
+	ErrCertificateParse   = fmt.Errorf("failed to parse certificate")
+	ErrSignedAttributes   = fmt.Errorf("failed to create signed attributes")
+	ErrSign               = fmt.Errorf("failed to sign")
+	ErrMarshalSignedData  = fmt.Errorf("failed to marshal SignedData")
+	ErrKeySize            = fmt.Errorf("unsupported key size")
+	ErrCertificateChain   = fmt.Errorf("certificate chain validation failed")
+	ErrTrustStoreRequired = fmt.Errorf("trust store is required for revocation checking")
 )
 
 // OIDs for GOST algorithms
@@ -31,6 +41,12 @@ var (
 	OIDGostR341012256 = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 1, 1}
 	// GOST R 34.10-2012 with GOST R 34.11-2012 (256 bit)
 	OIDGostR341012256WithGostR341112256 = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 3, 2}
+	// GOST R 34.11-2012 512-bit hash
+	OIDGostR341112512 = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 2, 3}
+	// GOST R 34.10-2012 512-bit signature
+	OIDGostR341012512 = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 1, 2}
+	// GOST R 34.10-2012 with GOST R 34.11-2012 (512 bit)
+	OIDGostR341012512WithGostR341112512 = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 3, 3}
 
 	// PKCS#7 OIDs
 	OIDData       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
@@ -40,8 +56,17 @@ var (
 	OIDAttributeContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
 	OIDAttributeSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
 	OIDAttributeMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	// id-countersignature (unsigned attribute)
+	OIDAttributeCountersignature = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 6}
+	// id-aa-signatureTimeStampToken (unsigned attribute)
+	OIDAttributeSignatureTimeStampToken = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 14}
 )
 
+// Timestamper obtains RFC 3161 timestamp token (DER-encoded ContentInfo) for signature value
+type Timestamper interface {
+	Timestamp(ctx context.Context, signature []byte) ([]byte, error)
+}
+
 // ContentInfo is the top-level CMS structure
 type ContentInfo struct {
 	ContentType asn1.ObjectIdentifier
@@ -54,6 +79,7 @@ type SignedData struct {
 	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
 	EncapContentInfo EncapsulatedContentInfo
 	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
 	SignerInfos      []SignerInfo  `asn1:"set"`
 }
 
@@ -71,6 +97,7 @@ type SignerInfo struct {
 	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
 	SignatureAlgorithm pkix.AlgorithmIdentifier
 	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
 }
 
 // IssuerAndSerial identifies the signer's certificate
@@ -85,113 +112,315 @@ type Attribute struct {
 	Values asn1.RawValue `asn1:"set"`
 }
 
+// algorithm describes digest and signature algorithms matching the key size
+type algorithm struct {
+	digestOID    asn1.ObjectIdentifier
+	signatureOID asn1.ObjectIdentifier
+	newHash      func() hash.Hash
+}
+
+var (
+	// GOST R 34.10-2012 256-bit key with Streebog-256
+	algorithm256 = algorithm{
+		digestOID:    OIDGostR341112256,
+		signatureOID: OIDGostR341012256,
+		newHash:      func() hash.Hash { return gost34112012256.New() },
+	}
+	// GOST R 34.10-2012 512-bit key with Streebog-512
+	algorithm512 = algorithm{
+		digestOID:    OIDGostR341112512,
+		signatureOID: OIDGostR341012512,
+		newHash:      func() hash.Hash { return gost34112012512.New() },
+	}
+)
+
+// algorithmForKeySize picks algorithms by private key length in bytes
+func algorithmForKeySize(size int) (algorithm, error) {
+	switch size {
+	case 32:
+		return algorithm256, nil
+	case 64:
+		return algorithm512, nil
+	default:
+		return algorithm{}, errors.Wrapf(ErrKeySize, "%d bytes", size)
+	}
+}
+
 // Signer holds the signing context
 type Signer struct {
+	// Private key, nil if signer was created with NewSignerFromKey
 	PrivateKey *gost3410.PrivateKey
+	// Key which makes signatures
+	Key crypto.Signer
 	// DER-encoded certificate
 	Certificate []byte
-	certParsed  *certificate
+	certParsed  *gostx509.Certificate
+	chain       []*gostx509.Certificate
+	alg         algorithm
+	profile     Profile
+	timestamper Timestamper
+	contentType asn1.ObjectIdentifier
+	now         func() time.Time
+	rand        io.Reader
 }
 
-// certificate is a minimal structure to extract issuer and serial
-type certificate struct {
-	TBSCertificate struct {
-		Raw          asn1.RawContent
-		Version      int `asn1:"optional,explicit,tag:0,default:0"`
-		SerialNumber *big.Int
-		Signature    pkix.AlgorithmIdentifier
-		Issuer       asn1.RawValue
+// NewSigner creates a new CMS signer.
+// Digest and signature algorithms follow the key size: Streebog-256 for 256-bit keys
+// and Streebog-512 for 512-bit keys.
+// With WithTrustStore option the certificate chain is validated before signer is created,
+// with WithRevocationChecker the chain certificates are also checked for revocation.
+// WithProfile(ProfileCAdESBES) makes CAdES-BES signatures, WithTimestamper adds signature timestamps (CAdES-T).
+func NewSigner(privateKey *gost3410.PrivateKey, certDER []byte, opts ...Option) (*Signer, error) {
+	if _, err := algorithmForKeySize(len(privateKey.Raw())); err != nil {
+		return nil, err
+	}
+	key, err := NewKeySigner(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := NewSignerFromKey(key, certDER, opts...)
+	if err != nil {
+		return nil, err
 	}
+	signer.PrivateKey = privateKey
+	return signer, nil
 }
 
-// NewSigner creates a new CMS signer
-func NewSigner(privateKey *gost3410.PrivateKey, certDER []byte) (*Signer, error) {
-	var cert certificate
-	_, err := asn1.Unmarshal(certDER, &cert)
+// NewSignerFromKey creates CMS signer signing through crypto.Signer, so that key may live in
+// a key store, external process or remote service. Public method of key must return
+// *gost3410.PublicKey, Sign receives Streebog digest of signed attributes (see KeySigner).
+// Public key of key must be the certificate public key, otherwise ErrKeyMismatch is returned.
+// Options are the same as of NewSigner.
+func NewSignerFromKey(key crypto.Signer, certDER []byte, opts ...Option) (*Signer, error) {
+	cert, err := gostx509.ParseCertificate(certDER)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse certificate")
 	}
 
+	alg, err := algorithmForPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	if err := checkKeyMatchesCertificate(key.Public(), cert); err != nil {
+		return nil, err
+	}
+
+	o := newOptions(opts)
+	chain, err := o.verifyChain(cert, o.now(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := o.contentType
+	if contentType == nil {
+		contentType = OIDData
+	}
+
 	return &Signer{
-		PrivateKey:  privateKey,
+		Key:         key,
 		Certificate: certDER,
-		certParsed:  &cert,
+		certParsed:  cert,
+		chain:       chain,
+		alg:         alg,
+		profile:     o.profile,
+		timestamper: o.timestamper,
+		contentType: contentType,
+		now:         o.now,
+		rand:        o.rand,
 	}, nil
 }
 
+// CertificateHash returns Streebog-256 hash of DER-encoded certificate as uppercase hex.
+// This is the client_certificate_hash value of ESIA v2 requests (same as cpverify -mk -alg GR3411_2012_256 output).
+func CertificateHash(certDER []byte) string {
+	h := gost34112012256.New()
+	h.Write(certDER)
+	return strings.ToUpper(hex.EncodeToString(h.Sum(nil)))
+}
+
+// ParsedCertificate returns parsed signer certificate (subject, validity, key usage etc.)
+func (s *Signer) ParsedCertificate() *gostx509.Certificate {
+	return s.certParsed
+}
+
+// Chain returns validated certificate chain (from signer certificate to root).
+// It is empty if signer was created without trust store.
+func (s *Signer) Chain() []*gostx509.Certificate {
+	return s.chain
+}
+
+// CertificateHash returns hash of the signer certificate, see CertificateHash
+func (s *Signer) CertificateHash() string {
+	return CertificateHash(s.Certificate)
+}
+
 // Sign creates a CMS SignedData structure (detached mode with signedAttributes)
 func (s *Signer) Sign(content []byte) ([]byte, error) {
+	return sign(content, true, []*Signer{s})
+}
+
+// SignAt is like Sign but puts signingTime t instead of current time of signer clock,
+// e.g. to match the timestamp parameter of ESIA request
+func (s *Signer) SignAt(content []byte, t time.Time) ([]byte, error) {
+	return sign(content, true, []*Signer{s.at(t)})
+}
+
+// SignAttached creates a CMS SignedData structure with content embedded as eContent
+func (s *Signer) SignAttached(content []byte) ([]byte, error) {
+	return sign(content, false, []*Signer{s})
+}
+
+// SignAttachedAt is like SignAttached but puts signingTime t instead of current time of signer clock
+func (s *Signer) SignAttachedAt(content []byte, t time.Time) ([]byte, error) {
+	return sign(content, false, []*Signer{s.at(t)})
+}
+
+// at returns copy of signer with clock fixed at t
+func (s *Signer) at(t time.Time) *Signer {
+	signer := *s
+	signer.now = func() time.Time { return t }
+	return &signer
+}
+
+// createSignerInfo signs content and builds SignerInfo with signedAttributes.
+// contentType attribute is omitted if contentType is nil (countersignatures).
+func (s *Signer) createSignerInfo(content []byte, contentType asn1.ObjectIdentifier) (SignerInfo, error) {
 	// 1. Compute digest of content
-	h := gost34112012256.New()
+	h := s.alg.newHash()
 	if _, err := h.Write(content); err != nil {
-		return nil, errors.Wrap(err, "failed to hash content")
+		return SignerInfo{}, errors.Wrap(err, "failed to hash content")
 	}
 	contentDigest := h.Sum(nil)
 
 	// 2. Create signedAttributes
-	signedAttrs, attrsForSigning, err := s.createSignedAttributes(contentDigest)
+	signedAttrs, attrsForSigning, err := s.createSignedAttributes(contentDigest, contentType)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to create signed attributes")
+		return SignerInfo{}, errors.Wrap(err, "failed to create signed attributes")
 	}
 
 	// 3. Hash the signedAttributes (what we actually sign)
-	h = gost34112012256.New()
+	h = s.alg.newHash()
 	if _, err := h.Write(attrsForSigning); err != nil {
-		return nil, errors.Wrap(err, "failed to hash attributes")
+		return SignerInfo{}, errors.Wrap(err, "failed to hash attributes")
 	}
 	attrsDigest := h.Sum(nil)
 
 	// 4. Sign the attributes digest
-	// GOST-engine reverses the digest (little-endian to big-endian) before signing
-	// gogost expects the same format, so we need to reverse the digest
-	reversedDigest := utils.ReverseBytes(attrsDigest)
-	rawSig, err := s.PrivateKey.SignDigest(reversedDigest, rand.Reader)
+	// Key gets the digest as computed by hash; GOST-engine reverses it (little-endian
+	// to big-endian) before signing, KeySigner does the same for raw keys
+	rawSig, err := s.Key.Sign(s.rand, attrsDigest, nil)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to sign")
+		return SignerInfo{}, errors.Wrap(err, "failed to sign")
 	}
 
 	// 5. Build SignerInfo with signedAttributes
 	signerInfo := SignerInfo{
 		Version: 1,
 		IssuerAndSerial: IssuerAndSerial{
-			Issuer:       s.certParsed.TBSCertificate.Issuer,
-			SerialNumber: s.certParsed.TBSCertificate.SerialNumber,
+			Issuer:       asn1.RawValue{FullBytes: s.certParsed.RawIssuer},
+			SerialNumber: s.certParsed.SerialNumber,
 		},
 		DigestAlgorithm: pkix.AlgorithmIdentifier{
-			Algorithm:  OIDGostR341112256,
+			Algorithm:  s.alg.digestOID,
 			Parameters: asn1.NullRawValue,
 		},
 		SignedAttrs: signedAttrs,
 		SignatureAlgorithm: pkix.AlgorithmIdentifier{
-			Algorithm:  OIDGostR341012256,
+			Algorithm:  s.alg.signatureOID,
 			Parameters: asn1.NullRawValue,
 		},
 		Signature: rawSig,
 	}
+	if s.timestamper != nil {
+		unsignedAttrs, err := s.createTimestampAttribute(rawSig)
+		if err != nil {
+			return SignerInfo{}, err
+		}
+		signerInfo.UnsignedAttrs = unsignedAttrs
+	}
+	return signerInfo, nil
+}
 
-	// 6. Build SignedData (detached mode - no eContent)
-	signedData := SignedData{
-		Version: 1,
-		DigestAlgorithms: []pkix.AlgorithmIdentifier{
-			{
-				Algorithm:  OIDGostR341112256,
-				Parameters: asn1.NullRawValue,
-			},
-		},
-		EncapContentInfo: EncapsulatedContentInfo{
-			EContentType: OIDData,
-			// Detached mode: eContent is omitted
-		},
-		Certificates: asn1.RawValue{
+// sign builds SignedData with SignerInfo of every signer
+func sign(content []byte, detached bool, signers []*Signer) ([]byte, error) {
+	if len(signers) == 0 {
+		return nil, ErrNoSigners
+	}
+	contentType := signers[0].contentType
+	for _, s := range signers[1:] {
+		if !s.contentType.Equal(contentType) {
+			return nil, errors.Wrapf(ErrContentTypeMismatch, "signers use %s and %s", contentType, s.contentType)
+		}
+	}
+
+	// 6. Build SignedData (eContent is omitted in detached mode)
+	encapContentInfo := EncapsulatedContentInfo{
+		EContentType: contentType,
+	}
+	if !detached {
+		// encoding/asn1 ignores "explicit" for RawValue, so [0] wrapper is built by hand
+		eContent, err := asn1.Marshal(content)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal eContent")
+		}
+		encapContentInfo.EContent = asn1.RawValue{
 			Class:      asn1.ClassContextSpecific,
 			Tag:        0,
 			IsCompound: true,
-			Bytes:      s.Certificate,
-		},
-		SignerInfos: []SignerInfo{signerInfo},
+			Bytes:      eContent,
+		}
+	}
+	signedData := &SignedData{
+		Version:          1,
+		EncapContentInfo: encapContentInfo,
+	}
+	for _, s := range signers {
+		signerInfo, err := s.createSignerInfo(content, contentType)
+		if err != nil {
+			return nil, err
+		}
+		signedData.addSigner(s, signerInfo)
+	}
+
+	return marshalSignedData(signedData)
+}
+
+// addSigner appends SignerInfo, its digest algorithm and certificate (unless already present)
+func (sd *SignedData) addSigner(s *Signer, signerInfo SignerInfo) {
+	hasDigest := false
+	for _, alg := range sd.DigestAlgorithms {
+		if alg.Algorithm.Equal(s.alg.digestOID) {
+			hasDigest = true
+			break
+		}
+	}
+	if !hasDigest {
+		sd.DigestAlgorithms = append(sd.DigestAlgorithms, pkix.AlgorithmIdentifier{
+			Algorithm:  s.alg.digestOID,
+			Parameters: asn1.NullRawValue,
+		})
 	}
 
-	signedDataBytes, err := asn1.Marshal(signedData)
+	sd.addCertificate(s.Certificate)
+	sd.SignerInfos = append(sd.SignerInfos, signerInfo)
+}
+
+// addCertificate adds DER-encoded certificate to CertificateSet unless it is already there
+func (sd *SignedData) addCertificate(certDER []byte) {
+	if bytes.Contains(sd.Certificates.Bytes, certDER) {
+		return
+	}
+	sd.Certificates = asn1.RawValue{
+		Class:      asn1.ClassContextSpecific,
+		Tag:        0,
+		IsCompound: true,
+		Bytes:      append(append([]byte{}, sd.Certificates.Bytes...), certDER...),
+	}
+}
+
+// marshalSignedData encodes SignedData wrapped in ContentInfo
+func marshalSignedData(signedData *SignedData) ([]byte, error) {
+	signedDataBytes, err := asn1.Marshal(*signedData)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to marshal SignedData")
 	}
@@ -210,24 +439,28 @@ func (s *Signer) Sign(content []byte) ([]byte, error) {
 	return asn1.Marshal(contentInfo)
 }
 
-func (s *Signer) createSignedAttributes(digest []byte) (asn1.RawValue, []byte, error) {
-	// Content type attribute
-	contentTypeBytes, err := asn1.Marshal(OIDData)
-	if err != nil {
-		return asn1.RawValue{}, nil, errors.Wrap(err, "failed to marshal content type OID")
-	}
-	contentTypeAttr := Attribute{
-		Type: OIDAttributeContentType,
-		Values: asn1.RawValue{
-			Class:      asn1.ClassUniversal,
-			Tag:        asn1.TagSet,
-			IsCompound: true,
-			Bytes:      contentTypeBytes,
-		},
+func (s *Signer) createSignedAttributes(digest []byte, contentType asn1.ObjectIdentifier) (asn1.RawValue, []byte, error) {
+	var attrs []Attribute
+
+	// Content type attribute (must be absent in countersignatures)
+	if contentType != nil {
+		contentTypeBytes, err := asn1.Marshal(contentType)
+		if err != nil {
+			return asn1.RawValue{}, nil, errors.Wrap(err, "failed to marshal content type OID")
+		}
+		attrs = append(attrs, Attribute{
+			Type: OIDAttributeContentType,
+			Values: asn1.RawValue{
+				Class:      asn1.ClassUniversal,
+				Tag:        asn1.TagSet,
+				IsCompound: true,
+				Bytes:      contentTypeBytes,
+			},
+		})
 	}
 
 	// Signing time attribute
-	signingTime := time.Now().UTC()
+	signingTime := s.now().UTC()
 	signingTimeBytes, err := asn1.Marshal(signingTime)
 	if err != nil {
 		return asn1.RawValue{}, nil, errors.Wrap(err, "failed to marshal signing time")
@@ -259,7 +492,16 @@ func (s *Signer) createSignedAttributes(digest []byte) (asn1.RawValue, []byte, e
 
 	// Marshal attributes as SET
 	// Order matches OpenSSL: contentType (1.9.3), signingTime (1.9.5), messageDigest (1.9.4)
-	attrs := []Attribute{contentTypeAttr, signingTimeAttr, messageDigestAttr}
+	attrs = append(attrs, signingTimeAttr, messageDigestAttr)
+
+	// CAdES-BES: signingCertificateV2 goes last, its encoding is the longest so DER order of SET is kept
+	if s.profile == ProfileCAdESBES {
+		signingCertAttr, err := signingCertificateV2Attribute(s.certParsed, s.alg)
+		if err != nil {
+			return asn1.RawValue{}, nil, err
+		}
+		attrs = append(attrs, signingCertAttr)
+	}
 	attrsBytes, err := asn1.Marshal(attrs)
 	if err != nil {
 		return asn1.RawValue{}, nil, errors.Wrap(err, "failed to marshal attributes")
@@ -273,13 +515,54 @@ func (s *Signer) createSignedAttributes(digest []byte) (asn1.RawValue, []byte, e
 	attrsForSigning[0] = 0x31
 
 	// For embedding, use implicit tag [0]
+	// Length may take more than one byte (e.g. with Streebog-512 digest), so unwrap SEQUENCE properly
+	var attrsSeq asn1.RawValue
+	if _, err := asn1.Unmarshal(attrsBytes, &attrsSeq); err != nil {
+		return asn1.RawValue{}, nil, errors.Wrap(err, "failed to unwrap attributes")
+	}
 	signedAttrs := asn1.RawValue{
 		Class:      asn1.ClassContextSpecific,
 		Tag:        0,
 		IsCompound: true,
-		// skip SEQUENCE tag and length
-		Bytes: attrsBytes[2:],
+		// content of SEQUENCE without tag and length
+		Bytes: attrsSeq.Bytes,
 	}
 
 	return signedAttrs, attrsForSigning, nil
 }
+
+// createTimestampAttribute requests timestamp of signature value and wraps it into unsigned attributes
+func (s *Signer) createTimestampAttribute(signature []byte) (asn1.RawValue, error) {
+	token, err := s.timestamper.Timestamp(context.Background(), signature)
+	if err != nil {
+		return asn1.RawValue{}, errors.Wrap(err, "failed to get signature timestamp")
+	}
+	attr := Attribute{
+		Type: OIDAttributeSignatureTimeStampToken,
+		Values: asn1.RawValue{
+			Class:      asn1.ClassUniversal,
+			Tag:        asn1.TagSet,
+			IsCompound: true,
+			Bytes:      token,
+		},
+	}
+	return marshalAttributes(1, []Attribute{attr})
+}
+
+// marshalAttributes encodes attributes as SET with IMPLICIT [tag]
+func marshalAttributes(tag int, attrs []Attribute) (asn1.RawValue, error) {
+	attrsBytes, err := asn1.Marshal(attrs)
+	if err != nil {
+		return asn1.RawValue{}, errors.Wrap(err, "failed to marshal attributes")
+	}
+	var attrsSeq asn1.RawValue
+	if _, err := asn1.Unmarshal(attrsBytes, &attrsSeq); err != nil {
+		return asn1.RawValue{}, errors.Wrap(err, "failed to unwrap attributes")
+	}
+	return asn1.RawValue{
+		Class:      asn1.ClassContextSpecific,
+		Tag:        tag,
+		IsCompound: true,
+		Bytes:      attrsSeq.Bytes,
+	}, nil
+}
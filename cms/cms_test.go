@@ -3,9 +3,13 @@ package cms
 import (
 	"crypto/rand"
 	"encoding/asn1"
+	"encoding/hex"
+	mathrand "math/rand"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/LdDl/esia-potato/internal/gosttest"
 	"github.com/ddulesov/gogost/gost3410"
 	"github.com/ddulesov/gogost/gost34112012256"
 	"github.com/stretchr/testify/assert"
@@ -27,8 +31,8 @@ func createTestPrivateKey(t *testing.T) *gost3410.PrivateKey {
 
 func createTestCertDER() []byte {
 	cert := []byte{
-		0x30, 0x82, 0x01, 0x00, // SEQUENCE
-		0x30, 0x81, 0xf0, // tbsCertificate SEQUENCE
+		0x30, 0x82, 0x01, 0x08, // SEQUENCE
+		0x30, 0x81, 0xb6, // tbsCertificate SEQUENCE
 		0xa0, 0x03, 0x02, 0x01, 0x02, // version
 		0x02, 0x01, 0x01, // serialNumber
 		0x30, 0x0a, 0x06, 0x08, 0x2a, 0x85, 0x03, 0x07, 0x01, 0x01, 0x03, 0x02, // algorithm
@@ -158,7 +162,7 @@ func TestNewSignerValidation(t *testing.T) {
 // go test -timeout 30s -run ^TestSignProducesDER$ github.com/LdDl/esia-potato/cms
 func TestSignProducesDER(t *testing.T) {
 	prv := createTestPrivateKey(t)
-	certDER := createTestCertDER()
+	certDER := gosttest.SelfSigned(t, prv, "Test Signer", 1)
 
 	signer, err := NewSigner(prv, certDER)
 	require.NoError(t, err, "NewSigner failed")
@@ -175,7 +179,7 @@ func TestSignProducesDER(t *testing.T) {
 // go test -timeout 30s -run ^TestSignDeterministicContent$ github.com/LdDl/esia-potato/cms
 func TestSignDeterministicContent(t *testing.T) {
 	prv := createTestPrivateKey(t)
-	certDER := createTestCertDER()
+	certDER := gosttest.SelfSigned(t, prv, "Test Signer", 1)
 
 	signer, err := NewSigner(prv, certDER)
 	require.NoError(t, err, "NewSigner failed")
@@ -191,3 +195,105 @@ func TestSignDeterministicContent(t *testing.T) {
 	sizeDiff := len(cms1) - len(cms2)
 	assert.InDelta(t, 0, sizeDiff, 10, "CMS sizes differ too much")
 }
+
+// go test -timeout 30s -run ^TestSignReproducible$ github.com/LdDl/esia-potato/cms
+func TestSignReproducible(t *testing.T) {
+	prv := createTestPrivateKey(t)
+	certDER := gosttest.SelfSigned(t, prv, "Test Signer", 1)
+	signingTime := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return signingTime }
+
+	newSigner := func(seed int64) *Signer {
+		signer, err := NewSigner(prv, certDER, WithClock(clock), WithRand(mathrand.New(mathrand.NewSource(seed))))
+		require.NoError(t, err)
+		return signer
+	}
+
+	message := []byte("test message")
+	cms1, err := newSigner(1).Sign(message)
+	require.NoError(t, err)
+	cms2, err := newSigner(1).Sign(message)
+	require.NoError(t, err)
+	assert.Equal(t, cms1, cms2, "Same clock and randomness should give byte-identical SignedData")
+
+	cms3, err := newSigner(2).Sign(message)
+	require.NoError(t, err)
+	assert.NotEqual(t, cms1, cms3)
+
+	result, err := Verify(cms1, message, WithClock(clock))
+	require.NoError(t, err)
+	assert.Equal(t, signingTime, result.SigningTime)
+}
+
+// go test -timeout 30s -run ^TestSignAt$ github.com/LdDl/esia-potato/cms
+func TestSignAt(t *testing.T) {
+	signer := createTestSigner(t)
+
+	signingTime := time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC)
+	message := []byte("openid2025.03.04 05:06:07 +0000CLIENT_ID12345")
+	cmsDER, err := signer.SignAt(message, signingTime)
+	require.NoError(t, err)
+
+	result, err := Verify(cmsDER, message)
+	require.NoError(t, err)
+	assert.Equal(t, signingTime, result.SigningTime)
+
+	cmsDER, err = signer.SignAttachedAt(message, signingTime)
+	require.NoError(t, err)
+	result, err = Verify(cmsDER, nil)
+	require.NoError(t, err)
+	assert.Equal(t, signingTime, result.SigningTime)
+	assert.Equal(t, message, result.Content)
+}
+
+// go test -timeout 30s -run ^TestSign512$ github.com/LdDl/esia-potato/cms
+func TestSign512(t *testing.T) {
+	curve := gost3410.CurveIdtc26gost341012512paramSetA()
+
+	keyBytes := make([]byte, 64)
+	_, err := rand.Read(keyBytes[:31])
+	require.NoError(t, err, "Failed to generate random key")
+	keyBytes[0] |= 0x01
+
+	prv, err := gost3410.NewPrivateKey(curve, gost3410.Mode2012, keyBytes)
+	require.NoError(t, err, "Failed to create private key")
+
+	signer, err := NewSigner(prv, gosttest.SelfSigned(t, prv, "Test Signer", 1))
+	require.NoError(t, err, "NewSigner failed")
+
+	cmsDER, err := signer.Sign([]byte("test message"))
+	require.NoError(t, err, "Sign failed")
+
+	var contentInfo ContentInfo
+	_, err = asn1.Unmarshal(cmsDER, &contentInfo)
+	require.NoError(t, err, "Failed to parse ContentInfo")
+
+	var signedData SignedData
+	_, err = asn1.Unmarshal(contentInfo.Content.Bytes, &signedData)
+	require.NoError(t, err, "Failed to parse SignedData")
+
+	require.Len(t, signedData.SignerInfos, 1)
+	signerInfo := signedData.SignerInfos[0]
+	assert.True(t, signedData.DigestAlgorithms[0].Algorithm.Equal(OIDGostR341112512), "Digest algorithm should be Streebog-512")
+	assert.True(t, signerInfo.DigestAlgorithm.Algorithm.Equal(OIDGostR341112512), "Digest algorithm should be Streebog-512")
+	assert.True(t, signerInfo.SignatureAlgorithm.Algorithm.Equal(OIDGostR341012512), "Signature algorithm should be GOST R 34.10-2012 512")
+	assert.Len(t, signerInfo.Signature, 128, "Signature should be 128 bytes")
+}
+
+// go test -timeout 30s -run ^TestCertificateHash$ github.com/LdDl/esia-potato/cms
+func TestCertificateHash(t *testing.T) {
+	certDER := createTestCertDER()
+
+	h := gost34112012256.New()
+	h.Write(certDER)
+	expected := strings.ToUpper(hex.EncodeToString(h.Sum(nil)))
+
+	assert.Equal(t, expected, CertificateHash(certDER))
+	assert.Len(t, CertificateHash(certDER), 64, "Streebog-256 hex should be 64 chars")
+
+	prv := createTestPrivateKey(t)
+	certDER = gosttest.SelfSigned(t, prv, "Test Signer", 1)
+	signer, err := NewSigner(prv, certDER)
+	require.NoError(t, err)
+	assert.Equal(t, CertificateHash(certDER), signer.CertificateHash())
+}
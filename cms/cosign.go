@@ -0,0 +1,55 @@
+package cms
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+)
+
+// Sign creates detached SignedData signed by every signer (co-signature).
+// Signers may use different key sizes but must share content type.
+func Sign(content []byte, signers ...*Signer) ([]byte, error) {
+	return sign(content, true, signers)
+}
+
+// SignAttached creates SignedData with content embedded as eContent signed by every signer
+func SignAttached(content []byte, signers ...*Signer) ([]byte, error) {
+	return sign(content, false, signers)
+}
+
+// AppendTo adds signature of s to existing DER-encoded SignedData (ContentInfo) and returns
+// the new SignedData. Existing SignerInfos are kept as is, so their signatures stay valid.
+// For detached signatures content must be provided, for attached ones it may be nil.
+// Existing signatures are not verified, use Verify or VerifyAll for that.
+func (s *Signer) AppendTo(signature, content []byte) ([]byte, error) {
+	signedData, err := parseSignedData(signature)
+	if err != nil {
+		return nil, err
+	}
+
+	eContent, detached, err := encapsulatedContent(&signedData.EncapContentInfo)
+	if err != nil {
+		return nil, err
+	}
+	if detached && content == nil {
+		return nil, ErrContentMissing
+	}
+	if !detached {
+		if content != nil && !bytes.Equal(content, eContent) {
+			return nil, ErrContentMismatch
+		}
+		content = eContent
+	}
+
+	if !signedData.EncapContentInfo.EContentType.Equal(s.contentType) {
+		return nil, errors.Wrapf(ErrContentTypeMismatch, "SignedData has %s, signer uses %s", signedData.EncapContentInfo.EContentType, s.contentType)
+	}
+
+	signerInfo, err := s.createSignerInfo(content, s.contentType)
+	if err != nil {
+		return nil, err
+	}
+	signedData.addSigner(s, signerInfo)
+
+	return marshalSignedData(signedData)
+}
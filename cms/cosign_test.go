@@ -0,0 +1,108 @@
+package cms
+
+import (
+	"encoding/asn1"
+	"testing"
+
+	"github.com/LdDl/esia-potato/internal/gosttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createTestSigner512(t *testing.T, commonName string, serial int64) *Signer {
+	prv := gosttest.PrivateKey512(t)
+	signer, err := NewSigner(prv, gosttest.SelfSigned(t, prv, commonName, serial))
+	require.NoError(t, err)
+	return signer
+}
+
+// go test -timeout 30s -run ^TestSignMultiple$ github.com/LdDl/esia-potato/cms
+func TestSignMultiple(t *testing.T) {
+	first := createTestSigner(t)
+	second := createTestSigner512(t, "Second Signer", 2)
+
+	message := []byte("contract")
+	cmsDER, err := Sign(message, first, second)
+	require.NoError(t, err)
+
+	signedData, err := parseSignedData(cmsDER)
+	require.NoError(t, err)
+	assert.Len(t, signedData.DigestAlgorithms, 2, "Both Streebog-256 and Streebog-512 should be listed")
+	certs, err := parseCertificates(signedData.Certificates.Bytes)
+	require.NoError(t, err)
+	assert.Len(t, certs, 2)
+
+	results, err := VerifyAll(cmsDER, message)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, first.Certificate, results[0].Certificate)
+	assert.Equal(t, second.Certificate, results[1].Certificate)
+	assert.True(t, results[1].Detached)
+
+	attached, err := SignAttached(message, first, second)
+	require.NoError(t, err)
+	results, err = VerifyAll(attached, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, message, results[1].Content)
+
+	_, err = Sign(message)
+	assert.ErrorIs(t, err, ErrNoSigners)
+
+	other, err := NewSigner(first.PrivateKey, first.Certificate, WithContentType(asn1.ObjectIdentifier{1, 2, 3}))
+	require.NoError(t, err)
+	_, err = Sign(message, first, other)
+	assert.ErrorIs(t, err, ErrContentTypeMismatch)
+}
+
+// go test -timeout 30s -run ^TestAppendTo$ github.com/LdDl/esia-potato/cms
+func TestAppendTo(t *testing.T) {
+	first := createTestSigner(t)
+	second := createTestSigner512(t, "Second Signer", 2)
+
+	message := []byte("contract")
+	cmsDER, err := first.Sign(message)
+	require.NoError(t, err)
+	original, err := Verify(cmsDER, message)
+	require.NoError(t, err)
+
+	cosigned, err := second.AppendTo(cmsDER, message)
+	require.NoError(t, err)
+
+	results, err := VerifyAll(cosigned, message)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, original.Signature, results[0].Signature, "First signature should be kept as is")
+	assert.Equal(t, second.Certificate, results[1].Certificate)
+
+	// Certificate of the same signer is not duplicated
+	again, err := first.AppendTo(cosigned, message)
+	require.NoError(t, err)
+	signedData, err := parseSignedData(again)
+	require.NoError(t, err)
+	assert.Len(t, signedData.SignerInfos, 3)
+	certs, err := parseCertificates(signedData.Certificates.Bytes)
+	require.NoError(t, err)
+	assert.Len(t, certs, 2)
+
+	_, err = second.AppendTo(cmsDER, nil)
+	assert.ErrorIs(t, err, ErrContentMissing)
+
+	// AppendTo does not verify existing signatures, VerifyAll catches it
+	tampered, err := second.AppendTo(cmsDER, []byte("tampered"))
+	require.NoError(t, err)
+	_, err = VerifyAll(tampered, []byte("tampered"))
+	assert.ErrorIs(t, err, ErrMessageDigestMismatch)
+
+	// Attached
+	attached, err := first.SignAttached(message)
+	require.NoError(t, err)
+	cosigned, err = second.AppendTo(attached, nil)
+	require.NoError(t, err)
+	results, err = VerifyAll(cosigned, nil)
+	require.NoError(t, err)
+	assert.Len(t, results, 2)
+
+	_, err = second.AppendTo(attached, []byte("other"))
+	assert.ErrorIs(t, err, ErrContentMismatch)
+}
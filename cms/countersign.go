@@ -0,0 +1,91 @@
+package cms
+
+import (
+	"encoding/asn1"
+	"fmt"
+
+	"github.com/LdDl/esia-potato/gostx509"
+	"github.com/pkg/errors"
+)
+
+// ErrSignerIndex is returned when SignedData has no signer with requested index
+var ErrSignerIndex = fmt.Errorf("signer index out of range")
+
+// Countersign adds countersignature of s to signer with index signerIndex of existing DER-encoded
+// SignedData (ContentInfo) and returns the new SignedData.
+// Countersignature signs signature value of that signer and is stored in its unsigned attributes,
+// so the countersigned signature itself stays valid. Signed attributes of countersignature
+// have no contentType (RFC 5652, section 11.4). Certificate of s is added to SignedData.
+func (s *Signer) Countersign(signature []byte, signerIndex int) ([]byte, error) {
+	signedData, err := parseSignedData(signature)
+	if err != nil {
+		return nil, err
+	}
+	if signerIndex < 0 || signerIndex >= len(signedData.SignerInfos) {
+		return nil, errors.Wrapf(ErrSignerIndex, "index %d, signers %d", signerIndex, len(signedData.SignerInfos))
+	}
+	target := &signedData.SignerInfos[signerIndex]
+
+	counter, err := s.createSignerInfo(target.Signature, nil)
+	if err != nil {
+		return nil, err
+	}
+	counterBytes, err := asn1.Marshal(counter)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal countersignature")
+	}
+
+	attrs, err := unsignedAttributes(target)
+	if err != nil {
+		return nil, err
+	}
+	attrs = append(attrs, Attribute{
+		Type: OIDAttributeCountersignature,
+		Values: asn1.RawValue{
+			Class:      asn1.ClassUniversal,
+			Tag:        asn1.TagSet,
+			IsCompound: true,
+			Bytes:      counterBytes,
+		},
+	})
+	if target.UnsignedAttrs, err = marshalAttributes(1, attrs); err != nil {
+		return nil, err
+	}
+
+	signedData.addCertificate(s.Certificate)
+	return marshalSignedData(signedData)
+}
+
+// verifyCountersignatures verifies every countersignature in unsigned attributes of si
+func (o *options) verifyCountersignatures(si *SignerInfo, certs []*gostx509.Certificate) ([]*VerifyResult, error) {
+	attrs, err := unsignedAttributes(si)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*VerifyResult
+	for _, attr := range attrs {
+		if !attr.Type.Equal(OIDAttributeCountersignature) {
+			continue
+		}
+		// Attribute may hold several SignerInfo values
+		values := attr.Values.Bytes
+		for len(values) > 0 {
+			var counter SignerInfo
+			rest, err := asn1.Unmarshal(values, &counter)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to parse countersignature")
+			}
+			values = rest
+
+			// Absent contentType is required, so eContentType is nil
+			result, err := o.verifySigner(&counter, nil, si.Signature, certs)
+			if err != nil {
+				return nil, errors.Wrapf(err, "countersignature %d", len(results))
+			}
+			result.Content = si.Signature
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,78 @@
+package cms
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -timeout 30s -run ^TestCountersign$ github.com/LdDl/esia-potato/cms
+func TestCountersign(t *testing.T) {
+	employee := createTestSigner(t)
+	manager := createTestSigner512(t, "Manager", 2)
+
+	message := []byte("vacation request")
+	cmsDER, err := employee.Sign(message)
+	require.NoError(t, err)
+
+	countersigned, err := manager.Countersign(cmsDER, 0)
+	require.NoError(t, err)
+
+	results, err := VerifyAll(countersigned, message)
+	require.NoError(t, err)
+	require.Len(t, results, 1, "Countersignature is not a separate signer")
+	assert.Equal(t, employee.Certificate, results[0].Certificate)
+	require.Len(t, results[0].Countersignatures, 1)
+	counter := results[0].Countersignatures[0]
+	assert.Equal(t, manager.Certificate, counter.Certificate)
+	assert.Equal(t, results[0].Signature, counter.Content)
+	assert.False(t, counter.SigningTime.IsZero())
+
+	// Countersignature has no contentType attribute
+	signedData, err := parseSignedData(countersigned)
+	require.NoError(t, err)
+	unsigned, err := unsignedAttributes(&signedData.SignerInfos[0])
+	require.NoError(t, err)
+	require.Len(t, unsigned, 1)
+	assert.True(t, unsigned[0].Type.Equal(OIDAttributeCountersignature))
+	certs, err := parseCertificates(signedData.Certificates.Bytes)
+	require.NoError(t, err)
+	assert.Len(t, certs, 2)
+
+	// Second countersignature is added next to the first one
+	twice, err := employee.Countersign(countersigned, 0)
+	require.NoError(t, err)
+	result, err := Verify(twice, message)
+	require.NoError(t, err)
+	require.Len(t, result.Countersignatures, 2)
+	assert.Equal(t, employee.Certificate, result.Countersignatures[1].Certificate)
+
+	_, err = manager.Countersign(cmsDER, 1)
+	assert.ErrorIs(t, err, ErrSignerIndex)
+}
+
+// go test -timeout 30s -run ^TestCountersignCoSigned$ github.com/LdDl/esia-potato/cms
+func TestCountersignCoSigned(t *testing.T) {
+	first := createTestSigner(t)
+	second := createTestSigner512(t, "Second Signer", 2)
+	manager := createTestSigner512(t, "Manager", 3)
+
+	message := []byte("contract")
+	cmsDER, err := Sign(message, first, second)
+	require.NoError(t, err)
+
+	countersigned, err := manager.Countersign(cmsDER, 1)
+	require.NoError(t, err)
+
+	results, err := VerifyAll(countersigned, message)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Empty(t, results[0].Countersignatures)
+	require.Len(t, results[1].Countersignatures, 1)
+	assert.Equal(t, manager.Certificate, results[1].Countersignatures[0].Certificate)
+
+	// CAdES-BES profile applies to countersignatures too
+	_, err = VerifyAll(countersigned, message, WithProfile(ProfileCAdESBES))
+	assert.ErrorIs(t, err, ErrSigningCertificateMissing)
+}
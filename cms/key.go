@@ -0,0 +1,71 @@
+package cms
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+	"io"
+
+	"github.com/LdDl/esia-potato/gostx509"
+	"github.com/LdDl/esia-potato/utils"
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrUnsupportedKey is returned for signing keys whose public key is not GOST R 34.10-2012
+	ErrUnsupportedKey = fmt.Errorf("unsupported signing key")
+	// ErrKeyMismatch is returned when signing key does not belong to signer certificate
+	ErrKeyMismatch = fmt.Errorf("signing key does not match certificate")
+)
+
+var _ crypto.Signer = (*KeySigner)(nil)
+
+// KeySigner adapts GOST private key to crypto.Signer.
+// Sign takes Streebog digest as computed by hash.Hash and reverses it before signing
+// (GOST-engine convention), so signatures match the ones made with the key directly.
+type KeySigner struct {
+	key *gost3410.PrivateKey
+	pub *gost3410.PublicKey
+}
+
+// NewKeySigner creates crypto.Signer for GOST private key
+func NewKeySigner(key *gost3410.PrivateKey) (*KeySigner, error) {
+	pub, err := key.PublicKey()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to derive public key")
+	}
+	return &KeySigner{key: key, pub: pub}, nil
+}
+
+// Public returns *gost3410.PublicKey
+func (k *KeySigner) Public() crypto.PublicKey {
+	return k.pub
+}
+
+// Sign signs digest. Opts are ignored since GOST hashes are not registered in crypto.Hash
+func (k *KeySigner) Sign(rand io.Reader, digest []byte, _ crypto.SignerOpts) ([]byte, error) {
+	return k.key.SignDigest(utils.ReverseBytes(digest), rand)
+}
+
+// algorithmForPublicKey picks algorithms by GOST public key of crypto.Signer
+func algorithmForPublicKey(pub crypto.PublicKey) (algorithm, error) {
+	gostPub, ok := pub.(*gost3410.PublicKey)
+	if !ok || gostPub == nil {
+		return algorithm{}, errors.Wrapf(ErrUnsupportedKey, "public key type %T", pub)
+	}
+	// Public key is X||Y, each coordinate has private key size
+	return algorithmForKeySize(len(gostPub.Raw()) / 2)
+}
+
+// checkKeyMatchesCertificate ensures public key of crypto.Signer is the certificate public key
+func checkKeyMatchesCertificate(pub crypto.PublicKey, cert *gostx509.Certificate) error {
+	gostPub, ok := pub.(*gost3410.PublicKey)
+	if !ok || gostPub == nil {
+		return errors.Wrapf(ErrUnsupportedKey, "public key type %T", pub)
+	}
+	if !bytes.Equal(gostPub.Raw(), cert.PublicKeyRaw) {
+		return errors.Wrapf(ErrKeyMismatch, "certificate %q (serial %s)", cert.Subject.CommonName, cert.SerialNumber.Text(16))
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package cms
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"io"
+	mathrand "math/rand"
+	"testing"
+	"time"
+
+	"github.com/LdDl/esia-potato/internal/gosttest"
+	"github.com/LdDl/esia-potato/utils"
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// remoteKey emulates key living outside of the process: it only exposes crypto.Signer
+type remoteKey struct {
+	signer crypto.Signer
+	calls  int
+}
+
+func (k *remoteKey) Public() crypto.PublicKey {
+	return k.signer.Public()
+}
+
+func (k *remoteKey) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	k.calls++
+	return k.signer.Sign(rand, digest, opts)
+}
+
+// go test -timeout 30s -run ^TestKeySigner$ github.com/LdDl/esia-potato/cms
+func TestKeySigner(t *testing.T) {
+	prv := createTestPrivateKey(t)
+	key, err := NewKeySigner(prv)
+	require.NoError(t, err)
+
+	pub, ok := key.Public().(*gost3410.PublicKey)
+	require.True(t, ok)
+	expected, err := prv.PublicKey()
+	require.NoError(t, err)
+	assert.Equal(t, expected.Raw(), pub.Raw())
+
+	h := gost34112012256.New()
+	h.Write([]byte("test message"))
+	digest := h.Sum(nil)
+
+	signature, err := key.Sign(rand.Reader, digest, nil)
+	require.NoError(t, err)
+	valid, err := pub.VerifyDigest(utils.ReverseBytes(digest), signature)
+	require.NoError(t, err)
+	assert.True(t, valid, "KeySigner should reverse digest like GOST-engine")
+}
+
+// go test -timeout 30s -run ^TestNewSignerFromKey$ github.com/LdDl/esia-potato/cms
+func TestNewSignerFromKey(t *testing.T) {
+	prv := createTestPrivateKey(t)
+	certDER := gosttest.SelfSigned(t, prv, "Test Signer", 1)
+	clock := WithClock(func() time.Time { return time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC) })
+
+	direct, err := NewSigner(prv, certDER, clock, WithRand(mathrand.New(mathrand.NewSource(1))))
+	require.NoError(t, err)
+	assert.Equal(t, prv, direct.PrivateKey)
+
+	keySigner, err := NewKeySigner(prv)
+	require.NoError(t, err)
+	remote := &remoteKey{signer: keySigner}
+	viaKey, err := NewSignerFromKey(remote, certDER, clock, WithRand(mathrand.New(mathrand.NewSource(1))))
+	require.NoError(t, err)
+	assert.Nil(t, viaKey.PrivateKey)
+
+	message := []byte("test message")
+	expected, err := direct.Sign(message)
+	require.NoError(t, err)
+	cmsDER, err := viaKey.Sign(message)
+	require.NoError(t, err)
+	assert.Equal(t, expected, cmsDER, "Signing through crypto.Signer should give identical SignedData")
+	assert.Equal(t, 1, remote.calls)
+
+	_, err = Verify(cmsDER, message)
+	assert.NoError(t, err)
+
+	// Non-GOST key
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, err = NewSignerFromKey(ecKey, certDER)
+	assert.ErrorIs(t, err, ErrUnsupportedKey)
+
+	// Key of another certificate
+	otherKey, err := NewKeySigner(createTestPrivateKey(t))
+	require.NoError(t, err)
+	_, err = NewSignerFromKey(otherKey, certDER, clock)
+	assert.ErrorIs(t, err, ErrKeyMismatch)
+	_, err = NewSigner(createTestPrivateKey(t), certDER, clock)
+	assert.ErrorIs(t, err, ErrKeyMismatch)
+
+	// Key of the same size on another curve
+	key512, err := NewKeySigner(gosttest.PrivateKey512(t))
+	require.NoError(t, err)
+	_, err = NewSignerFromKey(key512, certDER, clock)
+	assert.ErrorIs(t, err, ErrKeyMismatch)
+}
@@ -0,0 +1,124 @@
+package cms
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"io"
+	"time"
+
+	"github.com/LdDl/esia-potato/gostx509"
+	"github.com/pkg/errors"
+)
+
+// Option configures NewSigner and Verify
+type Option func(*options)
+
+type options struct {
+	trustStore  *gostx509.TrustStore
+	revocation  gostx509.RevocationChecker
+	profile     Profile
+	timestamper Timestamper
+	contentType asn1.ObjectIdentifier
+	now         func() time.Time
+	rand        io.Reader
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		now:  time.Now,
+		rand: rand.Reader,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithTrustStore enables certificate chain validation against trust store:
+// NewSigner checks the signer certificate, Verify checks certificate of every signer
+// (certificates embedded into SignedData may be used as intermediates)
+func WithTrustStore(ts *gostx509.TrustStore) Option {
+	return func(o *options) {
+		o.trustStore = ts
+	}
+}
+
+// WithRevocationChecker enables revocation checks (CRL, OCSP) of every certificate
+// of validated chain except the root. Requires WithTrustStore
+func WithRevocationChecker(checker gostx509.RevocationChecker) Option {
+	return func(o *options) {
+		o.revocation = checker
+	}
+}
+
+// WithProfile selects signature profile. NewSigner adds signed attributes of the profile,
+// Verify requires them (with ProfileCAdESBES every signer must have signingCertificateV2)
+func WithProfile(profile Profile) Option {
+	return func(o *options) {
+		o.profile = profile
+	}
+}
+
+// WithTimestamper makes NewSigner embed timestamp of every signature value
+// as signatureTimeStampToken unsigned attribute (CAdES-T)
+func WithTimestamper(ts Timestamper) Option {
+	return func(o *options) {
+		o.timestamper = ts
+	}
+}
+
+// WithContentType sets eContentType and contentType attribute of signatures made by NewSigner
+// (id-data by default), e.g. id-ct-TSTInfo for timestamp tokens
+func WithContentType(oid asn1.ObjectIdentifier) Option {
+	return func(o *options) {
+		o.contentType = oid
+	}
+}
+
+// WithClock sets clock used for signingTime attribute and certificate chain validation
+// (time.Now by default). For Verify it sets the time chains are validated at.
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+// WithRand sets source of randomness for GOST signatures (crypto/rand.Reader by default).
+// Together with WithClock it makes signatures reproducible, which is useful for golden-file tests only.
+func WithRand(r io.Reader) Option {
+	return func(o *options) {
+		o.rand = r
+	}
+}
+
+// Key usages allowing certificate to be used for CMS signatures
+const signingKeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment
+
+// verifyChain validates chain of signer certificate if trust store is configured.
+// It returns nil chain if validation is disabled.
+func (o *options) verifyChain(cert *gostx509.Certificate, now time.Time, extra []*gostx509.Certificate) ([]*gostx509.Certificate, error) {
+	if o.trustStore == nil {
+		if o.revocation != nil {
+			return nil, ErrTrustStoreRequired
+		}
+		return nil, nil
+	}
+	chain, err := o.trustStore.Verify(cert, gostx509.VerifyOptions{
+		CurrentTime: now,
+		KeyUsage:    signingKeyUsage,
+	}, extra...)
+	if err != nil {
+		return nil, errors.Wrap(ErrCertificateChain, err.Error())
+	}
+
+	if o.revocation != nil {
+		for i := 0; i < len(chain)-1; i++ {
+			if err := o.revocation.CheckRevocation(context.Background(), chain[i], chain[i+1]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return chain, nil
+}
@@ -0,0 +1,387 @@
+package cms
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"fmt"
+	"time"
+
+	"github.com/LdDl/esia-potato/gostx509"
+	"github.com/LdDl/esia-potato/utils"
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/pkg/errors"
+)
+
+// Sentinel errors for verification
+var (
+	ErrNotSignedData             = fmt.Errorf("content type is not SignedData")
+	ErrNoSigners                 = fmt.Errorf("SignedData has no signers")
+	ErrSignerCertificateNotFound = fmt.Errorf("signer certificate not found")
+	ErrUnsupportedAlgorithm      = fmt.Errorf("unsupported algorithm")
+	ErrContentMissing            = fmt.Errorf("content is required for detached signature")
+	ErrContentMismatch           = fmt.Errorf("content does not match encapsulated content")
+	ErrContentTypeMismatch       = fmt.Errorf("contentType attribute mismatch")
+	ErrMessageDigestMismatch     = fmt.Errorf("messageDigest attribute mismatch")
+	ErrMessageDigestMissing      = fmt.Errorf("messageDigest attribute missing")
+	ErrSignatureInvalid          = fmt.Errorf("signature is invalid")
+)
+
+// VerifyResult holds information about a successfully verified signer
+type VerifyResult struct {
+	// DER-encoded signer certificate
+	Certificate []byte
+	// Value of signingTime attribute (zero if attribute is absent)
+	SigningTime time.Time
+	// Signed content: encapsulated content for attached signatures, provided content otherwise
+	Content []byte
+	// Whether signature is detached (eContent omitted)
+	Detached bool
+	// Validated certificate chain of signer (only with WithTrustStore option)
+	Chain []*gostx509.Certificate
+	// Whether signer has signingCertificateV2 attribute (CAdES-BES). The attribute is checked against signer certificate
+	SigningCertificateV2 bool
+	// eContentType of SignedData
+	ContentType asn1.ObjectIdentifier
+	// Signature value of signer
+	Signature []byte
+	// DER-encoded signatureTimeStampToken unsigned attribute (CAdES-T), nil if absent.
+	// It is not verified here, see tsp.VerifyTimestamp
+	TimestampToken []byte
+	// Verified countersignatures of signer. Their Content is the countersigned signature value
+	Countersignatures []*VerifyResult
+}
+
+// Verify parses DER-encoded ContentInfo with SignedData and verifies every signer.
+// For detached signatures content must be provided. For attached signatures content may be nil,
+// otherwise it must be equal to the encapsulated one.
+// Signer certificates must be embedded into SignedData. Result describes the first signer,
+// use VerifyAll to get every signer of co-signed data.
+// With WithTrustStore option certificate chain of every signer is validated too
+// (and checked for revocation with WithRevocationChecker).
+func Verify(signature, content []byte, opts ...Option) (*VerifyResult, error) {
+	results, err := VerifyAll(signature, content, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+// VerifyAll is like Verify but returns results of every signer in order of SignerInfos
+func VerifyAll(signature, content []byte, opts ...Option) ([]*VerifyResult, error) {
+	o := newOptions(opts)
+
+	signedData, err := parseSignedData(signature)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(signedData.SignerInfos) == 0 {
+		return nil, ErrNoSigners
+	}
+
+	eContent, detached, err := encapsulatedContent(&signedData.EncapContentInfo)
+	if err != nil {
+		return nil, err
+	}
+	if detached && content == nil {
+		return nil, ErrContentMissing
+	}
+	if !detached {
+		if content != nil && !bytes.Equal(content, eContent) {
+			return nil, ErrContentMismatch
+		}
+		content = eContent
+	}
+
+	certs, err := parseCertificates(signedData.Certificates.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*VerifyResult, 0, len(signedData.SignerInfos))
+	for i := range signedData.SignerInfos {
+		result, err := o.verifySigner(&signedData.SignerInfos[i], signedData.EncapContentInfo.EContentType, content, certs)
+		if err != nil {
+			return nil, errors.Wrapf(err, "signer %d", i)
+		}
+		result.Content = content
+		result.Detached = detached
+		result.ContentType = signedData.EncapContentInfo.EContentType
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// verifySigner verifies SignerInfo with its countersignatures and applies profile and chain checks of options
+func (o *options) verifySigner(si *SignerInfo, eContentType asn1.ObjectIdentifier, content []byte, certs []*gostx509.Certificate) (*VerifyResult, error) {
+	result, signerCert, err := verifySignerInfo(si, eContentType, content, certs)
+	if err != nil {
+		return nil, err
+	}
+	if o.profile == ProfileCAdESBES && !result.SigningCertificateV2 {
+		return nil, ErrSigningCertificateMissing
+	}
+	if result.Chain, err = o.verifyChain(signerCert, o.now(), certs); err != nil {
+		return nil, err
+	}
+	if result.Countersignatures, err = o.verifyCountersignatures(si, certs); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// parseSignedData unwraps ContentInfo and parses SignedData
+func parseSignedData(der []byte) (*SignedData, error) {
+	var contentInfo ContentInfo
+	rest, err := asn1.Unmarshal(der, &contentInfo)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse ContentInfo")
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("trailing data after ContentInfo")
+	}
+	if !contentInfo.ContentType.Equal(OIDSignedData) {
+		return nil, errors.Wrapf(ErrNotSignedData, "got %s", contentInfo.ContentType)
+	}
+
+	var signedData SignedData
+	if _, err := asn1.Unmarshal(contentInfo.Content.Bytes, &signedData); err != nil {
+		return nil, errors.Wrap(err, "failed to parse SignedData")
+	}
+	return &signedData, nil
+}
+
+// encapsulatedContent returns eContent OCTET STRING value and whether it is omitted (detached mode)
+func encapsulatedContent(eci *EncapsulatedContentInfo) ([]byte, bool, error) {
+	if len(eci.EContent.FullBytes) == 0 {
+		return nil, true, nil
+	}
+	// EContent holds explicit [0] wrapper around OCTET STRING
+	var eContent []byte
+	if _, err := asn1.Unmarshal(eci.EContent.Bytes, &eContent); err != nil {
+		return nil, false, errors.Wrap(err, "failed to parse eContent")
+	}
+	if eContent == nil {
+		eContent = []byte{}
+	}
+	return eContent, false, nil
+}
+
+// parseCertificates splits content of CertificateSet into separate certificates
+func parseCertificates(data []byte) ([]*gostx509.Certificate, error) {
+	var certs []*gostx509.Certificate
+	for len(data) > 0 {
+		var raw asn1.RawValue
+		rest, err := asn1.Unmarshal(data, &raw)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse certificates")
+		}
+		data = rest
+
+		// Skip other certificate choices (attribute certificates etc.)
+		if raw.Class != asn1.ClassUniversal || raw.Tag != asn1.TagSequence {
+			continue
+		}
+
+		cert, err := gostx509.ParseCertificate(raw.FullBytes)
+		if err != nil {
+			return nil, errors.Wrap(ErrCertificateParse, err.Error())
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// findSignerCertificate looks up certificate by issuer and serial number
+func findSignerCertificate(certs []*gostx509.Certificate, sid IssuerAndSerial) (*gostx509.Certificate, error) {
+	for _, cert := range certs {
+		if cert.SerialNumber == nil || sid.SerialNumber == nil {
+			continue
+		}
+		if cert.SerialNumber.Cmp(sid.SerialNumber) == 0 && bytes.Equal(cert.RawIssuer, sid.Issuer.FullBytes) {
+			return cert, nil
+		}
+	}
+	return nil, errors.Wrapf(ErrSignerCertificateNotFound, "serial %s", sid.SerialNumber)
+}
+
+// algorithmForDigestOID picks algorithms by digest algorithm OID
+func algorithmForDigestOID(oid asn1.ObjectIdentifier) (algorithm, error) {
+	switch {
+	case oid.Equal(OIDGostR341112256):
+		return algorithm256, nil
+	case oid.Equal(OIDGostR341112512):
+		return algorithm512, nil
+	default:
+		return algorithm{}, errors.Wrapf(ErrUnsupportedAlgorithm, "digest %s", oid)
+	}
+}
+
+// checkSignatureAlgorithm ensures signature algorithm OID is compatible with digest algorithm
+func checkSignatureAlgorithm(alg algorithm, oid asn1.ObjectIdentifier) error {
+	switch {
+	case alg.digestOID.Equal(OIDGostR341112256) && (oid.Equal(OIDGostR341012256) || oid.Equal(OIDGostR341012256WithGostR341112256)):
+		return nil
+	case alg.digestOID.Equal(OIDGostR341112512) && (oid.Equal(OIDGostR341012512) || oid.Equal(OIDGostR341012512WithGostR341112512)):
+		return nil
+	default:
+		return errors.Wrapf(ErrUnsupportedAlgorithm, "signature %s with digest %s", oid, alg.digestOID)
+	}
+}
+
+// CertificatePublicKey extracts GOST public key from DER-encoded certificate.
+// It returns the key and OID of its parameter set (curve).
+func CertificatePublicKey(certDER []byte) (*gost3410.PublicKey, string, error) {
+	cert, err := gostx509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, "", errors.Wrap(ErrCertificateParse, err.Error())
+	}
+	pub, err := cert.PublicKey()
+	if err != nil {
+		return nil, "", err
+	}
+	return pub, cert.CurveOID, nil
+}
+
+// parseAttributes parses DER-encoded SET OF Attribute
+func parseAttributes(der []byte) ([]Attribute, error) {
+	var attrs []Attribute
+	rest, err := asn1.UnmarshalWithParams(der, &attrs, "set")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse attributes")
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("trailing data after attributes")
+	}
+	return attrs, nil
+}
+
+// findAttribute returns the first value of attribute with given type
+func findAttribute(attrs []Attribute, oid asn1.ObjectIdentifier) ([]byte, bool) {
+	for _, attr := range attrs {
+		if attr.Type.Equal(oid) {
+			return attr.Values.Bytes, true
+		}
+	}
+	return nil, false
+}
+
+// unsignedAttributes parses unsigned attributes of SignerInfo (nil if absent)
+func unsignedAttributes(si *SignerInfo) ([]Attribute, error) {
+	if len(si.UnsignedAttrs.FullBytes) == 0 {
+		return nil, nil
+	}
+	// Re-encode IMPLICIT [1] as SET
+	der := make([]byte, len(si.UnsignedAttrs.FullBytes))
+	copy(der, si.UnsignedAttrs.FullBytes)
+	der[0] = 0x31
+	return parseAttributes(der)
+}
+
+// verifySignerInfo checks signed attributes and signature of a single signer.
+// It returns signer certificate along with result.
+func verifySignerInfo(si *SignerInfo, eContentType asn1.ObjectIdentifier, content []byte, certs []*gostx509.Certificate) (*VerifyResult, *gostx509.Certificate, error) {
+	alg, err := algorithmForDigestOID(si.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkSignatureAlgorithm(alg, si.SignatureAlgorithm.Algorithm); err != nil {
+		return nil, nil, err
+	}
+
+	signerCert, err := findSignerCertificate(certs, si.IssuerAndSerial)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pub, err := signerCert.PublicKey()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	h := alg.newHash()
+	if _, err := h.Write(content); err != nil {
+		return nil, nil, errors.Wrap(err, "failed to hash content")
+	}
+	contentDigest := h.Sum(nil)
+
+	result := &VerifyResult{
+		Certificate: signerCert.Raw,
+		Signature:   si.Signature,
+	}
+
+	// Without signed attributes the content digest itself is signed
+	signedDigest := contentDigest
+	if len(si.SignedAttrs.FullBytes) > 0 {
+		// Signature covers signedAttrs re-encoded with SET tag instead of IMPLICIT [0]
+		attrsForVerify := make([]byte, len(si.SignedAttrs.FullBytes))
+		copy(attrsForVerify, si.SignedAttrs.FullBytes)
+		attrsForVerify[0] = 0x31
+
+		attrs, err := parseAttributes(attrsForVerify)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if value, ok := findAttribute(attrs, OIDAttributeContentType); ok {
+			var contentType asn1.ObjectIdentifier
+			if _, err := asn1.Unmarshal(value, &contentType); err != nil {
+				return nil, nil, errors.Wrap(err, "failed to parse contentType attribute")
+			}
+			if !contentType.Equal(eContentType) {
+				return nil, nil, errors.Wrapf(ErrContentTypeMismatch, "attribute %s, eContentType %s", contentType, eContentType)
+			}
+		}
+
+		value, ok := findAttribute(attrs, OIDAttributeMessageDigest)
+		if !ok {
+			return nil, nil, ErrMessageDigestMissing
+		}
+		var messageDigest []byte
+		if _, err := asn1.Unmarshal(value, &messageDigest); err != nil {
+			return nil, nil, errors.Wrap(err, "failed to parse messageDigest attribute")
+		}
+		if !bytes.Equal(messageDigest, contentDigest) {
+			return nil, nil, ErrMessageDigestMismatch
+		}
+
+		if value, ok := findAttribute(attrs, OIDAttributeSigningTime); ok {
+			if _, err := asn1.Unmarshal(value, &result.SigningTime); err != nil {
+				return nil, nil, errors.Wrap(err, "failed to parse signingTime attribute")
+			}
+		}
+
+		if value, ok := findAttribute(attrs, OIDAttributeSigningCertificateV2); ok {
+			if err := checkSigningCertificateV2(value, signerCert); err != nil {
+				return nil, nil, err
+			}
+			result.SigningCertificateV2 = true
+		}
+
+		h = alg.newHash()
+		if _, err := h.Write(attrsForVerify); err != nil {
+			return nil, nil, errors.Wrap(err, "failed to hash attributes")
+		}
+		signedDigest = h.Sum(nil)
+	}
+
+	unsignedAttrs, err := unsignedAttributes(si)
+	if err != nil {
+		return nil, nil, err
+	}
+	if value, ok := findAttribute(unsignedAttrs, OIDAttributeSignatureTimeStampToken); ok {
+		result.TimestampToken = value
+	}
+
+	// Sign reverses the digest before signing (GOST-engine compatibility), so do the same here
+	valid, err := pub.VerifyDigest(utils.ReverseBytes(signedDigest), si.Signature)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to verify signature")
+	}
+	if !valid {
+		return nil, nil, ErrSignatureInvalid
+	}
+
+	return result, signerCert, nil
+}
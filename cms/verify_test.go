@@ -0,0 +1,220 @@
+package cms
+
+import (
+	"context"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"testing"
+	"time"
+
+	"github.com/LdDl/esia-potato/gostx509"
+	"github.com/LdDl/esia-potato/internal/gosttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createTestSigner(t *testing.T) *Signer {
+	prv := createTestPrivateKey(t)
+	signer, err := NewSigner(prv, gosttest.SelfSigned(t, prv, "Test Signer", 1))
+	require.NoError(t, err, "NewSigner failed")
+	require.Equal(t, "Test Signer", signer.ParsedCertificate().Subject.CommonName)
+	return signer
+}
+
+// go test -timeout 30s -run ^TestVerify$ github.com/LdDl/esia-potato/cms
+func TestVerify(t *testing.T) {
+	signer := createTestSigner(t)
+
+	message := []byte("openid2025.01.01 12:00:00 +0000CLIENT_ID12345")
+	before := time.Now().UTC().Truncate(time.Second)
+	cmsDER, err := signer.Sign(message)
+	require.NoError(t, err, "Sign failed")
+
+	result, err := Verify(cmsDER, message)
+	require.NoError(t, err, "Verify failed")
+	assert.Equal(t, signer.Certificate, result.Certificate, "Signer certificate mismatch")
+	assert.False(t, result.SigningTime.Before(before), "Signing time should not be before signing")
+	assert.WithinDuration(t, time.Now(), result.SigningTime, time.Minute)
+}
+
+// go test -timeout 30s -run ^TestVerify512$ github.com/LdDl/esia-potato/cms
+func TestVerify512(t *testing.T) {
+	prv := gosttest.PrivateKey512(t)
+
+	signer, err := NewSigner(prv, gosttest.SelfSigned(t, prv, "Test Signer 512", 2))
+	require.NoError(t, err)
+
+	message := []byte("test message")
+	cmsDER, err := signer.Sign(message)
+	require.NoError(t, err)
+
+	_, err = Verify(cmsDER, message)
+	assert.NoError(t, err, "Verify failed for 512-bit key")
+}
+
+// go test -timeout 30s -run ^TestVerifyFailures$ github.com/LdDl/esia-potato/cms
+func TestVerifyFailures(t *testing.T) {
+	signer := createTestSigner(t)
+
+	message := []byte("test message")
+	cmsDER, err := signer.Sign(message)
+	require.NoError(t, err)
+
+	_, err = Verify(cmsDER, []byte("other message"))
+	assert.ErrorIs(t, err, ErrMessageDigestMismatch)
+
+	_, err = Verify(cmsDER, nil)
+	assert.ErrorIs(t, err, ErrContentMissing)
+
+	_, err = Verify([]byte{0x30, 0x00}, message)
+	assert.Error(t, err)
+
+	// Certificate of another key with the same issuer and serial
+	// (NewSigner refuses such pair, so key is replaced in place)
+	other := createTestSigner(t)
+	_, err = NewSigner(signer.PrivateKey, other.Certificate)
+	assert.ErrorIs(t, err, ErrKeyMismatch)
+	forged := *other
+	forged.Key = signer.Key
+	forgedDER, err := forged.Sign(message)
+	require.NoError(t, err)
+	_, err = Verify(forgedDER, message)
+	assert.ErrorIs(t, err, ErrSignatureInvalid)
+
+	// Certificate without matching issuer and serial
+	unknownCert, err := gostx509.ParseCertificate(createTestCertDER())
+	require.NoError(t, err)
+	unknown := *signer
+	unknown.Certificate, unknown.certParsed = unknownCert.Raw, unknownCert
+	unknownDER, err := unknown.Sign(message)
+	require.NoError(t, err)
+	_, err = Verify(unknownDER, message)
+	assert.Error(t, err)
+}
+
+// go test -timeout 30s -run ^TestVerifyAttached$ github.com/LdDl/esia-potato/cms
+func TestVerifyAttached(t *testing.T) {
+	signer := createTestSigner(t)
+
+	message := []byte("document with embedded content")
+	cmsDER, err := signer.SignAttached(message)
+	require.NoError(t, err, "SignAttached failed")
+
+	detachedDER, err := signer.Sign(message)
+	require.NoError(t, err, "Sign failed")
+	assert.Greater(t, len(cmsDER), len(detachedDER), "Attached signature should carry content")
+
+	result, err := Verify(cmsDER, nil)
+	require.NoError(t, err, "Verify without external content failed")
+	assert.Equal(t, message, result.Content)
+	assert.False(t, result.Detached)
+
+	result, err = Verify(cmsDER, message)
+	require.NoError(t, err, "Verify with matching content failed")
+	assert.Equal(t, message, result.Content)
+
+	_, err = Verify(cmsDER, []byte("other content"))
+	assert.ErrorIs(t, err, ErrContentMismatch)
+
+	result, err = Verify(detachedDER, message)
+	require.NoError(t, err)
+	assert.True(t, result.Detached)
+
+	emptyDER, err := signer.SignAttached([]byte{})
+	require.NoError(t, err)
+	result, err = Verify(emptyDER, nil)
+	require.NoError(t, err, "Verify of empty attached content failed")
+	assert.Empty(t, result.Content)
+}
+
+// go test -timeout 30s -run ^TestCertificatePublicKey$ github.com/LdDl/esia-potato/cms
+func TestCertificatePublicKey(t *testing.T) {
+	signer := createTestSigner(t)
+
+	pub, curveOID, err := CertificatePublicKey(signer.Certificate)
+	require.NoError(t, err)
+	assert.Equal(t, "1.2.643.2.2.35.1", curveOID)
+
+	expected, err := signer.PrivateKey.PublicKey()
+	require.NoError(t, err)
+	assert.Equal(t, expected.Raw(), pub.Raw())
+
+	_, _, err = CertificatePublicKey([]byte{0x01})
+	assert.ErrorIs(t, err, ErrCertificateParse)
+}
+
+// go test -timeout 30s -run ^TestTrustStore$ github.com/LdDl/esia-potato/cms
+func TestTrustStore(t *testing.T) {
+	signer := createTestSigner(t)
+	assert.Empty(t, signer.Chain(), "Chain should be empty without trust store")
+
+	cert, err := gostx509.ParseCertificate(signer.Certificate)
+	require.NoError(t, err)
+	trusted := gostx509.NewTrustStore()
+	trusted.AddCert(cert)
+
+	// Self-signed test certificate is trusted directly
+	withChain, err := NewSigner(signer.PrivateKey, signer.Certificate, WithTrustStore(trusted))
+	require.NoError(t, err)
+	require.Len(t, withChain.Chain(), 1)
+
+	_, err = NewSigner(signer.PrivateKey, signer.Certificate, WithTrustStore(gostx509.NewTrustStore()))
+	assert.ErrorIs(t, err, ErrCertificateChain)
+
+	message := []byte("test message")
+	cmsDER, err := signer.Sign(message)
+	require.NoError(t, err)
+
+	result, err := Verify(cmsDER, message, WithTrustStore(trusted))
+	require.NoError(t, err)
+	require.Len(t, result.Chain, 1)
+	assert.Equal(t, signer.Certificate, result.Chain[0].Raw)
+
+	_, err = Verify(cmsDER, message, WithTrustStore(gostx509.NewTrustStore()))
+	assert.ErrorIs(t, err, ErrCertificateChain)
+}
+
+// revokedSerials is RevocationChecker treating listed serial numbers as revoked
+type revokedSerials map[int64]bool
+
+func (r revokedSerials) CheckRevocation(_ context.Context, cert, _ *gostx509.Certificate) error {
+	if r[cert.SerialNumber.Int64()] {
+		return gostx509.ErrRevoked
+	}
+	return nil
+}
+
+// go test -timeout 30s -run ^TestRevocation$ github.com/LdDl/esia-potato/cms
+func TestRevocation(t *testing.T) {
+	caExt, err := asn1.Marshal(struct {
+		IsCA bool `asn1:"optional"`
+	}{IsCA: true})
+	require.NoError(t, err)
+	ca := gosttest.NewCA(t, "Test CA", pkix.Extension{Id: gostx509.OIDExtensionBasicConstraints, Critical: true, Value: caExt})
+	caCert, err := gostx509.ParseCertificate(ca.DER)
+	require.NoError(t, err)
+	trusted := gostx509.NewTrustStore()
+	trusted.AddCert(caCert)
+
+	prv := createTestPrivateKey(t)
+	certDER := ca.Issue(t, 2, "Test Signer", prv)
+
+	_, err = NewSigner(prv, certDER, WithRevocationChecker(revokedSerials{}))
+	assert.ErrorIs(t, err, ErrTrustStoreRequired)
+
+	signer, err := NewSigner(prv, certDER, WithTrustStore(trusted), WithRevocationChecker(revokedSerials{}))
+	require.NoError(t, err)
+	require.Len(t, signer.Chain(), 2)
+
+	_, err = NewSigner(prv, certDER, WithTrustStore(trusted), WithRevocationChecker(revokedSerials{2: true}))
+	assert.ErrorIs(t, err, gostx509.ErrRevoked)
+
+	message := []byte("test message")
+	cmsDER, err := signer.Sign(message)
+	require.NoError(t, err)
+
+	_, err = Verify(cmsDER, message, WithTrustStore(trusted), WithRevocationChecker(revokedSerials{}))
+	assert.NoError(t, err)
+	_, err = Verify(cmsDER, message, WithTrustStore(trusted), WithRevocationChecker(revokedSerials{2: true}))
+	assert.ErrorIs(t, err, gostx509.ErrRevoked)
+}
@@ -8,6 +8,7 @@ import (
 	"math/big"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"github.com/LdDl/esia-potato/utils"
 	"github.com/ddulesov/gogost/gost28147"
@@ -18,10 +19,12 @@ import (
 
 // Sentinel errors
 var (
-	ErrCurveOIDNotFound    = fmt.Errorf("could not find curve OID in header.key")
-	ErrCurveOIDUnknown     = fmt.Errorf("unknown curve OID")
-	ErrFingerprintMismatch = fmt.Errorf("fingerprint mismatch (wrong password?)")
-	ErrModInverseFailed    = fmt.Errorf("failed to calculate modular inverse")
+	ErrCurveOIDNotFound     = fmt.Errorf("could not find curve OID in header.key")
+	ErrCurveOIDUnknown      = fmt.Errorf("unknown curve OID")
+	ErrFingerprintMismatch  = fmt.Errorf("fingerprint mismatch (wrong password?)")
+	ErrModInverseFailed     = fmt.Errorf("failed to calculate modular inverse")
+	ErrKeySlotUnknown       = fmt.Errorf("unknown key slot")
+	ErrSecondaryKeyNotFound = fmt.Errorf("secondary key not found in container")
 )
 
 // CurveOID maps OID strings to gogost curves
@@ -38,6 +41,19 @@ var CurveOID = map[string]*gost3410.Curve{
 	"1.2.643.2.2.36.0": gost3410.CurveIdGostR34102001CryptoProXchAParamSet(),
 	// CryptoPro XchB
 	"1.2.643.2.2.36.1": gost3410.CurveIdGostR34102001CryptoProXchBParamSet(),
+	// GOST 2012 512-bit A
+	"1.2.643.7.1.2.1.2.1": gost3410.CurveIdtc26gost341012512paramSetA(),
+	// GOST 2012 512-bit B
+	"1.2.643.7.1.2.1.2.2": gost3410.CurveIdtc26gost341012512paramSetB(),
+	// GOST 2012 512-bit C
+	"1.2.643.7.1.2.1.2.3": gost3410.CurveIdtc26gost34102012512paramSetC(),
+}
+
+// curves512 lists curve OIDs of GOST R 34.10-2012 512-bit keys
+var curves512 = map[string]bool{
+	"1.2.643.7.1.2.1.2.1": true,
+	"1.2.643.7.1.2.1.2.2": true,
+	"1.2.643.7.1.2.1.2.3": true,
 }
 
 // OID patterns to search in header.key
@@ -53,9 +69,31 @@ var oidPatterns = map[string][]byte{
 	// CryptoPro XchB
 	"1.2.643.2.2.36.1": {0x06, 0x07, 0x2a, 0x85, 0x03, 0x02, 0x02, 0x24, 0x01},
 	// GOST 2012-256-A
-	"1.2.643.7.1.2.1.1.1": {0x06, 0x08, 0x2a, 0x85, 0x03, 0x07, 0x01, 0x02, 0x01, 0x01, 0x01},
+	"1.2.643.7.1.2.1.1.1": {0x06, 0x09, 0x2a, 0x85, 0x03, 0x07, 0x01, 0x02, 0x01, 0x01, 0x01},
 	// GOST 2012-256-B
-	"1.2.643.7.1.2.1.1.2": {0x06, 0x08, 0x2a, 0x85, 0x03, 0x07, 0x01, 0x02, 0x01, 0x01, 0x02},
+	"1.2.643.7.1.2.1.1.2": {0x06, 0x09, 0x2a, 0x85, 0x03, 0x07, 0x01, 0x02, 0x01, 0x01, 0x02},
+	// GOST 2012-512-A
+	"1.2.643.7.1.2.1.2.1": {0x06, 0x09, 0x2a, 0x85, 0x03, 0x07, 0x01, 0x02, 0x01, 0x02, 0x01},
+	// GOST 2012-512-B
+	"1.2.643.7.1.2.1.2.2": {0x06, 0x09, 0x2a, 0x85, 0x03, 0x07, 0x01, 0x02, 0x01, 0x02, 0x02},
+	// GOST 2012-512-C
+	"1.2.643.7.1.2.1.2.3": {0x06, 0x09, 0x2a, 0x85, 0x03, 0x07, 0x01, 0x02, 0x01, 0x02, 0x03},
+}
+
+// KeyMode returns gogost mode for keys on the curve with given OID
+func KeyMode(oid string) gost3410.Mode {
+	if curves512[oid] {
+		return gost3410.Mode2012
+	}
+	return gost3410.Mode2001
+}
+
+// KeySize returns private key length in bytes for keys on the curve with given OID
+func KeySize(oid string) int {
+	if curves512[oid] {
+		return 64
+	}
+	return 32
 }
 
 // KeyData contains extracted key information
@@ -116,33 +154,85 @@ func OpenContainer(path string) (*Container, error) {
 	}, nil
 }
 
+// KeySlot selects one of the two key pairs stored in a container
+type KeySlot int
+
+const (
+	// PrimarySlot is the masks.key / primary.key pair
+	PrimarySlot KeySlot = iota
+	// SecondarySlot is the masks2.key / primary2.key pair (usually the exchange key)
+	SecondarySlot
+)
+
+// keySlotFiles describes where a slot lives inside the container
+type keySlotFiles struct {
+	masks   string
+	primary string
+	// tag of the fingerprint in header.key
+	fingerprintTag byte
+}
+
+var slotFiles = map[KeySlot]keySlotFiles{
+	PrimarySlot:   {masks: "masks.key", primary: "primary.key", fingerprintTag: 0x8a},
+	SecondarySlot: {masks: "masks2.key", primary: "primary2.key", fingerprintTag: 0x8b},
+}
+
 // ExtractKey extracts the private key using the provided password
 func (c *Container) ExtractKey(password string) (*KeyData, error) {
-	// Read masks.key
-	masksPath := filepath.Join(c.Path, "masks.key")
-	masksData, err := os.ReadFile(masksPath)
+	return c.ExtractKeySlot(password, PrimarySlot)
+}
+
+// ExtractSecondaryKey extracts the secondary private key (masks2.key / primary2.key) using the provided password
+func (c *Container) ExtractSecondaryKey(password string) (*KeyData, error) {
+	return c.ExtractKeySlot(password, SecondarySlot)
+}
+
+// HasSecondaryKey reports whether the container holds the secondary key pair
+func (c *Container) HasSecondaryKey() bool {
+	files := slotFiles[SecondarySlot]
+	if _, err := os.Stat(filepath.Join(c.Path, files.masks)); err != nil {
+		return false
+	}
+	if _, err := os.Stat(filepath.Join(c.Path, files.primary)); err != nil {
+		return false
+	}
+	return true
+}
+
+// ExtractKeySlot extracts the private key stored in the given slot using the provided password
+func (c *Container) ExtractKeySlot(password string, slot KeySlot) (*KeyData, error) {
+	files, ok := slotFiles[slot]
+	if !ok {
+		return nil, errors.Wrapf(ErrKeySlotUnknown, "slot: %d", slot)
+	}
+
+	if slot == SecondarySlot && !c.HasSecondaryKey() {
+		return nil, ErrSecondaryKeyNotFound
+	}
+
+	// Read masks file
+	masksData, err := os.ReadFile(filepath.Join(c.Path, files.masks))
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to read masks.key")
+		return nil, errors.Wrapf(err, "failed to read %s", files.masks)
 	}
 
-	// Read primary.key
-	primaryPath := filepath.Join(c.Path, "primary.key")
-	primaryKeyData, err := os.ReadFile(primaryPath)
+	// Read primary file
+	primaryKeyData, err := os.ReadFile(filepath.Join(c.Path, files.primary))
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to read primary.key")
+		return nil, errors.Wrapf(err, "failed to read %s", files.primary)
 	}
 
 	// Parse ASN.1 structures
 	var mask maskData
 	_, err = asn1.Unmarshal(masksData, &mask)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to parse masks.key")
+		return nil, errors.Wrapf(err, "failed to parse %s", files.masks)
 	}
 
 	var primary primaryData
 	_, err = asn1.Unmarshal(primaryKeyData, &primary)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to parse primary.key")
+		return nil, errors.Wrapf(err, "failed to parse %s", files.primary)
 	}
 
 	// Derive key from password using CPKDF
@@ -157,14 +247,56 @@ func (c *Container) ExtractKey(password string) (*KeyData, error) {
 	// Reverse the decrypted key (little-endian to big-endian)
 	utils.ReverseBytesInPlace(decrypted)
 
+	expectedFP := findFingerprint(c.Header, files.fingerprintTag)
+
+	// Both key pairs may live on different curves (e.g. signature key on CryptoPro A
+	// and exchange key on XchA), so every curve mentioned in header.key is tried
+	// and the fingerprint decides. Container curve goes first.
+	var firstErr error
+	for _, oid := range c.candidateOIDs() {
+		keyData, err := unmaskKeyData(decrypted, mask.Mask, oid)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if expectedFP == nil || bytes.Equal(keyData.Fingerprint, expectedFP) {
+			return keyData, nil
+		}
+		if firstErr == nil {
+			firstErr = errors.Wrapf(ErrFingerprintMismatch, "expected %x, got %x", expectedFP, keyData.Fingerprint)
+		}
+	}
+	return nil, firstErr
+}
+
+// candidateOIDs returns curve OIDs to try: container curve first, then the rest found in header.key
+func (c *Container) candidateOIDs() []string {
+	oids := []string{c.OID}
+	for _, oid := range findCurveOIDs(c.Header) {
+		if oid != c.OID {
+			oids = append(oids, oid)
+		}
+	}
+	return oids
+}
+
+// unmaskKeyData unmasks decrypted key on the curve given by OID and derives its public key
+func unmaskKeyData(decrypted, mask []byte, oid string) (*KeyData, error) {
+	curve, ok := CurveOID[oid]
+	if !ok {
+		return nil, errors.Wrapf(ErrCurveOIDUnknown, "oid: %s", oid)
+	}
+
 	// Unmask the key
-	privateKey, err := unmaskKey(decrypted, mask.Mask, c.Curve)
+	privateKey, err := unmaskKey(decrypted, mask, curve, KeySize(oid))
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to unmask key")
 	}
 
 	// Calculate public key for verification
-	prv, err := gost3410.NewPrivateKey(c.Curve, gost3410.Mode2001, privateKey)
+	prv, err := gost3410.NewPrivateKey(curve, KeyMode(oid), privateKey)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create private key")
 	}
@@ -176,18 +308,11 @@ func (c *Container) ExtractKey(password string) (*KeyData, error) {
 
 	publicKey := pub.Raw()
 
-	// Verify fingerprint
-	expectedFP := findFingerprint(c.Header, 0x8a)
-	actualFP := publicKey[:8]
-	if expectedFP != nil && !bytes.Equal(actualFP, expectedFP) {
-		return nil, errors.Wrapf(ErrFingerprintMismatch, "expected %x, got %x", expectedFP, actualFP)
-	}
-
 	return &KeyData{
 		PrivateKey:  privateKey,
 		PublicKey:   publicKey,
-		CurveOID:    c.OID,
-		Fingerprint: actualFP,
+		CurveOID:    oid,
+		Fingerprint: publicKey[:8],
 	}, nil
 }
 
@@ -302,8 +427,8 @@ func gost28147ECBDecrypt(key, data []byte) []byte {
 	return result
 }
 
-// unmaskKey applies the mask to get the real private key
-func unmaskKey(encrypted, mask []byte, curve *gost3410.Curve) ([]byte, error) {
+// unmaskKey applies the mask to get the real private key of keySize bytes
+func unmaskKey(encrypted, mask []byte, curve *gost3410.Curve, keySize int) ([]byte, error) {
 	// Reverse mask
 	maskCopy := make([]byte, len(mask))
 	copy(maskCopy, mask)
@@ -324,10 +449,10 @@ func unmaskKey(encrypted, mask []byte, curve *gost3410.Curve) ([]byte, error) {
 
 	// Convert back to bytes and reverse
 	result := raw.Bytes()
-	// Pad to 32 bytes if needed
-	if len(result) < 32 {
-		padded := make([]byte, 32)
-		copy(padded[32-len(result):], result)
+	// Pad to key size if needed
+	if len(result) < keySize {
+		padded := make([]byte, keySize)
+		copy(padded[keySize-len(result):], result)
 		result = padded
 	}
 	utils.ReverseBytesInPlace(result)
@@ -337,12 +462,29 @@ func unmaskKey(encrypted, mask []byte, curve *gost3410.Curve) ([]byte, error) {
 
 // findCurveOID searches for a curve OID pattern in header data
 func findCurveOID(header []byte) string {
+	oids := findCurveOIDs(header)
+	if len(oids) == 0 {
+		return ""
+	}
+	return oids[0]
+}
+
+// findCurveOIDs returns all known curve OIDs found in header data ordered by position
+func findCurveOIDs(header []byte) []string {
+	positions := make(map[string]int)
 	for oid, pattern := range oidPatterns {
-		if bytes.Contains(header, pattern) {
-			return oid
+		if idx := bytes.Index(header, pattern); idx != -1 {
+			positions[oid] = idx
 		}
 	}
-	return ""
+	oids := make([]string, 0, len(positions))
+	for oid := range positions {
+		oids = append(oids, oid)
+	}
+	sort.Slice(oids, func(i, j int) bool {
+		return positions[oids[i]] < positions[oids[j]]
+	})
+	return oids
 }
 
 // findFingerprint finds fingerprint in header.key
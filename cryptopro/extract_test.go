@@ -1,8 +1,11 @@
 package cryptopro
 
 import (
+	"encoding/asn1"
 	"encoding/hex"
 	"math/big"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/LdDl/esia-potato/utils"
@@ -161,6 +164,9 @@ func TestCurveOIDMap(t *testing.T) {
 		"1.2.643.2.2.36.0",
 		"1.2.643.2.2.36.1",
 		"1.2.643.7.1.2.1.1.1",
+		"1.2.643.7.1.2.1.2.1",
+		"1.2.643.7.1.2.1.2.2",
+		"1.2.643.7.1.2.1.2.3",
 	}
 
 	for _, oid := range expectedOIDs {
@@ -168,3 +174,139 @@ func TestCurveOIDMap(t *testing.T) {
 		assert.NotNil(t, curve, "CurveOID[%s] should not be nil", oid)
 	}
 }
+
+// writeTestSlot masks and encrypts private key the way CryptoPro does and writes it into the container
+func writeTestSlot(t *testing.T, dir, masksName, primaryName string, curve *gost3410.Curve, prvKey, mask, salt []byte) {
+	derivedKey, err := cpkdf(nil, salt)
+	require.NoError(t, err)
+
+	// raw * mask mod q, stored little-endian
+	raw := new(big.Int).SetBytes(utils.ReverseBytes(prvKey))
+	m := new(big.Int).SetBytes(utils.ReverseBytes(mask))
+	masked := new(big.Int).Mul(raw, m)
+	masked.Mod(masked, curve.Q)
+	plain := make([]byte, len(prvKey))
+	maskedBytes := masked.Bytes()
+	copy(plain[len(plain)-len(maskedBytes):], maskedBytes)
+	utils.ReverseBytesInPlace(plain)
+
+	encrypted := make([]byte, len(plain))
+	gost28147.NewCipher(derivedKey, &gost28147.SboxIdtc26gost28147paramZ).NewECBEncrypter().CryptBlocks(encrypted, plain)
+
+	masksDER, err := asn1.Marshal(maskData{Mask: mask, Salt: salt, HMAC: make([]byte, 4)})
+	require.NoError(t, err)
+	primaryDER, err := asn1.Marshal(primaryData{Value: encrypted})
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, masksName), masksDER, 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, primaryName), primaryDER, 0600))
+}
+
+func testPublicKey(t *testing.T, curve *gost3410.Curve, mode gost3410.Mode, prvKey []byte) []byte {
+	prv, err := gost3410.NewPrivateKey(curve, mode, prvKey)
+	require.NoError(t, err)
+	pub, err := prv.PublicKey()
+	require.NoError(t, err)
+	return pub.Raw()
+}
+
+// go test -timeout 30s -run ^TestExtractSecondaryKey$ github.com/LdDl/esia-potato/cryptopro
+func TestExtractSecondaryKey(t *testing.T) {
+	dir := t.TempDir()
+
+	primaryKey, err := hex.DecodeString("0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcd0f")
+	require.NoError(t, err)
+	secondaryKey, err := hex.DecodeString("fedcba9876543210fedcba9876543210fedcba9876543210fedcba987654320f")
+	require.NoError(t, err)
+	mask, err := hex.DecodeString("1111111111111111222222222222222233333333333333334444444444444404")
+	require.NoError(t, err)
+	salt, err := hex.DecodeString("aabbccdd11223344aabbccdd")
+	require.NoError(t, err)
+
+	curve := CurveOID["1.2.643.2.2.35.1"]
+	primaryPub := testPublicKey(t, curve, gost3410.Mode2001, primaryKey)
+	secondaryPub := testPublicKey(t, curve, gost3410.Mode2001, secondaryKey)
+
+	header := []byte{0x30, 0x82}
+	header = append(header, oidPatterns["1.2.643.2.2.35.1"]...)
+	header = append(header, 0x8a, 0x08)
+	header = append(header, primaryPub[:8]...)
+	header = append(header, 0x8b, 0x08)
+	header = append(header, secondaryPub[:8]...)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "header.key"), header, 0600))
+
+	writeTestSlot(t, dir, "masks.key", "primary.key", curve, primaryKey, mask, salt)
+
+	container, err := OpenContainer(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "1.2.643.2.2.35.1", container.OID)
+	assert.False(t, container.HasSecondaryKey())
+
+	_, err = container.ExtractSecondaryKey("")
+	assert.ErrorIs(t, err, ErrSecondaryKeyNotFound)
+
+	writeTestSlot(t, dir, "masks2.key", "primary2.key", curve, secondaryKey, mask, salt)
+	assert.True(t, container.HasSecondaryKey())
+
+	primaryData, err := container.ExtractKey("")
+	require.NoError(t, err)
+	assert.Equal(t, primaryKey, primaryData.PrivateKey)
+	assert.Equal(t, primaryPub, primaryData.PublicKey)
+
+	secondaryData, err := container.ExtractSecondaryKey("")
+	require.NoError(t, err)
+	assert.Equal(t, secondaryKey, secondaryData.PrivateKey)
+	assert.Equal(t, secondaryPub[:8], secondaryData.Fingerprint)
+	assert.Equal(t, "1.2.643.2.2.35.1", secondaryData.CurveOID)
+
+	_, err = container.ExtractKeySlot("", KeySlot(42))
+	assert.ErrorIs(t, err, ErrKeySlotUnknown)
+}
+
+// go test -timeout 30s -run ^TestOIDPatterns$ github.com/LdDl/esia-potato/cryptopro
+func TestOIDPatterns(t *testing.T) {
+	for oidStr, pattern := range oidPatterns {
+		var oid asn1.ObjectIdentifier
+		_, err := asn1.Unmarshal(pattern, &oid)
+		require.NoError(t, err, "pattern for %s should be valid DER", oidStr)
+		assert.Equal(t, oidStr, oid.String())
+	}
+}
+
+// go test -timeout 30s -run ^TestExtractKey512$ github.com/LdDl/esia-potato/cryptopro
+func TestExtractKey512(t *testing.T) {
+	dir := t.TempDir()
+
+	prvKey := make([]byte, 64)
+	for i := 0; i < 31; i++ {
+		prvKey[i] = byte(i + 1)
+	}
+	mask := make([]byte, 64)
+	for i := 0; i < 31; i++ {
+		mask[i] = byte(0xa0 + i)
+	}
+	salt, err := hex.DecodeString("aabbccdd11223344aabbccdd")
+	require.NoError(t, err)
+
+	oid := "1.2.643.7.1.2.1.2.1"
+	curve := CurveOID[oid]
+	pub := testPublicKey(t, curve, gost3410.Mode2012, prvKey)
+	assert.Len(t, pub, 128)
+
+	header := []byte{0x30, 0x82}
+	header = append(header, oidPatterns[oid]...)
+	header = append(header, 0x8a, 0x08)
+	header = append(header, pub[:8]...)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "header.key"), header, 0600))
+	writeTestSlot(t, dir, "masks.key", "primary.key", curve, prvKey, mask, salt)
+
+	container, err := OpenContainer(dir)
+	require.NoError(t, err)
+	assert.Equal(t, oid, container.OID)
+	assert.Equal(t, gost3410.Mode2012, KeyMode(container.OID))
+
+	keyData, err := container.ExtractKey("")
+	require.NoError(t, err)
+	assert.Equal(t, prvKey, keyData.PrivateKey)
+	assert.Equal(t, pub, keyData.PublicKey)
+}
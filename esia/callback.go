@@ -0,0 +1,182 @@
+package esia
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultStateTTL is how long user may stay on ESIA pages before returning to callback
+const DefaultStateTTL = 10 * time.Minute
+
+// DefaultStateCookie is name of cookie binding state to user browser
+const DefaultStateCookie = "esia_state"
+
+// ErrTokenFuncRequired is returned by NewCallbackHandler without token callback
+var ErrTokenFuncRequired = fmt.Errorf("token callback is required")
+
+// TokenFunc receives tokens of successfully finished authorization and writes response to user
+type TokenFunc func(w http.ResponseWriter, r *http.Request, token *Token)
+
+// ErrorFunc writes response to user when authorization failed
+type ErrorFunc func(w http.ResponseWriter, r *http.Request, err error)
+
+// CallbackHandler starts authorization with a fresh state and handles redirect back from ESIA:
+// checks state, handles error/error_description, exchanges code and passes tokens to OnToken.
+// ServeHTTP serves the callback (RedirectURI), Login redirects user to ESIA.
+// State is also put into HttpOnly cookie and callback is accepted only from the browser holding it,
+// so attacker can't log user in with attacker's own authorization (login CSRF).
+type CallbackHandler struct {
+	client *Client
+	states StateStore
+	// Lifetime of state, DefaultStateTTL by default
+	StateTTL time.Duration
+	// Name of state cookie, DefaultStateCookie by default
+	StateCookie string
+	// Send state cookie over plain HTTP as well. By default it has Secure attribute
+	InsecureCookie bool
+	// Called with tokens after successful exchange
+	OnToken TokenFunc
+	// Called on any failure. By default responds 400 for invalid callbacks and 502 for failed token exchange
+	OnError ErrorFunc
+}
+
+// NewCallbackHandler creates handler storing states in states (NewMemoryStateStore if nil).
+// onToken is called with tokens after successful exchange.
+func NewCallbackHandler(client *Client, states StateStore, onToken TokenFunc) (*CallbackHandler, error) {
+	if onToken == nil {
+		return nil, ErrTokenFuncRequired
+	}
+	if states == nil {
+		states = NewMemoryStateStore()
+	}
+	return &CallbackHandler{
+		client:      client,
+		states:      states,
+		StateTTL:    DefaultStateTTL,
+		StateCookie: DefaultStateCookie,
+		OnToken:     onToken,
+	}, nil
+}
+
+// AuthCodeURL generates and saves new state, binds it to user browser with cookie
+// and returns ESIA authorization URL with it
+func (h *CallbackHandler) AuthCodeURL(w http.ResponseWriter) (string, error) {
+	state := h.client.newState()
+	ttl := h.stateTTL()
+	if err := h.states.Put(state, h.client.now().Add(ttl)); err != nil {
+		return "", errors.Wrap(err, "failed to save state")
+	}
+	authURL, err := h.client.AuthCodeURL(state)
+	if err != nil {
+		return "", err
+	}
+	h.setStateCookie(w, state, int(ttl/time.Second))
+	return authURL, nil
+}
+
+// Login redirects user to ESIA authorization page
+func (h *CallbackHandler) Login(w http.ResponseWriter, r *http.Request) {
+	authURL, err := h.AuthCodeURL(w)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// ServeHTTP handles redirect from ESIA to RedirectURI
+func (h *CallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, err := h.callback(w, r)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+	h.OnToken(w, r, token)
+}
+
+// callback validates callback parameters and exchanges code
+func (h *CallbackHandler) callback(w http.ResponseWriter, r *http.Request) (*Token, error) {
+	q := r.URL.Query()
+
+	// State is checked first: error responses carry state as well
+	state := q.Get("state")
+	if state == "" {
+		return nil, ErrStateRequired
+	}
+	cookie, err := r.Cookie(h.cookieName())
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		return nil, ErrStateMismatch
+	}
+	h.setStateCookie(w, "", -1)
+	expiry, err := h.states.Take(state)
+	if err != nil {
+		return nil, err
+	}
+	if h.client.now().After(expiry) {
+		return nil, ErrStateExpired
+	}
+
+	if code := q.Get("error"); code != "" {
+		return nil, &Error{Code: code, Description: q.Get("error_description")}
+	}
+
+	return h.client.Exchange(r.Context(), q.Get("code"))
+}
+
+func (h *CallbackHandler) stateTTL() time.Duration {
+	if h.StateTTL <= 0 {
+		return DefaultStateTTL
+	}
+	return h.StateTTL
+}
+
+func (h *CallbackHandler) cookieName() string {
+	if h.StateCookie == "" {
+		return DefaultStateCookie
+	}
+	return h.StateCookie
+}
+
+// setStateCookie sets state cookie living maxAge seconds or removes it if maxAge is negative.
+// SameSite=Lax lets the cookie through on top-level redirect from ESIA
+func (h *CallbackHandler) setStateCookie(w http.ResponseWriter, state string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     h.cookieName(),
+		Value:    state,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   !h.InsecureCookie,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (h *CallbackHandler) fail(w http.ResponseWriter, r *http.Request, err error) {
+	if h.OnError != nil {
+		h.OnError(w, r, err)
+		return
+	}
+	http.Error(w, err.Error(), callbackStatus(err))
+}
+
+// callbackStatus returns 400 for errors caused by callback request and 502 for token exchange failures
+func callbackStatus(err error) int {
+	var esiaErr *Error
+	if errors.As(err, &esiaErr) {
+		// Error from callback parameters has no HTTP status
+		if esiaErr.StatusCode == 0 {
+			return http.StatusBadRequest
+		}
+		return http.StatusBadGateway
+	}
+	switch {
+	case errors.Is(err, ErrStateRequired), errors.Is(err, ErrStateMismatch), errors.Is(err, ErrStateUnknown),
+		errors.Is(err, ErrStateExpired), errors.Is(err, ErrCodeRequired):
+		return http.StatusBadRequest
+	}
+	return http.StatusBadGateway
+}
@@ -0,0 +1,184 @@
+package esia
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -timeout 30s -run ^TestMemoryStateStore$ github.com/LdDl/esia-potato/esia
+func TestMemoryStateStore(t *testing.T) {
+	store := NewMemoryStateStore()
+	now := testTime
+	store.Now = func() time.Time { return now }
+
+	require.NoError(t, store.Put("a", now.Add(time.Minute)))
+	require.NoError(t, store.Put("b", now.Add(time.Second)))
+
+	expiry, err := store.Take("a")
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(time.Minute), expiry)
+	_, err = store.Take("a")
+	assert.ErrorIs(t, err, ErrStateUnknown, "state is single use")
+	_, err = store.Take("unknown")
+	assert.ErrorIs(t, err, ErrStateUnknown)
+
+	// Expired states are purged on Put
+	now = now.Add(time.Minute)
+	require.NoError(t, store.Put("c", now.Add(time.Minute)))
+	_, err = store.Take("b")
+	assert.ErrorIs(t, err, ErrStateUnknown)
+	_, err = store.Take("c")
+	assert.NoError(t, err)
+}
+
+func newTestCallbackHandler(t *testing.T, baseURL string) (*CallbackHandler, *[]*Token) {
+	client := newTestClient(t, baseURL)
+	n := 0
+	client.newState = func() string {
+		n++
+		return fmt.Sprintf("state-%d", n)
+	}
+	store := NewMemoryStateStore()
+	store.Now = client.now
+	var tokens []*Token
+	handler, err := NewCallbackHandler(client, store, func(w http.ResponseWriter, r *http.Request, token *Token) {
+		tokens = append(tokens, token)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	require.NoError(t, err)
+	return handler, &tokens
+}
+
+// login calls Login handler and returns state from redirect URL with cookie binding it to browser
+func login(t *testing.T, handler *CallbackHandler) (string, *http.Cookie) {
+	rec := httptest.NewRecorder()
+	handler.Login(rec, httptest.NewRequest(http.MethodGet, "/login", nil))
+	require.Equal(t, http.StatusFound, rec.Code)
+	u, err := url.Parse(rec.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, authCodePath, u.Path)
+	state := u.Query().Get("state")
+
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, DefaultStateCookie, cookies[0].Name)
+	assert.Equal(t, state, cookies[0].Value)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.True(t, cookies[0].Secure)
+	assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+	assert.Equal(t, int(handler.StateTTL/time.Second), cookies[0].MaxAge)
+	return state, cookies[0]
+}
+
+// callback calls callback handler as browser holding cookie (if not nil)
+func callback(handler *CallbackHandler, query url.Values, cookie *http.Cookie) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/callback?"+query.Encode(), nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// go test -timeout 30s -run ^TestNewCallbackHandler$ github.com/LdDl/esia-potato/esia
+func TestNewCallbackHandler(t *testing.T) {
+	_, err := NewCallbackHandler(newTestClient(t, ""), nil, nil)
+	assert.ErrorIs(t, err, ErrTokenFuncRequired)
+}
+
+// go test -timeout 30s -run ^TestCallbackHandler$ github.com/LdDl/esia-potato/esia
+func TestCallbackHandler(t *testing.T) {
+	server := fakeESIA(t, func(form url.Values) (int, interface{}) {
+		if form.Get("code") != "auth-code" {
+			return http.StatusBadRequest, map[string]string{"error": "invalid_grant"}
+		}
+		return http.StatusOK, map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		}
+	})
+	handler, tokens := newTestCallbackHandler(t, server.URL)
+
+	state, cookie := login(t, handler)
+	assert.Equal(t, "state-1", state)
+
+	rec := callback(handler, url.Values{"state": {state}, "code": {"auth-code"}}, cookie)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	require.Len(t, *tokens, 1)
+	assert.Equal(t, "access", (*tokens)[0].AccessToken)
+	// State cookie is removed
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, DefaultStateCookie, cookies[0].Name)
+	assert.Negative(t, cookies[0].MaxAge)
+
+	// Replayed callback
+	rec = callback(handler, url.Values{"state": {state}, "code": {"auth-code"}}, cookie)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), ErrStateUnknown.Error())
+
+	// Missing and forged state
+	state, cookie = login(t, handler)
+	assert.Equal(t, http.StatusBadRequest, callback(handler, url.Values{"code": {"auth-code"}}, cookie).Code)
+	rec = callback(handler, url.Values{"state": {"forged"}, "code": {"auth-code"}}, cookie)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), ErrStateMismatch.Error())
+
+	// Valid state started in another browser (login CSRF) is rejected without the cookie
+	rec = callback(handler, url.Values{"state": {state}, "code": {"auth-code"}}, nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), ErrStateMismatch.Error())
+	otherState, _ := login(t, handler)
+	rec = callback(handler, url.Values{"state": {otherState}, "code": {"auth-code"}}, cookie)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), ErrStateMismatch.Error())
+
+	// Error returned by ESIA
+	state, cookie = login(t, handler)
+	rec = callback(handler, url.Values{"state": {state}, "error": {"access_denied"}, "error_description": {"user declined"}}, cookie)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "esia: access_denied: user declined")
+
+	// Missing code
+	state, cookie = login(t, handler)
+	assert.Equal(t, http.StatusBadRequest, callback(handler, url.Values{"state": {state}}, cookie).Code)
+
+	// Failed exchange
+	state, cookie = login(t, handler)
+	var failure error
+	handler.OnError = func(w http.ResponseWriter, r *http.Request, err error) {
+		failure = err
+		w.WriteHeader(http.StatusTeapot)
+	}
+	rec = callback(handler, url.Values{"state": {state}, "code": {"bad-code"}}, cookie)
+	assert.Equal(t, http.StatusTeapot, rec.Code)
+	var esiaErr *Error
+	require.ErrorAs(t, failure, &esiaErr)
+	assert.Equal(t, "invalid_grant", esiaErr.Code)
+	assert.Equal(t, http.StatusBadGateway, callbackStatus(failure))
+
+	assert.Len(t, *tokens, 1)
+}
+
+// go test -timeout 30s -run ^TestCallbackHandlerStateExpiry$ github.com/LdDl/esia-potato/esia
+func TestCallbackHandlerStateExpiry(t *testing.T) {
+	handler, tokens := newTestCallbackHandler(t, "")
+	handler.StateTTL = time.Minute
+
+	state, cookie := login(t, handler)
+	handler.client.now = func() time.Time { return testTime.Add(2 * time.Minute) }
+
+	rec := callback(handler, url.Values{"state": {state}, "code": {"auth-code"}}, cookie)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), ErrStateExpired.Error())
+	assert.Empty(t, *tokens)
+}
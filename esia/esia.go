@@ -0,0 +1,240 @@
+// Package esia implements OAuth2 client for ESIA (Russian government authentication service)
+package esia
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// ESIA environments
+const (
+	// Test environment
+	TestBaseURL = "https://esia-portal1.test.gosuslugi.ru"
+	// Production environment
+	ProdBaseURL = "https://esia.gosuslugi.ru"
+)
+
+// TimestampLayout is the format of timestamp parameter expected by ESIA
+const TimestampLayout = "2006.01.02 15:04:05 -0700"
+
+// Version selects ESIA authorization flow
+type Version int
+
+const (
+	// V1 is the legacy flow: /aas/oauth2/ac and /aas/oauth2/te,
+	// client_secret signs scope + timestamp + client_id + state
+	V1 Version = iota + 1
+	// V2 is the flow with client_certificate_hash: /aas/oauth2/v2/ac and /aas/oauth2/v3/te,
+	// client_secret signs client_id + scope + timestamp + state + redirect_uri (+ code or refresh token)
+	V2
+)
+
+// Endpoints
+const (
+	authCodePath   = "/aas/oauth2/ac"
+	tokenPath      = "/aas/oauth2/te"
+	authCodePathV2 = "/aas/oauth2/v2/ac"
+	tokenPathV3    = "/aas/oauth2/v3/te"
+	revokePath     = "/aas/oauth2/rvk"
+	logoutPath     = "/idp/ext/Logout"
+)
+
+// Access types
+const (
+	// Refresh token is issued along with access token
+	AccessTypeOffline = "offline"
+	// Only access token is issued
+	AccessTypeOnline = "online"
+)
+
+// Sentinel errors
+var (
+	ErrClientIDRequired    = fmt.Errorf("client id is required")
+	ErrRedirectURIRequired = fmt.Errorf("redirect URI is required")
+	ErrScopesRequired      = fmt.Errorf("at least one scope is required")
+	ErrSignerRequired      = fmt.Errorf("signer is required")
+	ErrStateRequired       = fmt.Errorf("state is required")
+	ErrCodeRequired        = fmt.Errorf("authorization code is required")
+	ErrRefreshRequired     = fmt.Errorf("refresh token is required")
+	ErrTokenRequired       = fmt.Errorf("token is required")
+	ErrCertificateHash     = fmt.Errorf("certificate hash is required for ESIA v2")
+	ErrVersionUnknown      = fmt.Errorf("unknown ESIA flow version")
+)
+
+// Signer produces detached CMS signature used as client_secret.
+// *cms.Signer implements it.
+type Signer interface {
+	Sign(content []byte) ([]byte, error)
+}
+
+// TimeSigner is implemented by signers which can put given signing time into signature (e.g. *cms.Signer).
+// Such signers are used with the same time as the timestamp parameter of request.
+type TimeSigner interface {
+	SignAt(content []byte, t time.Time) ([]byte, error)
+}
+
+// CertificateHasher is implemented by signers which know hash of their certificate (e.g. *cms.Signer).
+// It is used as client_certificate_hash in V2 flow when Config.CertificateHash is empty.
+type CertificateHasher interface {
+	CertificateHash() string
+}
+
+// Config holds ESIA client settings
+type Config struct {
+	// Mnemonic of the information system registered in ESIA
+	ClientID string
+	// Where ESIA redirects user after authorization
+	RedirectURI string
+	// Requested scopes, e.g. openid, fullname, email
+	Scopes []string
+	// ESIA environment, TestBaseURL by default
+	BaseURL string
+	// AccessTypeOffline by default
+	AccessType string
+	// Signer of client_secret
+	Signer Signer
+	// Authorization flow version, V1 by default
+	Version Version
+	// client_certificate_hash for V2 flow. Taken from Signer if it implements CertificateHasher
+	CertificateHash string
+	// HTTP client for token requests, http.DefaultClient by default
+	HTTPClient *http.Client
+}
+
+// Client is ESIA OAuth2 client
+type Client struct {
+	cfg Config
+	// clock, replaced in tests
+	now func() time.Time
+	// generator of state for token requests, replaced in tests
+	newState func() string
+	// cache of client_credentials tokens
+	systemTokens *systemTokens
+}
+
+// NewClient validates configuration and creates ESIA client
+func NewClient(cfg Config) (*Client, error) {
+	if cfg.ClientID == "" {
+		return nil, ErrClientIDRequired
+	}
+	if cfg.RedirectURI == "" {
+		return nil, ErrRedirectURIRequired
+	}
+	if len(cfg.Scopes) == 0 {
+		return nil, ErrScopesRequired
+	}
+	if cfg.Signer == nil {
+		return nil, ErrSignerRequired
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = TestBaseURL
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	if cfg.AccessType == "" {
+		cfg.AccessType = AccessTypeOffline
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	if cfg.Version == 0 {
+		cfg.Version = V1
+	}
+	switch cfg.Version {
+	case V1:
+	case V2:
+		if cfg.CertificateHash == "" {
+			if hasher, ok := cfg.Signer.(CertificateHasher); ok {
+				cfg.CertificateHash = hasher.CertificateHash()
+			}
+		}
+		if cfg.CertificateHash == "" {
+			return nil, ErrCertificateHash
+		}
+	default:
+		return nil, errors.Wrapf(ErrVersionUnknown, "version %d", cfg.Version)
+	}
+
+	return &Client{
+		cfg:          cfg,
+		now:          time.Now,
+		newState:     func() string { return uuid.New().String() },
+		systemTokens: newSystemTokens(),
+	}, nil
+}
+
+// Scope returns requested scopes as a single space separated string
+func (c *Client) Scope() string {
+	return strings.Join(c.cfg.Scopes, " ")
+}
+
+// AuthCodeURL builds URL of ESIA authorization page.
+// State must be unique for every authorization attempt and checked on callback.
+func (c *Client) AuthCodeURL(state string) (string, error) {
+	if state == "" {
+		return "", ErrStateRequired
+	}
+
+	scope := c.Scope()
+	now := c.now()
+	timestamp := formatTimestamp(now)
+	clientSecret, err := c.clientSecret(scope, now, state, "")
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("client_id", c.cfg.ClientID)
+	params.Set("client_secret", clientSecret)
+	params.Set("redirect_uri", c.cfg.RedirectURI)
+	params.Set("scope", scope)
+	params.Set("response_type", "code")
+	params.Set("state", state)
+	params.Set("timestamp", timestamp)
+	params.Set("access_type", c.cfg.AccessType)
+
+	path := authCodePath
+	if c.cfg.Version == V2 {
+		path = authCodePathV2
+		params.Set("client_certificate_hash", c.cfg.CertificateHash)
+	}
+
+	return c.cfg.BaseURL + path + "?" + params.Encode(), nil
+}
+
+// formatTimestamp formats time in ESIA format
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format(TimestampLayout)
+}
+
+// secretMessage builds the string signed as client_secret.
+// grant is authorization code or refresh token for token requests and empty for authorization URL.
+func (c *Client) secretMessage(scope, timestamp, state, grant string) string {
+	if c.cfg.Version == V2 {
+		return c.cfg.ClientID + scope + timestamp + state + c.cfg.RedirectURI + grant
+	}
+	return scope + timestamp + c.cfg.ClientID + state
+}
+
+// clientSecret signs secret message with timestamp of now and encodes signature with URL-safe base64.
+// TimeSigner puts now into signingTime attribute as well.
+func (c *Client) clientSecret(scope string, now time.Time, state, grant string) (string, error) {
+	message := []byte(c.secretMessage(scope, formatTimestamp(now), state, grant))
+	var signature []byte
+	var err error
+	if signer, ok := c.cfg.Signer.(TimeSigner); ok {
+		signature, err = signer.SignAt(message, now)
+	} else {
+		signature, err = c.cfg.Signer.Sign(message)
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "failed to sign client secret")
+	}
+	return base64.URLEncoding.EncodeToString(signature), nil
+}
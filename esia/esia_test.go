@@ -0,0 +1,290 @@
+package esia
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSigner "signs" by prefixing content, so tests can check what was signed
+type fakeSigner struct{}
+
+func (fakeSigner) Sign(content []byte) ([]byte, error) {
+	return append([]byte("signed:"), content...), nil
+}
+
+// fakeTimeSigner also puts signing time into "signature"
+type fakeTimeSigner struct {
+	fakeSigner
+}
+
+func (fakeTimeSigner) SignAt(content []byte, t time.Time) ([]byte, error) {
+	return append([]byte("signed at "+t.Format(time.RFC3339)+":"), content...), nil
+}
+
+func decodeSecret(t *testing.T, secret string) string {
+	raw, err := base64.URLEncoding.DecodeString(secret)
+	require.NoError(t, err, "client_secret should be URL-safe base64")
+	return string(raw)
+}
+
+var testTime = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestClient(t *testing.T, baseURL string) *Client {
+	client, err := NewClient(Config{
+		ClientID:    "CLIENT_ID",
+		RedirectURI: "https://example.com/callback",
+		Scopes:      []string{"openid", "fullname"},
+		BaseURL:     baseURL,
+		Signer:      fakeSigner{},
+	})
+	require.NoError(t, err)
+	client.now = func() time.Time { return testTime }
+	client.newState = func() string { return "token-state" }
+	return client
+}
+
+// fakeESIA emulates ESIA token endpoint
+func fakeESIA(t *testing.T, handler func(form url.Values) (int, interface{})) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc(tokenPath, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/x-www-form-urlencoded", r.Header.Get("Content-Type"))
+		require.NoError(t, r.ParseForm())
+		status, body := handler(r.PostForm)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(body)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// go test -timeout 30s -run ^TestNewClientValidation$ github.com/LdDl/esia-potato/esia
+func TestNewClientValidation(t *testing.T) {
+	_, err := NewClient(Config{})
+	assert.ErrorIs(t, err, ErrClientIDRequired)
+
+	_, err = NewClient(Config{ClientID: "id"})
+	assert.ErrorIs(t, err, ErrRedirectURIRequired)
+
+	_, err = NewClient(Config{ClientID: "id", RedirectURI: "https://example.com"})
+	assert.ErrorIs(t, err, ErrScopesRequired)
+
+	_, err = NewClient(Config{ClientID: "id", RedirectURI: "https://example.com", Scopes: []string{"openid"}})
+	assert.ErrorIs(t, err, ErrSignerRequired)
+}
+
+// go test -timeout 30s -run ^TestAuthCodeURL$ github.com/LdDl/esia-potato/esia
+func TestAuthCodeURL(t *testing.T) {
+	client := newTestClient(t, "")
+
+	_, err := client.AuthCodeURL("")
+	assert.ErrorIs(t, err, ErrStateRequired)
+
+	authURL, err := client.AuthCodeURL("auth-state")
+	require.NoError(t, err)
+
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, TestBaseURL+authCodePath, u.Scheme+"://"+u.Host+u.Path)
+
+	q := u.Query()
+	assert.Equal(t, "CLIENT_ID", q.Get("client_id"))
+	assert.Equal(t, "https://example.com/callback", q.Get("redirect_uri"))
+	assert.Equal(t, "openid fullname", q.Get("scope"))
+	assert.Equal(t, "code", q.Get("response_type"))
+	assert.Equal(t, "auth-state", q.Get("state"))
+	assert.Equal(t, "2025.01.01 12:00:00 +0000", q.Get("timestamp"))
+	assert.Equal(t, AccessTypeOffline, q.Get("access_type"))
+	assert.Equal(t, "signed:openid fullname2025.01.01 12:00:00 +0000CLIENT_IDauth-state", decodeSecret(t, q.Get("client_secret")))
+}
+
+// go test -timeout 30s -run ^TestAuthCodeURLTimeSigner$ github.com/LdDl/esia-potato/esia
+func TestAuthCodeURLTimeSigner(t *testing.T) {
+	client, err := NewClient(Config{
+		ClientID:    "CLIENT_ID",
+		RedirectURI: "https://example.com/callback",
+		Scopes:      []string{"openid"},
+		Signer:      fakeTimeSigner{},
+	})
+	require.NoError(t, err)
+	client.now = func() time.Time { return testTime }
+
+	authURL, err := client.AuthCodeURL("auth-state")
+	require.NoError(t, err)
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+
+	// Signing time equals timestamp parameter
+	assert.Equal(t, "2025.01.01 12:00:00 +0000", u.Query().Get("timestamp"))
+	assert.Equal(t, "signed at 2025-01-01T12:00:00Z:openid2025.01.01 12:00:00 +0000CLIENT_IDauth-state", decodeSecret(t, u.Query().Get("client_secret")))
+}
+
+// go test -timeout 30s -run ^TestExchange$ github.com/LdDl/esia-potato/esia
+func TestExchange(t *testing.T) {
+	server := fakeESIA(t, func(form url.Values) (int, interface{}) {
+		assert.Equal(t, "authorization_code", form.Get("grant_type"))
+		assert.Equal(t, "auth-code", form.Get("code"))
+		assert.Equal(t, "CLIENT_ID", form.Get("client_id"))
+		assert.Equal(t, "https://example.com/callback", form.Get("redirect_uri"))
+		assert.Equal(t, "openid fullname", form.Get("scope"))
+		assert.Equal(t, "token-state", form.Get("state"))
+		assert.Equal(t, "Bearer", form.Get("token_type"))
+		assert.Equal(t, "signed:openid fullname2025.01.01 12:00:00 +0000CLIENT_IDtoken-state", decodeSecret(t, form.Get("client_secret")))
+		return http.StatusOK, map[string]interface{}{
+			"access_token":  "access",
+			"refresh_token": "refresh",
+			"id_token":      "id",
+			"token_type":    "Bearer",
+			"expires_in":    3600,
+			"state":         "token-state",
+		}
+	})
+	client := newTestClient(t, server.URL)
+
+	token, err := client.Exchange(context.Background(), "auth-code")
+	require.NoError(t, err)
+	assert.Equal(t, "access", token.AccessToken)
+	assert.Equal(t, "refresh", token.RefreshToken)
+	assert.Equal(t, "id", token.IDToken)
+	assert.Equal(t, testTime.Add(time.Hour), token.Expiry)
+	assert.False(t, token.Expired(testTime))
+	assert.True(t, token.Expired(testTime.Add(time.Hour)))
+
+	_, err = client.Exchange(context.Background(), "")
+	assert.ErrorIs(t, err, ErrCodeRequired)
+}
+
+// go test -timeout 30s -run ^TestRefresh$ github.com/LdDl/esia-potato/esia
+func TestRefresh(t *testing.T) {
+	server := fakeESIA(t, func(form url.Values) (int, interface{}) {
+		assert.Equal(t, "refresh_token", form.Get("grant_type"))
+		assert.Equal(t, "refresh", form.Get("refresh_token"))
+		assert.NotEmpty(t, form.Get("client_secret"))
+		return http.StatusOK, map[string]interface{}{
+			"access_token": "access2",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		}
+	})
+	client := newTestClient(t, server.URL)
+
+	token, err := client.Refresh(context.Background(), "refresh")
+	require.NoError(t, err)
+	assert.Equal(t, "access2", token.AccessToken)
+
+	_, err = client.Refresh(context.Background(), "")
+	assert.ErrorIs(t, err, ErrRefreshRequired)
+}
+
+// go test -timeout 30s -run ^TestTokenError$ github.com/LdDl/esia-potato/esia
+func TestTokenError(t *testing.T) {
+	server := fakeESIA(t, func(form url.Values) (int, interface{}) {
+		return http.StatusBadRequest, map[string]string{
+			"error":             "invalid_grant",
+			"error_description": "ESIA-007004: code expired",
+		}
+	})
+	client := newTestClient(t, server.URL)
+
+	_, err := client.Exchange(context.Background(), "auth-code")
+	require.Error(t, err)
+
+	var esiaErr *Error
+	require.True(t, errors.As(err, &esiaErr), "error should be *esia.Error")
+	assert.Equal(t, http.StatusBadRequest, esiaErr.StatusCode)
+	assert.Equal(t, "invalid_grant", esiaErr.Code)
+	assert.Equal(t, "ESIA-007004: code expired", esiaErr.Description)
+}
+
+// hashingSigner is fakeSigner which knows its certificate hash
+type hashingSigner struct {
+	fakeSigner
+}
+
+func (hashingSigner) CertificateHash() string {
+	return "CERTHASH"
+}
+
+func newTestClientV2(t *testing.T, baseURL string) *Client {
+	client, err := NewClient(Config{
+		ClientID:    "CLIENT_ID",
+		RedirectURI: "https://example.com/callback",
+		Scopes:      []string{"openid"},
+		BaseURL:     baseURL,
+		Signer:      hashingSigner{},
+		Version:     V2,
+	})
+	require.NoError(t, err)
+	client.now = func() time.Time { return testTime }
+	client.newState = func() string { return "token-state" }
+	return client
+}
+
+// go test -timeout 30s -run ^TestNewClientV2Validation$ github.com/LdDl/esia-potato/esia
+func TestNewClientV2Validation(t *testing.T) {
+	cfg := Config{
+		ClientID:    "CLIENT_ID",
+		RedirectURI: "https://example.com/callback",
+		Scopes:      []string{"openid"},
+		Signer:      fakeSigner{},
+		Version:     V2,
+	}
+	_, err := NewClient(cfg)
+	assert.ErrorIs(t, err, ErrCertificateHash)
+
+	cfg.CertificateHash = "EXPLICIT"
+	client, err := NewClient(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "EXPLICIT", client.cfg.CertificateHash)
+
+	cfg.Version = Version(42)
+	_, err = NewClient(cfg)
+	assert.ErrorIs(t, err, ErrVersionUnknown)
+}
+
+// go test -timeout 30s -run ^TestAuthCodeURLV2$ github.com/LdDl/esia-potato/esia
+func TestAuthCodeURLV2(t *testing.T) {
+	client := newTestClientV2(t, "")
+
+	authURL, err := client.AuthCodeURL("auth-state")
+	require.NoError(t, err)
+
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, authCodePathV2, u.Path)
+
+	q := u.Query()
+	assert.Equal(t, "CERTHASH", q.Get("client_certificate_hash"))
+	assert.Equal(t, "signed:CLIENT_IDopenid2025.01.01 12:00:00 +0000auth-statehttps://example.com/callback", decodeSecret(t, q.Get("client_secret")))
+}
+
+// go test -timeout 30s -run ^TestExchangeV2$ github.com/LdDl/esia-potato/esia
+func TestExchangeV2(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(tokenPathV3, func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "CERTHASH", r.PostForm.Get("client_certificate_hash"))
+		assert.Equal(t, "signed:CLIENT_IDopenid2025.01.01 12:00:00 +0000token-statehttps://example.com/callbackauth-code", decodeSecret(t, r.PostForm.Get("client_secret")))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"access","token_type":"Bearer","expires_in":3600}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := newTestClientV2(t, server.URL)
+	token, err := client.Exchange(context.Background(), "auth-code")
+	require.NoError(t, err)
+	assert.Equal(t, "access", token.AccessToken)
+}
@@ -0,0 +1,79 @@
+package esia
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Token type hints for Revoke
+const (
+	TokenTypeAccess  = "access_token"
+	TokenTypeRefresh = "refresh_token"
+)
+
+// LogoutURL builds URL ending user session in ESIA. After logout ESIA redirects user to redirectURL
+// (it must be registered for the information system); if empty, user stays on ESIA page.
+func (c *Client) LogoutURL(redirectURL string) string {
+	params := url.Values{}
+	params.Set("client_id", c.cfg.ClientID)
+	if redirectURL != "" {
+		params.Set("redirect_url", redirectURL)
+	}
+	return c.cfg.BaseURL + logoutPath + "?" + params.Encode()
+}
+
+// Revoke invalidates access or refresh token. tokenTypeHint is TokenTypeAccess, TokenTypeRefresh or empty.
+// Request is signed as token requests; in V2 flow the token is signed as well.
+func (c *Client) Revoke(ctx context.Context, token, tokenTypeHint string) error {
+	if token == "" {
+		return ErrTokenRequired
+	}
+	params := url.Values{}
+	params.Set("token", token)
+	if tokenTypeHint != "" {
+		params.Set("token_type_hint", tokenTypeHint)
+	}
+	if err := c.signParams(params, token); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.BaseURL+revokePath, strings.NewReader(params.Encode()))
+	if err != nil {
+		return errors.Wrap(err, "failed to create revoke request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "revoke request failed")
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return errors.Wrap(err, "failed to read revoke response")
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return decodeError(resp.StatusCode, body)
+	}
+	return nil
+}
+
+// RevokeToken revokes refresh token (if any) and access token of t
+func (c *Client) RevokeToken(ctx context.Context, t *Token) error {
+	if t == nil {
+		return ErrTokenRequired
+	}
+	if t.RefreshToken != "" {
+		if err := c.Revoke(ctx, t.RefreshToken, TokenTypeRefresh); err != nil {
+			return err
+		}
+	}
+	return c.Revoke(ctx, t.AccessToken, TokenTypeAccess)
+}
@@ -0,0 +1,70 @@
+package esia
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -timeout 30s -run ^TestLogoutURL$ github.com/LdDl/esia-potato/esia
+func TestLogoutURL(t *testing.T) {
+	client := newTestClient(t, "")
+
+	u, err := url.Parse(client.LogoutURL("https://example.com/logged-out"))
+	require.NoError(t, err)
+	assert.Equal(t, TestBaseURL+logoutPath, u.Scheme+"://"+u.Host+u.Path)
+	assert.Equal(t, "CLIENT_ID", u.Query().Get("client_id"))
+	assert.Equal(t, "https://example.com/logged-out", u.Query().Get("redirect_url"))
+
+	u, err = url.Parse(client.LogoutURL(""))
+	require.NoError(t, err)
+	assert.False(t, u.Query().Has("redirect_url"))
+}
+
+// go test -timeout 30s -run ^TestRevoke$ github.com/LdDl/esia-potato/esia
+func TestRevoke(t *testing.T) {
+	var revoked []url.Values
+	mux := http.NewServeMux()
+	mux.HandleFunc(revokePath, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		require.NoError(t, r.ParseForm())
+		revoked = append(revoked, r.PostForm)
+		if r.PostForm.Get("token") == "unknown" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_token"})
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	client := newTestClient(t, server.URL)
+	ctx := context.Background()
+
+	assert.ErrorIs(t, client.Revoke(ctx, "", ""), ErrTokenRequired)
+	assert.ErrorIs(t, client.RevokeToken(ctx, nil), ErrTokenRequired)
+
+	require.NoError(t, client.RevokeToken(ctx, &Token{AccessToken: "access", RefreshToken: "refresh"}))
+	require.Len(t, revoked, 2)
+	assert.Equal(t, "refresh", revoked[0].Get("token"))
+	assert.Equal(t, TokenTypeRefresh, revoked[0].Get("token_type_hint"))
+	assert.Equal(t, "access", revoked[1].Get("token"))
+	assert.Equal(t, TokenTypeAccess, revoked[1].Get("token_type_hint"))
+	assert.Equal(t, "CLIENT_ID", revoked[1].Get("client_id"))
+	assert.Equal(t, "token-state", revoked[1].Get("state"))
+	assert.Equal(t, "signed:openid fullname2025.01.01 12:00:00 +0000CLIENT_IDtoken-state", decodeSecret(t, revoked[1].Get("client_secret")))
+
+	err := client.Revoke(ctx, "unknown", "")
+	var esiaErr *Error
+	require.ErrorAs(t, err, &esiaErr)
+	assert.Equal(t, http.StatusBadRequest, esiaErr.StatusCode)
+	assert.Equal(t, "invalid_token", esiaErr.Code)
+	assert.False(t, revoked[2].Has("token_type_hint"))
+}
@@ -0,0 +1,139 @@
+package esia
+
+import (
+	"context"
+	"strconv"
+)
+
+// Organization data endpoints (require org_* scopes with org_oid, see OrgScope)
+const (
+	rolesPath     = "/roles"
+	orgsPath      = "/rs/orgs/"
+	employeesPath = "/emps"
+	branchesPath  = "/brhs"
+)
+
+// Organization types
+const (
+	OrgLegal    = "LEGAL"
+	OrgBusiness = "BUSINESS"
+	OrgAgency   = "AGENCY"
+)
+
+// Role is membership of person in organization, element of /rs/prns/{oid}/roles
+type Role struct {
+	// Organization oid
+	OID       int64  `json:"oid"`
+	FullName  string `json:"fullName,omitempty"`
+	ShortName string `json:"shortName,omitempty"`
+	OGRN      string `json:"ogrn,omitempty"`
+	// OrgLegal, OrgBusiness or OrgAgency
+	Type string `json:"type,omitempty"`
+	// Person is head of organization
+	Chief bool `json:"chief"`
+	// Person is administrator of organization profile
+	Admin      bool   `json:"admin"`
+	Active     bool   `json:"active"`
+	Liquidated bool   `json:"isLiquidated"`
+	Email      string `json:"email,omitempty"`
+	Phone      string `json:"phone,omitempty"`
+	// Organization branch of employee, if any
+	BranchOID int64 `json:"branchOid,omitempty"`
+}
+
+// Organization is returned by /rs/orgs/{oid}.
+// Collections are filled only when requested with embed, e.g. Embed("employees.elements").
+type Organization struct {
+	StateFacts []string `json:"stateFacts,omitempty"`
+	OID        int64    `json:"oid"`
+	ShortName  string   `json:"shortName,omitempty"`
+	FullName   string   `json:"fullName,omitempty"`
+	// OrgLegal, OrgBusiness or OrgAgency
+	Type string `json:"type,omitempty"`
+	OGRN string `json:"ogrn,omitempty"`
+	INN  string `json:"inn,omitempty"`
+	// Code of legal form (OKOPF)
+	Leg        string `json:"leg,omitempty"`
+	KPP        string `json:"kpp,omitempty"`
+	AgencyType string `json:"agencyType,omitempty"`
+	OKTMO      string `json:"oktmo,omitempty"`
+	Liquidated bool   `json:"isLiquidated"`
+	ETag       string `json:"eTag,omitempty"`
+
+	Contacts  *Collection[Contact]  `json:"contacts,omitempty"`
+	Addresses *Collection[Address]  `json:"addresses,omitempty"`
+	Vehicles  *Collection[Vehicle]  `json:"vehicles,omitempty"`
+	Employees *Collection[Employee] `json:"employees,omitempty"`
+	Branches  *Collection[Branch]   `json:"branches,omitempty"`
+}
+
+// Employee is element of /rs/orgs/{oid}/emps
+type Employee struct {
+	StateFacts []string `json:"stateFacts,omitempty"`
+	// Person oid
+	OID      int64  `json:"oid"`
+	Position string `json:"position,omitempty"`
+	Chief    bool   `json:"chief"`
+	Active   bool   `json:"active"`
+	Email    string `json:"email,omitempty"`
+	Phone    string `json:"phone,omitempty"`
+	ETag     string `json:"eTag,omitempty"`
+	// Filled when requested with embed, e.g. Embed("elements.person")
+	Person *Person `json:"person,omitempty"`
+}
+
+// Branch is element of /rs/orgs/{oid}/brhs
+type Branch struct {
+	StateFacts []string `json:"stateFacts,omitempty"`
+	ID         int64    `json:"id"`
+	Name       string   `json:"name,omitempty"`
+	Leg        string   `json:"leg,omitempty"`
+	KPP        string   `json:"kpp,omitempty"`
+	ETag       string   `json:"eTag,omitempty"`
+
+	Contacts  *Collection[Contact] `json:"contacts,omitempty"`
+	Addresses *Collection[Address] `json:"addresses,omitempty"`
+}
+
+func orgPath(oid int64) string {
+	return orgsPath + strconv.FormatInt(oid, 10)
+}
+
+// Roles returns organizations of person (requires usr_org scope)
+func (a *API) Roles(ctx context.Context, oid int64) ([]Role, error) {
+	return collection[Role](ctx, a, personPath(oid)+rolesPath)
+}
+
+// Organization returns organization data. Embed paths (e.g. "branches.elements") expand collections in one request.
+func (a *API) Organization(ctx context.Context, orgOID int64, embed ...string) (*Organization, error) {
+	var org Organization
+	if err := a.get(ctx, orgPath(orgOID), embedQuery(embed), &org); err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// OrgEmployees returns all employees of organization
+func (a *API) OrgEmployees(ctx context.Context, orgOID int64) ([]Employee, error) {
+	return collection[Employee](ctx, a, orgPath(orgOID)+employeesPath)
+}
+
+// OrgBranches returns all branches of organization
+func (a *API) OrgBranches(ctx context.Context, orgOID int64) ([]Branch, error) {
+	return collection[Branch](ctx, a, orgPath(orgOID)+branchesPath)
+}
+
+// OrgVehicles returns all vehicles of organization
+func (a *API) OrgVehicles(ctx context.Context, orgOID int64) ([]Vehicle, error) {
+	return collection[Vehicle](ctx, a, orgPath(orgOID)+vehiclesPath)
+}
+
+// OrgContacts returns all contacts of organization
+func (a *API) OrgContacts(ctx context.Context, orgOID int64) ([]Contact, error) {
+	return collection[Contact](ctx, a, orgPath(orgOID)+contactsPath)
+}
+
+// OrgAddresses returns all addresses of organization
+func (a *API) OrgAddresses(ctx context.Context, orgOID int64) ([]Address, error) {
+	return collection[Address](ctx, a, orgPath(orgOID)+addressesPath)
+}
@@ -0,0 +1,91 @@
+package esia
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -timeout 30s -run ^TestOrgScope$ github.com/LdDl/esia-potato/esia
+func TestOrgScope(t *testing.T) {
+	assert.Equal(t, "http://esia.gosuslugi.ru/org_fullname?org_oid=1000298922", OrgScope(ScopeOrgFullName, 1000298922))
+	assert.Equal(t, []string{
+		"http://esia.gosuslugi.ru/org_shortname?org_oid=42",
+		"http://esia.gosuslugi.ru/org_emps?org_oid=42",
+	}, OrgScopes(42, ScopeOrgShortName, ScopeOrgEmployees))
+}
+
+// go test -timeout 30s -run ^TestWithScopes$ github.com/LdDl/esia-potato/esia
+func TestWithScopes(t *testing.T) {
+	scopes := append([]string{ScopeOpenID}, OrgScopes(1000298922, ScopeOrgFullName, ScopeOrgEmployees)...)
+	scope := "openid http://esia.gosuslugi.ru/org_fullname?org_oid=1000298922 http://esia.gosuslugi.ru/org_emps?org_oid=1000298922"
+
+	client := newTestClient(t, "")
+	orgClient := client.WithScopes(scopes...)
+	assert.Equal(t, scope, orgClient.Scope())
+	assert.Equal(t, "openid fullname", client.Scope(), "original client should keep its scopes")
+
+	authURL, err := orgClient.AuthCodeURL("auth-state")
+	require.NoError(t, err)
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, scope, u.Query().Get("scope"))
+	assert.Equal(t, "signed:"+scope+"2025.01.01 12:00:00 +0000CLIENT_IDauth-state", decodeSecret(t, u.Query().Get("client_secret")))
+
+	// V2 signs full scope string as well
+	orgClientV2 := newTestClientV2(t, "").WithScopes(scopes...)
+	authURL, err = orgClientV2.AuthCodeURL("auth-state")
+	require.NoError(t, err)
+	u, err = url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, "signed:CLIENT_ID"+scope+"2025.01.01 12:00:00 +0000auth-statehttps://example.com/callback", decodeSecret(t, u.Query().Get("client_secret")))
+}
+
+// go test -timeout 30s -run ^TestOrganizationAPI$ github.com/LdDl/esia-potato/esia
+func TestOrganizationAPI(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+
+	roles, err := api.Roles(ctx, 1000)
+	require.NoError(t, err)
+	require.Len(t, roles, 2)
+	assert.Equal(t, int64(1000298922), roles[0].OID)
+	assert.Equal(t, OrgLegal, roles[0].Type)
+	assert.Equal(t, "ООО \"РОМАШКА\"", roles[0].ShortName)
+	assert.True(t, roles[0].Chief)
+	assert.True(t, roles[0].Admin)
+	assert.Equal(t, OrgBusiness, roles[1].Type)
+	assert.False(t, roles[1].Admin)
+
+	org, err := api.Organization(ctx, roles[0].OID, "branches.elements")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000298922), org.OID)
+	assert.Equal(t, "7707083893", org.INN)
+	assert.Equal(t, "770701001", org.KPP)
+	assert.Equal(t, "1027700132195", org.OGRN)
+	require.NotNil(t, org.Branches)
+	require.Len(t, org.Branches.Elements, 1)
+	assert.Equal(t, "781001001", org.Branches.Elements[0].KPP)
+	assert.Nil(t, org.Employees)
+
+	employees, err := api.OrgEmployees(ctx, org.OID)
+	require.NoError(t, err)
+	require.Len(t, employees, 2)
+	assert.Equal(t, int64(1000), employees[0].OID)
+	assert.Equal(t, "Генеральный директор", employees[0].Position)
+	assert.True(t, employees[0].Chief)
+	assert.Equal(t, "+7(495)0000000", employees[1].Phone)
+
+	branches, err := api.OrgBranches(ctx, org.OID)
+	require.NoError(t, err)
+	require.Len(t, branches, 1)
+	assert.Equal(t, "Филиал в Санкт-Петербурге", branches[0].Name)
+
+	vehicles, err := api.OrgVehicles(ctx, org.OID)
+	require.NoError(t, err)
+	require.Len(t, vehicles, 1)
+	assert.Equal(t, "В123ВВ77", vehicles[0].NumberPlate)
+}
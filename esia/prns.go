@@ -0,0 +1,212 @@
+package esia
+
+import (
+	"context"
+	"strconv"
+)
+
+// Person data endpoints (require corresponding scopes, e.g. fullname, birthdate, contacts, addresses, id_doc)
+const (
+	personsPath   = "/rs/prns/"
+	contactsPath  = "/ctts"
+	addressesPath = "/addrs"
+	documentsPath = "/docs"
+	vehiclesPath  = "/vhls"
+	kidsPath      = "/kids"
+)
+
+// Contact types
+const (
+	ContactEmail     = "EML"
+	ContactMobile    = "MBT"
+	ContactPhone     = "PHN"
+	ContactWorkEmail = "CEM"
+	ContactHomePhone = "CPH"
+)
+
+// Verification status of contact or document
+const (
+	Verified    = "VERIFIED"
+	NotVerified = "NOT_VERIFIED"
+)
+
+// Address types
+const (
+	// Registration address
+	AddressRegistration = "PRG"
+	// Residence address
+	AddressResidence = "PLV"
+)
+
+// Document types
+const (
+	DocumentPassport        = "RF_PASSPORT"
+	DocumentForeignPassport = "FRGN_PASS"
+	DocumentDrivingLicense  = "RF_DRIVING_LICENSE"
+	DocumentBirthCert       = "RF_BRTH_CERT"
+	DocumentMedicalPolicy   = "MDCL_PLCY"
+)
+
+// Person is the user account returned by /rs/prns/{oid}.
+// Collections are filled only when requested with embed, e.g. Embed("contacts.elements").
+type Person struct {
+	StateFacts []string `json:"stateFacts,omitempty"`
+	FirstName  string   `json:"firstName,omitempty"`
+	LastName   string   `json:"lastName,omitempty"`
+	MiddleName string   `json:"middleName,omitempty"`
+	BirthDate  Date     `json:"birthDate,omitzero"`
+	BirthPlace string   `json:"birthPlace,omitempty"`
+	// M or F
+	Gender string `json:"gender,omitempty"`
+	// Account is confirmed
+	Trusted bool `json:"trusted"`
+	// Citizenship country code, e.g. RUS
+	Citizenship string `json:"citizenship,omitempty"`
+	SNILS       string `json:"snils,omitempty"`
+	INN         string `json:"inn,omitempty"`
+	// Id of identity document in documents collection
+	IDDocument int64  `json:"rIdDoc,omitempty"`
+	Status     string `json:"status,omitempty"`
+	Verifying  bool   `json:"verifying"`
+	// Unix time of last update
+	UpdatedOn int64  `json:"updatedOn,omitempty"`
+	ETag      string `json:"eTag,omitempty"`
+
+	Contacts  *Collection[Contact]  `json:"contacts,omitempty"`
+	Addresses *Collection[Address]  `json:"addresses,omitempty"`
+	Documents *Collection[Document] `json:"documents,omitempty"`
+	Vehicles  *Collection[Vehicle]  `json:"vehicles,omitempty"`
+	Kids      *Collection[Kid]      `json:"kids,omitempty"`
+}
+
+// Contact is element of /rs/prns/{oid}/ctts
+type Contact struct {
+	StateFacts []string `json:"stateFacts,omitempty"`
+	ID         int64    `json:"id"`
+	// ContactEmail, ContactMobile, ...
+	Type string `json:"type"`
+	// Verified or NotVerified
+	VerificationStatus string `json:"vrfStu,omitempty"`
+	Value              string `json:"value"`
+	ETag               string `json:"eTag,omitempty"`
+}
+
+// Verified reports whether contact is confirmed
+func (c *Contact) Verified() bool {
+	return c.VerificationStatus == Verified
+}
+
+// Address is element of /rs/prns/{oid}/addrs
+type Address struct {
+	StateFacts []string `json:"stateFacts,omitempty"`
+	ID         int64    `json:"id"`
+	// AddressRegistration or AddressResidence
+	Type string `json:"type"`
+	// Full address as a single line
+	AddressStr string `json:"addressStr,omitempty"`
+	CountryID  string `json:"countryId,omitempty"`
+	ZipCode    string `json:"zipCode,omitempty"`
+	Region     string `json:"region,omitempty"`
+	District   string `json:"district,omitempty"`
+	City       string `json:"city,omitempty"`
+	Settlement string `json:"settlement,omitempty"`
+	Street     string `json:"street,omitempty"`
+	House      string `json:"house,omitempty"`
+	Frame      string `json:"frame,omitempty"`
+	Building   string `json:"building,omitempty"`
+	Flat       string `json:"flat,omitempty"`
+	FIASCode   string `json:"fiasCode,omitempty"`
+	ETag       string `json:"eTag,omitempty"`
+}
+
+// Document is element of /rs/prns/{oid}/docs
+type Document struct {
+	StateFacts []string `json:"stateFacts,omitempty"`
+	ID         int64    `json:"id"`
+	// DocumentPassport, DocumentDrivingLicense, ...
+	Type               string `json:"type"`
+	VerificationStatus string `json:"vrfStu,omitempty"`
+	Series             string `json:"series,omitempty"`
+	Number             string `json:"number,omitempty"`
+	IssueDate          Date   `json:"issueDate,omitzero"`
+	// Code of issuing department
+	IssueID    string `json:"issueId,omitempty"`
+	IssuedBy   string `json:"issuedBy,omitempty"`
+	ExpiryDate Date   `json:"expiryDate,omitzero"`
+	ETag       string `json:"eTag,omitempty"`
+}
+
+// Vehicle is element of /rs/prns/{oid}/vhls
+type Vehicle struct {
+	StateFacts     []string               `json:"stateFacts,omitempty"`
+	ID             int64                  `json:"id"`
+	Name           string                 `json:"name,omitempty"`
+	NumberPlate    string                 `json:"numberPlate,omitempty"`
+	RegCertificate *VehicleRegCertificate `json:"regCertificate,omitempty"`
+	ETag           string                 `json:"eTag,omitempty"`
+}
+
+// VehicleRegCertificate is vehicle registration certificate
+type VehicleRegCertificate struct {
+	Series string `json:"series,omitempty"`
+	Number string `json:"number,omitempty"`
+}
+
+// Kid is element of /rs/prns/{oid}/kids
+type Kid struct {
+	StateFacts []string `json:"stateFacts,omitempty"`
+	ID         int64    `json:"id"`
+	FirstName  string   `json:"firstName,omitempty"`
+	LastName   string   `json:"lastName,omitempty"`
+	MiddleName string   `json:"middleName,omitempty"`
+	BirthDate  Date     `json:"birthDate,omitzero"`
+	Gender     string   `json:"gender,omitempty"`
+	SNILS      string   `json:"snils,omitempty"`
+	INN        string   `json:"inn,omitempty"`
+	ETag       string   `json:"eTag,omitempty"`
+	// Filled when requested with embed, e.g. Embed("documents.elements")
+	Documents *Collection[Document] `json:"documents,omitempty"`
+}
+
+func personPath(oid int64) string {
+	return personsPath + strconv.FormatInt(oid, 10)
+}
+
+// Person returns person data. Embed paths (e.g. "contacts.elements") expand collections in one request.
+func (a *API) Person(ctx context.Context, oid int64, embed ...string) (*Person, error) {
+	var person Person
+	if err := a.get(ctx, personPath(oid), embedQuery(embed), &person); err != nil {
+		return nil, err
+	}
+	return &person, nil
+}
+
+// Contacts returns all contacts of person
+func (a *API) Contacts(ctx context.Context, oid int64) ([]Contact, error) {
+	return collection[Contact](ctx, a, personPath(oid)+contactsPath)
+}
+
+// Addresses returns all addresses of person
+func (a *API) Addresses(ctx context.Context, oid int64) ([]Address, error) {
+	return collection[Address](ctx, a, personPath(oid)+addressesPath)
+}
+
+// Documents returns all documents of person
+func (a *API) Documents(ctx context.Context, oid int64) ([]Document, error) {
+	return collection[Document](ctx, a, personPath(oid)+documentsPath)
+}
+
+// Vehicles returns all vehicles of person
+func (a *API) Vehicles(ctx context.Context, oid int64) ([]Vehicle, error) {
+	return collection[Vehicle](ctx, a, personPath(oid)+vehiclesPath)
+}
+
+// Kids returns all children of person
+func (a *API) Kids(ctx context.Context, oid int64) ([]Kid, error) {
+	return collection[Kid](ctx, a, personPath(oid)+kidsPath)
+}
+
+// KidDocuments returns documents of child
+func (a *API) KidDocuments(ctx context.Context, oid, kidID int64) ([]Document, error) {
+	return collection[Document](ctx, a, personPath(oid)+kidsPath+"/"+strconv.FormatInt(kidID, 10)+documentsPath)
+}
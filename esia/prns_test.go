@@ -0,0 +1,177 @@
+package esia
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeREST serves recorded ESIA REST API responses from testdata
+func fakeREST(t *testing.T) *httptest.Server {
+	serve := func(w http.ResponseWriter, status int, fixture string) {
+		data, err := os.ReadFile(filepath.Join("testdata", fixture))
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write(data)
+	}
+	collections := map[string]string{
+		"/rs/prns/1000/docs":          "prns/docs.json",
+		"/rs/prns/1000/vhls":          "prns/vhls.json",
+		"/rs/prns/1000/kids":          "prns/kids.json",
+		"/rs/prns/1000/kids/901/docs": "prns/kid_docs.json",
+		"/rs/prns/1000/roles":         "orgs/roles.json",
+		"/rs/orgs/1000298922/emps":    "orgs/emps.json",
+		"/rs/orgs/1000298922/brhs":    "orgs/brhs.json",
+		"/rs/orgs/1000298922/vhls":    "orgs/vhls.json",
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rs/", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		if r.Header.Get("Authorization") != "Bearer access" {
+			serve(w, http.StatusUnauthorized, "prns/forbidden.json")
+			return
+		}
+		q := r.URL.Query()
+		switch r.URL.Path {
+		case "/rs/prns/1000":
+			if q.Get("embed") == "(contacts.elements,documents.elements)" {
+				serve(w, http.StatusOK, "prns/person_embed.json")
+				return
+			}
+			assert.Empty(t, q.Get("embed"))
+			serve(w, http.StatusOK, "prns/person.json")
+		case "/rs/prns/1000/ctts":
+			assert.Equal(t, "(elements)", q.Get("embed"))
+			assert.Equal(t, "2", q.Get("pageSize"))
+			serve(w, http.StatusOK, "prns/ctts_page"+q.Get("pageIndex")+".json")
+		case "/rs/orgs/1000298922":
+			assert.Equal(t, "(branches.elements)", q.Get("embed"))
+			serve(w, http.StatusOK, "orgs/org.json")
+		case "/rs/prns/1000/addrs":
+			serve(w, http.StatusForbidden, "prns/forbidden.json")
+		default:
+			fixture, ok := collections[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			assert.Equal(t, "(elements)", q.Get("embed"))
+			serve(w, http.StatusOK, fixture)
+		}
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestAPI(t *testing.T) *API {
+	client := newTestClient(t, fakeREST(t).URL)
+	return client.API(&Token{AccessToken: "access", Expiry: testTime.Add(time.Hour)})
+}
+
+// go test -timeout 30s -run ^TestPerson$ github.com/LdDl/esia-potato/esia
+func TestPerson(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+
+	person, err := api.Person(ctx, 1000)
+	require.NoError(t, err)
+	assert.Equal(t, "Иван", person.FirstName)
+	assert.Equal(t, "Иванов", person.LastName)
+	assert.Equal(t, "Иванович", person.MiddleName)
+	assert.Equal(t, time.Date(1990, 2, 1, 0, 0, 0, 0, time.UTC), person.BirthDate.Time)
+	assert.Equal(t, "01.02.1990", person.BirthDate.String())
+	assert.Equal(t, "M", person.Gender)
+	assert.True(t, person.Trusted)
+	assert.Equal(t, "000-000-600 06", person.SNILS)
+	assert.Equal(t, "500100732259", person.INN)
+	assert.Equal(t, int64(3284), person.IDDocument)
+	assert.Equal(t, int64(1735732800), person.UpdatedOn)
+	assert.Nil(t, person.Contacts)
+
+	person, err = api.Person(ctx, 1000, "contacts.elements", "documents.elements")
+	require.NoError(t, err)
+	require.NotNil(t, person.Contacts)
+	require.Len(t, person.Contacts.Elements, 1)
+	assert.Equal(t, "ivanov@example.com", person.Contacts.Elements[0].Value)
+	require.NotNil(t, person.Documents)
+	require.Len(t, person.Documents.Elements, 1)
+	assert.Equal(t, person.IDDocument, person.Documents.Elements[0].ID)
+	assert.Nil(t, person.Addresses)
+}
+
+// go test -timeout 30s -run ^TestPersonCollections$ github.com/LdDl/esia-potato/esia
+func TestPersonCollections(t *testing.T) {
+	api := newTestAPI(t)
+	api.PageSize = 2
+	ctx := context.Background()
+
+	contacts, err := api.Contacts(ctx, 1000)
+	require.NoError(t, err)
+	require.Len(t, contacts, 3, "all pages should be fetched")
+	assert.Equal(t, ContactEmail, contacts[0].Type)
+	assert.True(t, contacts[0].Verified())
+	assert.Equal(t, ContactMobile, contacts[1].Type)
+	assert.Equal(t, "+7(900)0000000", contacts[1].Value)
+	assert.Equal(t, ContactWorkEmail, contacts[2].Type)
+	assert.False(t, contacts[2].Verified())
+
+	documents, err := api.Documents(ctx, 1000)
+	require.NoError(t, err)
+	require.Len(t, documents, 2)
+	assert.Equal(t, DocumentPassport, documents[0].Type)
+	assert.Equal(t, "4510", documents[0].Series)
+	assert.Equal(t, "123456", documents[0].Number)
+	assert.Equal(t, "15.03.2010", documents[0].IssueDate.String())
+	assert.True(t, documents[0].ExpiryDate.IsZero())
+	assert.Equal(t, DocumentDrivingLicense, documents[1].Type)
+	assert.Equal(t, time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), documents[1].ExpiryDate.Time)
+
+	vehicles, err := api.Vehicles(ctx, 1000)
+	require.NoError(t, err)
+	require.Len(t, vehicles, 1)
+	assert.Equal(t, "А001АА77", vehicles[0].NumberPlate)
+	require.NotNil(t, vehicles[0].RegCertificate)
+	assert.Equal(t, "7701", vehicles[0].RegCertificate.Series)
+
+	kids, err := api.Kids(ctx, 1000)
+	require.NoError(t, err)
+	require.Len(t, kids, 1)
+	assert.Equal(t, "Мария", kids[0].FirstName)
+	assert.Equal(t, "F", kids[0].Gender)
+
+	kidDocs, err := api.KidDocuments(ctx, 1000, kids[0].ID)
+	require.NoError(t, err)
+	require.Len(t, kidDocs, 1)
+	assert.Equal(t, DocumentBirthCert, kidDocs[0].Type)
+}
+
+// go test -timeout 30s -run ^TestAPIErrors$ github.com/LdDl/esia-potato/esia
+func TestAPIErrors(t *testing.T) {
+	api := newTestAPI(t)
+	ctx := context.Background()
+
+	_, err := api.Addresses(ctx, 1000)
+	var esiaErr *Error
+	require.ErrorAs(t, err, &esiaErr)
+	assert.Equal(t, http.StatusForbidden, esiaErr.StatusCode)
+	assert.Equal(t, "ESIA-005011", esiaErr.Code)
+	assert.Equal(t, "Access denied: scope addresses is not granted", esiaErr.Description)
+
+	_, err = api.Person(ctx, 2000)
+	require.ErrorAs(t, err, &esiaErr)
+	assert.Equal(t, http.StatusNotFound, esiaErr.StatusCode)
+
+	api.token = &Token{AccessToken: "access", Expiry: testTime}
+	_, err = api.Person(ctx, 1000)
+	assert.ErrorIs(t, err, ErrTokenExpired)
+}
@@ -0,0 +1,177 @@
+package esia
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrTokenExpired is returned by API calls made with expired access token
+var ErrTokenExpired = fmt.Errorf("access token is expired")
+
+// DefaultPageSize is number of collection elements requested per page
+const DefaultPageSize = 100
+
+// Limit for REST API response body
+const maxRESTResponseSize = 4 << 20
+
+// DateLayout is the format of dates in ESIA REST API
+const DateLayout = "02.01.2006"
+
+// Date is a calendar date in ESIA format (dd.mm.yyyy)
+type Date struct {
+	time.Time
+}
+
+// UnmarshalJSON parses date in DateLayout, empty string gives zero date
+func (d *Date) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return errors.Wrap(err, "date must be a string")
+	}
+	if s == "" {
+		d.Time = time.Time{}
+		return nil
+	}
+	t, err := time.Parse(DateLayout, s)
+	if err != nil {
+		return errors.Wrapf(err, "invalid date %q", s)
+	}
+	d.Time = t
+	return nil
+}
+
+// MarshalJSON formats date in DateLayout
+func (d Date) MarshalJSON() ([]byte, error) {
+	if d.IsZero() {
+		return []byte(`""`), nil
+	}
+	return json.Marshal(d.Format(DateLayout))
+}
+
+func (d Date) String() string {
+	return d.Format(DateLayout)
+}
+
+// Collection is ESIA REST API collection. Elements are present when embedded (see Embed).
+type Collection[T any] struct {
+	StateFacts []string `json:"stateFacts,omitempty"`
+	// Total number of elements
+	Size     int    `json:"size"`
+	ETag     string `json:"eTag,omitempty"`
+	Elements []T    `json:"elements"`
+}
+
+// API calls ESIA REST API on behalf of the user who owns the access token
+type API struct {
+	client *Client
+	token  *Token
+	// Collection page size, DefaultPageSize by default
+	PageSize int
+}
+
+// API returns REST API client using access token obtained by Exchange or Refresh
+func (c *Client) API(token *Token) *API {
+	return &API{
+		client:   c,
+		token:    token,
+		PageSize: DefaultPageSize,
+	}
+}
+
+// Embed builds value of embed parameter, e.g. Embed("contacts.elements", "addresses.elements")
+// gives (contacts.elements,addresses.elements)
+func Embed(paths ...string) string {
+	return "(" + strings.Join(paths, ",") + ")"
+}
+
+// embedQuery returns query with embed parameter or nil if there is nothing to embed
+func embedQuery(paths []string) url.Values {
+	if len(paths) == 0 {
+		return nil
+	}
+	return url.Values{"embed": {Embed(paths...)}}
+}
+
+// get requests path with query and decodes JSON response into out
+func (a *API) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	if a.token == nil || a.token.AccessToken == "" {
+		return errors.New("access token is required")
+	}
+	if a.token.Expired(a.client.now()) {
+		return ErrTokenExpired
+	}
+
+	target := a.client.cfg.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to create API request")
+	}
+	req.Header.Set("Authorization", "Bearer "+a.token.AccessToken)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := a.client.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "API request failed")
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRESTResponseSize))
+	if err != nil {
+		return errors.Wrap(err, "failed to read API response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return decodeAPIError(resp.StatusCode, body)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return errors.Wrapf(err, "failed to parse API response of %s", path)
+	}
+	return nil
+}
+
+// decodeAPIError converts REST API error ({"code": ..., "message": ...}) or OAuth2 error to *Error
+func decodeAPIError(statusCode int, body []byte) error {
+	var restErr struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &restErr); err == nil && restErr.Code != "" {
+		return &Error{StatusCode: statusCode, Code: restErr.Code, Description: restErr.Message}
+	}
+	return decodeError(statusCode, body)
+}
+
+// collection fetches all elements of collection at path page by page
+func collection[T any](ctx context.Context, a *API, path string) ([]T, error) {
+	pageSize := a.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	var elements []T
+	for page := 1; ; page++ {
+		query := url.Values{}
+		query.Set("embed", Embed("elements"))
+		query.Set("pageSize", strconv.Itoa(pageSize))
+		query.Set("pageIndex", strconv.Itoa(page))
+
+		var c Collection[T]
+		if err := a.get(ctx, path, query, &c); err != nil {
+			return nil, err
+		}
+		elements = append(elements, c.Elements...)
+		if len(c.Elements) == 0 || len(c.Elements) < pageSize || len(elements) >= c.Size {
+			return elements, nil
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package esia
+
+import (
+	"strconv"
+)
+
+// Person scopes
+const (
+	ScopeOpenID    = "openid"
+	ScopeFullName  = "fullname"
+	ScopeBirthDate = "birthdate"
+	ScopeGender    = "gender"
+	ScopeSNILS     = "snils"
+	ScopeINN       = "inn"
+	ScopeIDDoc     = "id_doc"
+	ScopeEmail     = "email"
+	ScopeMobile    = "mobile"
+	ScopeContacts  = "contacts"
+	ScopeAddresses = "addresses"
+	ScopeVehicles  = "vehicles"
+	ScopeKidName   = "kid_fullname"
+	// Organizations of user (/rs/prns/{oid}/roles)
+	ScopeUserOrgs = "usr_org"
+)
+
+// Organization scopes, used with OrgScope
+const (
+	ScopeOrgShortName       = "org_shortname"
+	ScopeOrgFullName        = "org_fullname"
+	ScopeOrgType            = "org_type"
+	ScopeOrgOGRN            = "org_ogrn"
+	ScopeOrgINN             = "org_inn"
+	ScopeOrgLeg             = "org_leg"
+	ScopeOrgKPP             = "org_kpp"
+	ScopeOrgAgencyType      = "org_agencytype"
+	ScopeOrgOKTMO           = "org_oktmo"
+	ScopeOrgContacts        = "org_ctts"
+	ScopeOrgAddresses       = "org_addrs"
+	ScopeOrgVehicles        = "org_vhls"
+	ScopeOrgEmployees       = "org_emps"
+	ScopeOrgBranches        = "org_brhs"
+	ScopeOrgBranchContacts  = "org_brhs_ctts"
+	ScopeOrgBranchAddresses = "org_brhs_addrs"
+)
+
+// Prefix of organization scopes
+const orgScopePrefix = "http://esia.gosuslugi.ru/"
+
+// OrgScope builds scope of organization data, e.g.
+// OrgScope(ScopeOrgFullName, 1000298922) gives http://esia.gosuslugi.ru/org_fullname?org_oid=1000298922
+func OrgScope(name string, orgOID int64) string {
+	return orgScopePrefix + name + "?org_oid=" + strconv.FormatInt(orgOID, 10)
+}
+
+// OrgScopes builds OrgScope for every name
+func OrgScopes(orgOID int64, names ...string) []string {
+	scopes := make([]string, len(names))
+	for i, name := range names {
+		scopes[i] = OrgScope(name, orgOID)
+	}
+	return scopes
+}
+
+// WithScopes returns copy of client requesting given scopes instead of configured ones,
+// e.g. to obtain token for organization selected by user:
+//
+//	orgClient := client.WithScopes(append([]string{esia.ScopeOpenID}, esia.OrgScopes(orgOID, esia.ScopeOrgFullName)...)...)
+//
+// Scopes are joined with space into scope parameter and signed message.
+func (c *Client) WithScopes(scopes ...string) *Client {
+	cp := *c
+	cp.cfg.Scopes = append([]string(nil), scopes...)
+	return &cp
+}
@@ -0,0 +1,67 @@
+package esia
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// State errors
+var (
+	ErrStateUnknown  = fmt.Errorf("unknown or already used state")
+	ErrStateExpired  = fmt.Errorf("state expired")
+	ErrStateMismatch = fmt.Errorf("state does not belong to this browser")
+)
+
+// StateStore keeps states of started authorizations until callback.
+// Implementations must be safe for concurrent use.
+type StateStore interface {
+	// Put saves state valid until expiry
+	Put(state string, expiry time.Time) error
+	// Take removes state, so it can be used only once, and returns its expiry.
+	// Returns ErrStateUnknown if state was not saved or already taken.
+	Take(state string) (time.Time, error)
+}
+
+// MemoryStateStore keeps states in memory. Expired states are purged on Put.
+type MemoryStateStore struct {
+	// Clock used for purging, defaults to time.Now
+	Now func() time.Time
+
+	mu     sync.Mutex
+	states map[string]time.Time
+}
+
+// NewMemoryStateStore creates empty in-memory state store
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{
+		Now:    time.Now,
+		states: make(map[string]time.Time),
+	}
+}
+
+// Put saves state valid until expiry
+func (s *MemoryStateStore) Put(state string, expiry time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.Now()
+	for st, exp := range s.states {
+		if now.After(exp) {
+			delete(s.states, st)
+		}
+	}
+	s.states[state] = expiry
+	return nil
+}
+
+// Take removes state and returns its expiry
+func (s *MemoryStateStore) Take(state string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expiry, ok := s.states[state]
+	if !ok {
+		return time.Time{}, ErrStateUnknown
+	}
+	delete(s.states, state)
+	return expiry, nil
+}
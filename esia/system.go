@@ -0,0 +1,69 @@
+package esia
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// System tokens are refreshed this long before expiry
+const systemTokenLeeway = time.Minute
+
+// systemTokens caches client_credentials tokens by scope string.
+// It is shared between copies of Client made by WithScopes.
+type systemTokens struct {
+	mu      sync.Mutex
+	entries map[string]*systemToken
+}
+
+// systemToken serializes requests of the same scopes, so concurrent callers wait for single request
+type systemToken struct {
+	mu    sync.Mutex
+	token *Token
+}
+
+func newSystemTokens() *systemTokens {
+	return &systemTokens{
+		entries: make(map[string]*systemToken),
+	}
+}
+
+func (s *systemTokens) entry(scope string) *systemToken {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[scope]
+	if !ok {
+		e = &systemToken{}
+		s.entries[scope] = e
+	}
+	return e
+}
+
+// SystemToken returns token of the information system itself (grant_type=client_credentials) for given scopes,
+// configured scopes are used if none given. Tokens are cached and requested again shortly before expiry.
+// It is safe for concurrent use; returned token is shared and must not be modified.
+func (c *Client) SystemToken(ctx context.Context, scopes ...string) (*Token, error) {
+	if len(scopes) > 0 {
+		c = c.WithScopes(scopes...)
+	}
+	e := c.systemTokens.entry(c.Scope())
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.token != nil && !e.token.Expired(c.now().Add(systemTokenLeeway)) {
+		return e.token, nil
+	}
+
+	params := url.Values{}
+	params.Set("grant_type", "client_credentials")
+	token, err := c.requestToken(ctx, params, "")
+	if err != nil {
+		return nil, err
+	}
+	// Tokens without lifetime are not cached
+	if !token.Expiry.IsZero() {
+		e.token = token
+	}
+	return token, nil
+}
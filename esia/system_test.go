@@ -0,0 +1,97 @@
+package esia
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -timeout 30s -run ^TestSystemToken$ github.com/LdDl/esia-potato/esia
+func TestSystemToken(t *testing.T) {
+	var requests atomic.Int32
+	server := fakeESIA(t, func(form url.Values) (int, interface{}) {
+		n := requests.Add(1)
+		assert.Equal(t, "client_credentials", form.Get("grant_type"))
+		assert.Empty(t, form.Get("code"))
+		assert.NotEmpty(t, form.Get("client_secret"))
+		// Let concurrent callers pile up
+		time.Sleep(10 * time.Millisecond)
+		return http.StatusOK, map[string]interface{}{
+			"access_token": fmt.Sprintf("system-%d:%s", n, form.Get("scope")),
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		}
+	})
+	client := newTestClient(t, server.URL)
+	now := testTime
+	client.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	tokens := make([]*Token, 20)
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			token, err := client.SystemToken(ctx)
+			assert.NoError(t, err)
+			tokens[i] = token
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), requests.Load(), "concurrent callers should share single request")
+	for _, token := range tokens {
+		require.NotNil(t, token)
+		assert.Equal(t, "system-1:openid fullname", token.AccessToken)
+	}
+
+	// Other scopes are cached separately, also through WithScopes copies
+	token, err := client.SystemToken(ctx, "openid", "email")
+	require.NoError(t, err)
+	assert.Equal(t, "system-2:openid email", token.AccessToken)
+	token, err = client.WithScopes("openid", "email").SystemToken(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "system-2:openid email", token.AccessToken)
+
+	// Still valid
+	now = testTime.Add(58 * time.Minute)
+	token, err = client.SystemToken(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "system-1:openid fullname", token.AccessToken)
+
+	// Refreshed before expiry
+	now = testTime.Add(59*time.Minute + 30*time.Second)
+	token, err = client.SystemToken(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "system-3:openid fullname", token.AccessToken)
+	assert.Equal(t, now.Add(time.Hour), token.Expiry)
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+// go test -timeout 30s -run ^TestSystemTokenError$ github.com/LdDl/esia-potato/esia
+func TestSystemTokenError(t *testing.T) {
+	var requests atomic.Int32
+	server := fakeESIA(t, func(form url.Values) (int, interface{}) {
+		requests.Add(1)
+		return http.StatusBadRequest, map[string]string{"error": "invalid_scope"}
+	})
+	client := newTestClient(t, server.URL)
+
+	_, err := client.SystemToken(context.Background())
+	var esiaErr *Error
+	require.ErrorAs(t, err, &esiaErr)
+	assert.Equal(t, "invalid_scope", esiaErr.Code)
+
+	// Failures are not cached
+	_, err = client.SystemToken(context.Background())
+	require.Error(t, err)
+	assert.Equal(t, int32(2), requests.Load())
+}
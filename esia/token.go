@@ -0,0 +1,161 @@
+package esia
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Limit for token endpoint response body
+const maxResponseSize = 1 << 20
+
+// Token is ESIA token endpoint response
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	TokenType    string `json:"token_type"`
+	// Lifetime of access token in seconds
+	ExpiresIn int64  `json:"expires_in"`
+	State     string `json:"state,omitempty"`
+	// Expiry is calculated from ExpiresIn when token is received
+	Expiry time.Time `json:"-"`
+}
+
+// Expired reports whether access token is expired at the given moment
+func (t *Token) Expired(now time.Time) bool {
+	return !t.Expiry.IsZero() && !now.Before(t.Expiry)
+}
+
+// Error is OAuth2 error returned by ESIA
+type Error struct {
+	// HTTP status code, zero for error returned to callback URL
+	StatusCode int `json:"-"`
+	// OAuth2 error code, e.g. invalid_grant
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *Error) Error() string {
+	msg := "esia: " + e.Code
+	if e.Description != "" {
+		msg += ": " + e.Description
+	}
+	// Errors passed to callback have no status
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(" (status %d)", e.StatusCode)
+	}
+	return msg
+}
+
+// Exchange exchanges authorization code for tokens
+func (c *Client) Exchange(ctx context.Context, code string) (*Token, error) {
+	if code == "" {
+		return nil, ErrCodeRequired
+	}
+	params := url.Values{}
+	params.Set("grant_type", "authorization_code")
+	params.Set("code", code)
+	return c.requestToken(ctx, params, code)
+}
+
+// Refresh obtains new tokens using refresh token
+func (c *Client) Refresh(ctx context.Context, refreshToken string) (*Token, error) {
+	if refreshToken == "" {
+		return nil, ErrRefreshRequired
+	}
+	params := url.Values{}
+	params.Set("grant_type", "refresh_token")
+	params.Set("refresh_token", refreshToken)
+	return c.requestToken(ctx, params, refreshToken)
+}
+
+// requestToken adds common signed parameters and posts them to token endpoint.
+// grant is the code or refresh token, it is signed in V2 flow.
+func (c *Client) requestToken(ctx context.Context, params url.Values, grant string) (*Token, error) {
+	if err := c.signParams(params, grant); err != nil {
+		return nil, err
+	}
+	path := tokenPath
+	if c.cfg.Version == V2 {
+		path = tokenPathV3
+	}
+	return c.postToken(ctx, path, params)
+}
+
+// signParams adds client_id, client_secret and parameters signed in it to params
+func (c *Client) signParams(params url.Values, grant string) error {
+	scope := c.Scope()
+	now := c.now()
+	timestamp := formatTimestamp(now)
+	state := c.newState()
+	clientSecret, err := c.clientSecret(scope, now, state, grant)
+	if err != nil {
+		return err
+	}
+
+	params.Set("client_id", c.cfg.ClientID)
+	params.Set("client_secret", clientSecret)
+	params.Set("redirect_uri", c.cfg.RedirectURI)
+	params.Set("scope", scope)
+	params.Set("state", state)
+	params.Set("timestamp", timestamp)
+	params.Set("token_type", "Bearer")
+	if c.cfg.Version == V2 {
+		params.Set("client_certificate_hash", c.cfg.CertificateHash)
+	}
+	return nil
+}
+
+// postToken sends form to token endpoint and decodes response
+func (c *Client) postToken(ctx context.Context, path string, params url.Values) (*Token, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.BaseURL+path, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create token request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "token request failed")
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read token response")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeError(resp.StatusCode, body)
+	}
+
+	var token Token
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, errors.Wrap(err, "failed to parse token response")
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("token response has no access_token")
+	}
+	if token.ExpiresIn > 0 {
+		token.Expiry = c.now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return &token, nil
+}
+
+// decodeError converts ESIA error response to *Error
+func decodeError(statusCode int, body []byte) error {
+	esiaErr := &Error{StatusCode: statusCode}
+	if err := json.Unmarshal(body, esiaErr); err != nil || esiaErr.Code == "" {
+		esiaErr.Code = http.StatusText(statusCode)
+	}
+	return esiaErr
+}
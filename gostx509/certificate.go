@@ -0,0 +1,267 @@
+// Package gostx509 parses X.509 certificates with GOST R 34.10 keys (Russian qualified certificates),
+// which crypto/x509 can't handle
+package gostx509
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/LdDl/esia-potato/cryptopro"
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/pkg/errors"
+)
+
+// Sentinel errors
+var (
+	ErrParse                  = fmt.Errorf("failed to parse certificate")
+	ErrUnsupportedPublicKey   = fmt.Errorf("unsupported public key algorithm")
+	ErrPublicKeySize          = fmt.Errorf("public key size does not match curve")
+	ErrCertificateExpired     = fmt.Errorf("certificate has expired")
+	ErrCertificateNotYetValid = fmt.Errorf("certificate is not yet valid")
+)
+
+// OIDs of GOST public key algorithms
+var (
+	// GOST R 34.10-2001
+	OIDPublicKeyGostR341001 = asn1.ObjectIdentifier{1, 2, 643, 2, 2, 19}
+	// GOST R 34.10-2012 256-bit
+	OIDPublicKeyGostR341012256 = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 1, 1}
+	// GOST R 34.10-2012 512-bit
+	OIDPublicKeyGostR341012512 = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 1, 2}
+)
+
+// OIDs of GOST signature algorithms
+var (
+	// GOST R 34.11-94 with GOST R 34.10-2001
+	OIDSignatureGostR341001 = asn1.ObjectIdentifier{1, 2, 643, 2, 2, 3}
+	// GOST R 34.10-2012 with GOST R 34.11-2012 (256 bit)
+	OIDSignatureGostR341012256 = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 3, 2}
+	// GOST R 34.10-2012 with GOST R 34.11-2012 (512 bit)
+	OIDSignatureGostR341012512 = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 3, 3}
+)
+
+// Certificate is a parsed GOST X.509 certificate
+type Certificate struct {
+	// Complete DER-encoded certificate
+	Raw                     []byte
+	RawTBSCertificate       []byte
+	RawSubjectPublicKeyInfo []byte
+	RawSubject              []byte
+	RawIssuer               []byte
+
+	// Version as in certificate (0 for v1, 2 for v3)
+	Version            int
+	SerialNumber       *big.Int
+	SignatureAlgorithm asn1.ObjectIdentifier
+	Signature          []byte
+
+	Issuer    Name
+	Subject   Name
+	NotBefore time.Time
+	NotAfter  time.Time
+
+	PublicKeyAlgorithm asn1.ObjectIdentifier
+	// Public key parameter set (curve) OID, key of cryptopro.CurveOID
+	CurveOID       string
+	DigestParamSet asn1.ObjectIdentifier
+	// Public key as little-endian X||Y
+	PublicKeyRaw []byte
+
+	KeyUsage              x509.KeyUsage
+	ExtKeyUsage           []asn1.ObjectIdentifier
+	BasicConstraintsValid bool
+	IsCA                  bool
+	// -1 if not set
+	MaxPathLen            int
+	SubjectKeyId          []byte
+	AuthorityKeyId        []byte
+	CRLDistributionPoints []string
+	OCSPServer            []string
+	IssuingCertificateURL []string
+	PolicyIdentifiers     []asn1.ObjectIdentifier
+	// Name of subject's signature tool (1.2.643.100.111)
+	SubjectSignTool string
+
+	Extensions []pkix.Extension
+	// Critical extensions this package does not understand
+	UnhandledCriticalExtensions []asn1.ObjectIdentifier
+}
+
+type rawCertificate struct {
+	Raw                asn1.RawContent
+	TBSCertificate     tbsCertificate
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	SignatureValue     asn1.BitString
+}
+
+type tbsCertificate struct {
+	Raw                asn1.RawContent
+	Version            int `asn1:"optional,explicit,default:0,tag:0"`
+	SerialNumber       *big.Int
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Issuer             asn1.RawValue
+	Validity           validity
+	Subject            asn1.RawValue
+	PublicKey          publicKeyInfo
+	UniqueID           asn1.BitString   `asn1:"optional,tag:1"`
+	SubjectUniqueID    asn1.BitString   `asn1:"optional,tag:2"`
+	Extensions         []pkix.Extension `asn1:"omitempty,optional,explicit,tag:3"`
+}
+
+type validity struct {
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+// publicKeyInfo is SubjectPublicKeyInfo of a GOST certificate
+type publicKeyInfo struct {
+	Raw       asn1.RawContent
+	Algorithm pkix.AlgorithmIdentifier
+	// DER-encoded OCTET STRING with little-endian X||Y
+	PublicKey asn1.BitString
+}
+
+// publicKeyParameters is GostR3410-2012-PublicKeyParameters (and its 2001 predecessor)
+type publicKeyParameters struct {
+	PublicKeyParamSet  asn1.ObjectIdentifier
+	DigestParamSet     asn1.ObjectIdentifier `asn1:"optional"`
+	EncryptionParamSet asn1.ObjectIdentifier `asn1:"optional"`
+}
+
+// ParseCertificate parses single DER-encoded certificate
+func ParseCertificate(der []byte) (*Certificate, error) {
+	var raw rawCertificate
+	rest, err := asn1.Unmarshal(der, &raw)
+	if err != nil {
+		return nil, errors.Wrap(ErrParse, err.Error())
+	}
+	if len(rest) > 0 {
+		return nil, errors.Wrap(ErrParse, "trailing data")
+	}
+
+	tbs := &raw.TBSCertificate
+	cert := &Certificate{
+		Raw:                     raw.Raw,
+		RawTBSCertificate:       tbs.Raw,
+		RawSubjectPublicKeyInfo: tbs.PublicKey.Raw,
+		RawSubject:              tbs.Subject.FullBytes,
+		RawIssuer:               tbs.Issuer.FullBytes,
+		Version:                 tbs.Version,
+		SerialNumber:            tbs.SerialNumber,
+		SignatureAlgorithm:      raw.SignatureAlgorithm.Algorithm,
+		Signature:               raw.SignatureValue.RightAlign(),
+		NotBefore:               tbs.Validity.NotBefore,
+		NotAfter:                tbs.Validity.NotAfter,
+		PublicKeyAlgorithm:      tbs.PublicKey.Algorithm.Algorithm,
+		MaxPathLen:              -1,
+		Extensions:              tbs.Extensions,
+	}
+
+	if cert.Issuer, err = parseName(cert.RawIssuer); err != nil {
+		return nil, errors.Wrap(ErrParse, "issuer: "+err.Error())
+	}
+	if cert.Subject, err = parseName(cert.RawSubject); err != nil {
+		return nil, errors.Wrap(ErrParse, "subject: "+err.Error())
+	}
+	if err := cert.parsePublicKeyInfo(&tbs.PublicKey); err != nil {
+		return nil, err
+	}
+	if err := cert.parseExtensions(); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// ParseCertificates parses concatenated DER-encoded certificates
+func ParseCertificates(der []byte) ([]*Certificate, error) {
+	var certs []*Certificate
+	for len(der) > 0 {
+		var raw asn1.RawValue
+		rest, err := asn1.Unmarshal(der, &raw)
+		if err != nil {
+			return nil, errors.Wrap(ErrParse, err.Error())
+		}
+		cert, err := ParseCertificate(raw.FullBytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+		der = rest
+	}
+	return certs, nil
+}
+
+func (c *Certificate) parsePublicKeyInfo(spki *publicKeyInfo) error {
+	alg := spki.Algorithm.Algorithm
+	if !alg.Equal(OIDPublicKeyGostR341001) && !alg.Equal(OIDPublicKeyGostR341012256) && !alg.Equal(OIDPublicKeyGostR341012512) {
+		return errors.Wrapf(ErrUnsupportedPublicKey, "oid: %s", alg)
+	}
+
+	var params publicKeyParameters
+	if _, err := asn1.Unmarshal(spki.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return errors.Wrap(ErrParse, "public key parameters: "+err.Error())
+	}
+	c.CurveOID = params.PublicKeyParamSet.String()
+	c.DigestParamSet = params.DigestParamSet
+
+	if _, err := asn1.Unmarshal(spki.PublicKey.RightAlign(), &c.PublicKeyRaw); err != nil {
+		return errors.Wrap(ErrParse, "public key: "+err.Error())
+	}
+	return nil
+}
+
+// PublicKey converts certificate public key to gogost public key
+func (c *Certificate) PublicKey() (*gost3410.PublicKey, error) {
+	curve, ok := cryptopro.CurveOID[c.CurveOID]
+	if !ok {
+		return nil, errors.Wrapf(cryptopro.ErrCurveOIDUnknown, "oid: %s", c.CurveOID)
+	}
+	if len(c.PublicKeyRaw) != 2*cryptopro.KeySize(c.CurveOID) {
+		return nil, errors.Wrapf(ErrPublicKeySize, "%d bytes for curve %s", len(c.PublicKeyRaw), c.CurveOID)
+	}
+	pub, err := gost3410.NewPublicKey(curve, cryptopro.KeyMode(c.CurveOID), c.PublicKeyRaw)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create public key")
+	}
+	return pub, nil
+}
+
+// CheckValidity checks that t is within certificate validity period
+func (c *Certificate) CheckValidity(t time.Time) error {
+	if t.Before(c.NotBefore) {
+		return errors.Wrapf(ErrCertificateNotYetValid, "valid from %s", c.NotBefore.Format(time.RFC3339))
+	}
+	if t.After(c.NotAfter) {
+		return errors.Wrapf(ErrCertificateExpired, "valid until %s", c.NotAfter.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// ListsExtKeyUsage reports whether extended key usage extension contains given usage itself.
+// Unlike HasExtKeyUsage, missing extension and anyExtendedKeyUsage don't count.
+func (c *Certificate) ListsExtKeyUsage(oid asn1.ObjectIdentifier) bool {
+	for _, usage := range c.ExtKeyUsage {
+		if usage.Equal(oid) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasExtKeyUsage reports whether certificate allows given extended key usage.
+// Certificates without extended key usage extension allow any usage.
+func (c *Certificate) HasExtKeyUsage(oid asn1.ObjectIdentifier) bool {
+	if len(c.ExtKeyUsage) == 0 {
+		return true
+	}
+	for _, usage := range c.ExtKeyUsage {
+		if usage.Equal(oid) || usage.Equal(OIDExtKeyUsageAny) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,196 @@
+package gostx509
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"testing"
+	"time"
+
+	"github.com/LdDl/esia-potato/internal/gosttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// qualifiedSubject is a subject of qualified certificate of legal entity employee
+func qualifiedSubject() pkix.RDNSequence {
+	numeric := func(oid asn1.ObjectIdentifier, value string) pkix.RelativeDistinguishedNameSET {
+		return pkix.RelativeDistinguishedNameSET{{Type: oid, Value: asn1.RawValue{Tag: asn1.TagNumericString, Bytes: []byte(value)}}}
+	}
+	utf8 := func(oid asn1.ObjectIdentifier, value string) pkix.RelativeDistinguishedNameSET {
+		return pkix.RelativeDistinguishedNameSET{{Type: oid, Value: value}}
+	}
+	return pkix.RDNSequence{
+		utf8(OIDCountry, "RU"),
+		utf8(OIDProvince, "77 г. Москва"),
+		utf8(OIDLocality, "Москва"),
+		utf8(OIDOrganization, "ООО \"Ромашка\""),
+		utf8(OIDTitle, "Генеральный директор"),
+		utf8(OIDSurname, "Иванов"),
+		utf8(OIDGivenName, "Иван Иванович"),
+		numeric(OIDINN, "771234567890"),
+		numeric(OIDINNLE, "7712345678"),
+		numeric(OIDOGRN, "1027700000000"),
+		numeric(OIDSNILS, "12345678901"),
+		pkix.RelativeDistinguishedNameSET{{Type: OIDEmailAddress, Value: asn1.RawValue{Tag: asn1.TagIA5String, Bytes: []byte("ivanov@example.com")}}},
+		utf8(OIDCommonName, "ООО \"Ромашка\""),
+	}
+}
+
+// go test -timeout 30s -run ^TestParseCertificate$ github.com/LdDl/esia-potato/gostx509
+func TestParseCertificate(t *testing.T) {
+	caKey := gosttest.PrivateKey(t)
+	key := gosttest.PrivateKey(t)
+
+	keyUsage := asn1.BitString{Bytes: []byte{0xf0}, BitLength: 4}
+	crlDP := []distributionPoint{{DistributionPoint: distributionPointName{FullName: []asn1.RawValue{
+		{Class: asn1.ClassContextSpecific, Tag: generalNameURI, Bytes: []byte("http://ca.example.com/ca.crl")},
+	}}}}
+	aia := []accessDescription{
+		{Method: OIDAuthorityInfoAccessOCSP, Location: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: generalNameURI, Bytes: []byte("http://ca.example.com/ocsp")}},
+		{Method: OIDAuthorityInfoAccessCAIssuers, Location: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: generalNameURI, Bytes: []byte("http://ca.example.com/ca.crt")}},
+	}
+	tmpl := gosttest.Template{
+		Serial:  0x1234,
+		Subject: qualifiedSubject(),
+		Issuer:  gosttest.Name("Test CA"),
+		Extensions: []pkix.Extension{
+			gosttest.Extension(t, OIDExtensionKeyUsage, true, keyUsage),
+			gosttest.Extension(t, OIDExtensionExtendedKeyUsage, false, []asn1.ObjectIdentifier{OIDExtKeyUsageClientAuth, OIDExtKeyUsageEmailProtection}),
+			gosttest.Extension(t, OIDExtensionBasicConstraints, true, basicConstraints{MaxPathLen: -1}),
+			gosttest.Extension(t, OIDExtensionSubjectKeyId, false, []byte{0x01, 0x02}),
+			gosttest.Extension(t, OIDExtensionAuthorityKeyId, false, authKeyId{ID: []byte{0x03, 0x04}}),
+			gosttest.Extension(t, OIDExtensionCRLDistributionPoints, false, crlDP),
+			gosttest.Extension(t, OIDExtensionAuthorityInfoAccess, false, aia),
+			gosttest.Extension(t, OIDExtensionCertificatePolicies, false, []policyInformation{{Policy: asn1.ObjectIdentifier{1, 2, 643, 100, 113, 1}}}),
+			gosttest.Extension(t, OIDExtensionSubjectSignTool, false, "КриптоПРО CSP (версия 5.0)"),
+		},
+	}
+	der := gosttest.Certificate(t, tmpl, key, caKey)
+
+	cert, err := ParseCertificate(der)
+	require.NoError(t, err)
+
+	assert.Equal(t, der, cert.Raw)
+	assert.Equal(t, 2, cert.Version)
+	assert.Equal(t, int64(0x1234), cert.SerialNumber.Int64())
+	assert.True(t, cert.SignatureAlgorithm.Equal(OIDSignatureGostR341012256))
+	assert.Len(t, cert.Signature, 64)
+
+	assert.Equal(t, "Test CA", cert.Issuer.CommonName)
+	assert.Equal(t, "ООО \"Ромашка\"", cert.Subject.CommonName)
+	assert.Equal(t, "Иванов", cert.Subject.Surname)
+	assert.Equal(t, "Иван Иванович", cert.Subject.GivenName)
+	assert.Equal(t, "Генеральный директор", cert.Subject.Title)
+	assert.Equal(t, "RU", cert.Subject.Country)
+	assert.Equal(t, "771234567890", cert.Subject.INN)
+	assert.Equal(t, "7712345678", cert.Subject.INNLE)
+	assert.Equal(t, "1027700000000", cert.Subject.OGRN)
+	assert.Equal(t, "12345678901", cert.Subject.SNILS)
+	assert.Equal(t, "ivanov@example.com", cert.Subject.Email)
+	assert.Len(t, cert.Subject.Names, 13)
+	assert.Contains(t, cert.Subject.String(), "INN=771234567890")
+	assert.Contains(t, cert.Subject.String(), "SNILS=12345678901")
+
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), cert.NotBefore)
+	assert.Equal(t, time.Date(2034, 1, 1, 0, 0, 0, 0, time.UTC), cert.NotAfter)
+
+	assert.True(t, cert.PublicKeyAlgorithm.Equal(OIDPublicKeyGostR341012256))
+	assert.Equal(t, "1.2.643.2.2.35.1", cert.CurveOID)
+
+	assert.Equal(t, x509.KeyUsageDigitalSignature|x509.KeyUsageContentCommitment|x509.KeyUsageKeyEncipherment|x509.KeyUsageDataEncipherment, cert.KeyUsage)
+	assert.True(t, cert.HasExtKeyUsage(OIDExtKeyUsageClientAuth))
+	assert.False(t, cert.HasExtKeyUsage(OIDExtKeyUsageServerAuth))
+	assert.True(t, cert.ListsExtKeyUsage(OIDExtKeyUsageClientAuth))
+	assert.False(t, (&Certificate{}).ListsExtKeyUsage(OIDExtKeyUsageClientAuth), "missing extension allows any usage but lists none")
+	assert.False(t, (&Certificate{ExtKeyUsage: []asn1.ObjectIdentifier{OIDExtKeyUsageAny}}).ListsExtKeyUsage(OIDExtKeyUsageClientAuth))
+	assert.True(t, cert.BasicConstraintsValid)
+	assert.False(t, cert.IsCA)
+	assert.Equal(t, -1, cert.MaxPathLen)
+	assert.Equal(t, []byte{0x01, 0x02}, cert.SubjectKeyId)
+	assert.Equal(t, []byte{0x03, 0x04}, cert.AuthorityKeyId)
+	assert.Equal(t, []string{"http://ca.example.com/ca.crl"}, cert.CRLDistributionPoints)
+	assert.Equal(t, []string{"http://ca.example.com/ocsp"}, cert.OCSPServer)
+	assert.Equal(t, []string{"http://ca.example.com/ca.crt"}, cert.IssuingCertificateURL)
+	require.Len(t, cert.PolicyIdentifiers, 1)
+	assert.Equal(t, "1.2.643.100.113.1", cert.PolicyIdentifiers[0].String())
+	assert.Equal(t, "КриптоПРО CSP (версия 5.0)", cert.SubjectSignTool)
+	assert.Empty(t, cert.UnhandledCriticalExtensions)
+
+	pub, err := cert.PublicKey()
+	require.NoError(t, err)
+	expected, err := key.PublicKey()
+	require.NoError(t, err)
+	assert.Equal(t, expected.Raw(), pub.Raw())
+}
+
+// go test -timeout 30s -run ^TestParseCertificate512$ github.com/LdDl/esia-potato/gostx509
+func TestParseCertificate512(t *testing.T) {
+	key := gosttest.PrivateKey512(t)
+	der := gosttest.Certificate(t, gosttest.Template{Serial: 1, Subject: gosttest.Name("Test 512")}, key, key)
+
+	cert, err := ParseCertificate(der)
+	require.NoError(t, err)
+	assert.True(t, cert.PublicKeyAlgorithm.Equal(OIDPublicKeyGostR341012512))
+	assert.True(t, cert.SignatureAlgorithm.Equal(OIDSignatureGostR341012512))
+	assert.Equal(t, "1.2.643.7.1.2.1.2.1", cert.CurveOID)
+	assert.Len(t, cert.PublicKeyRaw, 128)
+	assert.Equal(t, -1, cert.MaxPathLen)
+	assert.False(t, cert.BasicConstraintsValid)
+
+	pub, err := cert.PublicKey()
+	require.NoError(t, err)
+	expected, err := key.PublicKey()
+	require.NoError(t, err)
+	assert.Equal(t, expected.Raw(), pub.Raw())
+}
+
+// go test -timeout 30s -run ^TestParseCertificateFailures$ github.com/LdDl/esia-potato/gostx509
+func TestParseCertificateFailures(t *testing.T) {
+	key := gosttest.PrivateKey(t)
+	der := gosttest.Certificate(t, gosttest.Template{Serial: 1, Subject: gosttest.Name("Test")}, key, key)
+
+	_, err := ParseCertificate(nil)
+	assert.ErrorIs(t, err, ErrParse)
+
+	_, err = ParseCertificate(append(der, 0x00))
+	assert.ErrorIs(t, err, ErrParse, "trailing data should be rejected")
+
+	_, err = ParseCertificate(der[:len(der)-1])
+	assert.ErrorIs(t, err, ErrParse)
+
+	// Unknown critical extension is reported, not rejected
+	tmpl := gosttest.Template{
+		Serial:     2,
+		Subject:    gosttest.Name("Test"),
+		Extensions: []pkix.Extension{{Id: asn1.ObjectIdentifier{1, 2, 3, 4}, Critical: true, Value: []byte{0x05, 0x00}}},
+	}
+	cert, err := ParseCertificate(gosttest.Certificate(t, tmpl, key, key))
+	require.NoError(t, err)
+	require.Len(t, cert.UnhandledCriticalExtensions, 1)
+	assert.Equal(t, "1.2.3.4", cert.UnhandledCriticalExtensions[0].String())
+}
+
+// go test -timeout 30s -run ^TestCheckValidity$ github.com/LdDl/esia-potato/gostx509
+func TestCheckValidity(t *testing.T) {
+	key := gosttest.PrivateKey(t)
+	cert, err := ParseCertificate(gosttest.Certificate(t, gosttest.Template{Serial: 1, Subject: gosttest.Name("Test")}, key, key))
+	require.NoError(t, err)
+
+	assert.NoError(t, cert.CheckValidity(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)))
+	assert.ErrorIs(t, cert.CheckValidity(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)), ErrCertificateNotYetValid)
+	assert.ErrorIs(t, cert.CheckValidity(time.Date(2035, 1, 1, 0, 0, 0, 0, time.UTC)), ErrCertificateExpired)
+}
+
+// go test -timeout 30s -run ^TestParseCertificates$ github.com/LdDl/esia-potato/gostx509
+func TestParseCertificates(t *testing.T) {
+	key := gosttest.PrivateKey(t)
+	first := gosttest.Certificate(t, gosttest.Template{Serial: 1, Subject: gosttest.Name("First")}, key, key)
+	second := gosttest.Certificate(t, gosttest.Template{Serial: 2, Subject: gosttest.Name("Second")}, key, key)
+
+	certs, err := ParseCertificates(append(append([]byte{}, first...), second...))
+	require.NoError(t, err)
+	require.Len(t, certs, 2)
+	assert.Equal(t, "First", certs[0].Subject.CommonName)
+	assert.Equal(t, "Second", certs[1].Subject.CommonName)
+}
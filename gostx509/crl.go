@@ -0,0 +1,199 @@
+package gostx509
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Sentinel errors of CRL handling
+var (
+	ErrCRLParse          = fmt.Errorf("failed to parse CRL")
+	ErrCRLIssuerMismatch = fmt.Errorf("CRL is not issued by certificate issuer")
+)
+
+// OIDs of CRL extensions
+var (
+	OIDExtensionCRLNumber  = asn1.ObjectIdentifier{2, 5, 29, 20}
+	OIDExtensionReasonCode = asn1.ObjectIdentifier{2, 5, 29, 21}
+)
+
+// RevokedCertificate is an entry of CRL
+type RevokedCertificate struct {
+	SerialNumber   *big.Int
+	RevocationTime time.Time
+	// CRLReason code (RFC 5280, 5.3.1), 0 (unspecified) if absent
+	ReasonCode int
+}
+
+// RevocationList is a parsed GOST-signed CRL
+type RevocationList struct {
+	Raw                  []byte
+	RawTBSRevocationList []byte
+	RawIssuer            []byte
+
+	Issuer             Name
+	SignatureAlgorithm asn1.ObjectIdentifier
+	Signature          []byte
+	ThisUpdate         time.Time
+	// Zero if absent
+	NextUpdate          time.Time
+	Number              *big.Int
+	AuthorityKeyId      []byte
+	RevokedCertificates []RevokedCertificate
+	Extensions          []pkix.Extension
+}
+
+type rawCertificateList struct {
+	Raw                asn1.RawContent
+	TBSCertList        tbsCertList
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	SignatureValue     asn1.BitString
+}
+
+type tbsCertList struct {
+	Raw                 asn1.RawContent
+	Version             int `asn1:"optional,default:0"`
+	Signature           pkix.AlgorithmIdentifier
+	Issuer              asn1.RawValue
+	ThisUpdate          time.Time
+	NextUpdate          time.Time            `asn1:"optional"`
+	RevokedCertificates []revokedCertificate `asn1:"optional"`
+	Extensions          []pkix.Extension     `asn1:"tag:0,optional,explicit"`
+}
+
+type revokedCertificate struct {
+	SerialNumber   *big.Int
+	RevocationTime time.Time
+	Extensions     []pkix.Extension `asn1:"optional"`
+}
+
+// ParseRevocationList parses DER-encoded CRL
+func ParseRevocationList(der []byte) (*RevocationList, error) {
+	var raw rawCertificateList
+	rest, err := asn1.Unmarshal(der, &raw)
+	if err != nil {
+		return nil, errors.Wrap(ErrCRLParse, err.Error())
+	}
+	if len(rest) > 0 {
+		return nil, errors.Wrap(ErrCRLParse, "trailing data")
+	}
+
+	tbs := &raw.TBSCertList
+	crl := &RevocationList{
+		Raw:                  raw.Raw,
+		RawTBSRevocationList: tbs.Raw,
+		RawIssuer:            tbs.Issuer.FullBytes,
+		SignatureAlgorithm:   raw.SignatureAlgorithm.Algorithm,
+		Signature:            raw.SignatureValue.RightAlign(),
+		ThisUpdate:           tbs.ThisUpdate,
+		NextUpdate:           tbs.NextUpdate,
+		Extensions:           tbs.Extensions,
+	}
+	if crl.Issuer, err = parseName(crl.RawIssuer); err != nil {
+		return nil, errors.Wrap(ErrCRLParse, "issuer: "+err.Error())
+	}
+
+	for _, ext := range tbs.Extensions {
+		switch {
+		case ext.Id.Equal(OIDExtensionCRLNumber):
+			_, err = asn1.Unmarshal(ext.Value, &crl.Number)
+		case ext.Id.Equal(OIDExtensionAuthorityKeyId):
+			var aki authKeyId
+			if _, err = asn1.Unmarshal(ext.Value, &aki); err == nil {
+				crl.AuthorityKeyId = aki.ID
+			}
+		}
+		if err != nil {
+			return nil, errors.Wrapf(ErrCRLParse, "extension %s: %s", ext.Id, err.Error())
+		}
+	}
+
+	crl.RevokedCertificates = make([]RevokedCertificate, 0, len(tbs.RevokedCertificates))
+	for _, rc := range tbs.RevokedCertificates {
+		entry := RevokedCertificate{
+			SerialNumber:   rc.SerialNumber,
+			RevocationTime: rc.RevocationTime,
+		}
+		for _, ext := range rc.Extensions {
+			if !ext.Id.Equal(OIDExtensionReasonCode) {
+				continue
+			}
+			var reason asn1.Enumerated
+			if _, err := asn1.Unmarshal(ext.Value, &reason); err != nil {
+				return nil, errors.Wrap(ErrCRLParse, "reason code: "+err.Error())
+			}
+			entry.ReasonCode = int(reason)
+		}
+		crl.RevokedCertificates = append(crl.RevokedCertificates, entry)
+	}
+	return crl, nil
+}
+
+// ParseRevocationListFile parses CRL from DER, PEM ("X509 CRL" block) or bare base64 data
+func ParseRevocationListFile(data []byte) (*RevocationList, error) {
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("-----BEGIN")) {
+		block, _ := pem.Decode(trimmed)
+		if block == nil || block.Type != "X509 CRL" {
+			return nil, errors.Wrap(ErrCRLParse, "no X509 CRL block in PEM")
+		}
+		return ParseRevocationList(block.Bytes)
+	}
+	if len(trimmed) > 0 && trimmed[0] != 0x30 {
+		der, err := base64.StdEncoding.DecodeString(string(bytes.Join(bytes.Fields(trimmed), nil)))
+		if err != nil {
+			return nil, errors.Wrap(ErrCRLParse, "neither DER, PEM nor base64")
+		}
+		return ParseRevocationList(der)
+	}
+	return ParseRevocationList(data)
+}
+
+// CheckSignatureFrom verifies that CRL is issued and signed by issuer
+func (crl *RevocationList) CheckSignatureFrom(issuer *Certificate) error {
+	if !bytes.Equal(crl.RawIssuer, issuer.RawSubject) {
+		return errors.Wrapf(ErrCRLIssuerMismatch, "CRL issuer %q, certificate %q", crl.Issuer.CommonName, issuer.Subject.CommonName)
+	}
+	if issuer.KeyUsage != 0 && issuer.KeyUsage&x509.KeyUsageCRLSign == 0 {
+		return errors.Wrapf(ErrKeyUsage, "certificate %q can't sign CRLs", issuer.Subject.CommonName)
+	}
+	return issuer.CheckSignature(crl.SignatureAlgorithm, crl.RawTBSRevocationList, crl.Signature)
+}
+
+// IsCurrent reports whether t is within [ThisUpdate, NextUpdate]. CRL without NextUpdate is always current after ThisUpdate
+func (crl *RevocationList) IsCurrent(t time.Time) bool {
+	if t.Before(crl.ThisUpdate) {
+		return false
+	}
+	return crl.NextUpdate.IsZero() || !t.After(crl.NextUpdate)
+}
+
+// newerThan reports whether crl is issued after other: by thisUpdate, then by CRL number
+func (crl *RevocationList) newerThan(other *RevocationList) bool {
+	if !crl.ThisUpdate.Equal(other.ThisUpdate) {
+		return crl.ThisUpdate.After(other.ThisUpdate)
+	}
+	if crl.Number != nil && other.Number != nil {
+		return crl.Number.Cmp(other.Number) > 0
+	}
+	return false
+}
+
+// Find returns CRL entry for serial number
+func (crl *RevocationList) Find(serial *big.Int) (*RevokedCertificate, bool) {
+	for i := range crl.RevokedCertificates {
+		if crl.RevokedCertificates[i].SerialNumber.Cmp(serial) == 0 {
+			return &crl.RevokedCertificates[i], true
+		}
+	}
+	return nil, false
+}
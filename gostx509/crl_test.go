@@ -0,0 +1,319 @@
+package gostx509
+
+import (
+	"context"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LdDl/esia-potato/internal/gosttest"
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testTBSCertList struct {
+	Version             int
+	Signature           pkix.AlgorithmIdentifier
+	Issuer              pkix.RDNSequence
+	ThisUpdate          time.Time
+	NextUpdate          time.Time            `asn1:"optional"`
+	RevokedCertificates []revokedCertificate `asn1:"optional"`
+	Extensions          []pkix.Extension     `asn1:"tag:0,optional,explicit"`
+}
+
+var (
+	testThisUpdate = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	testNextUpdate = time.Date(2025, 1, 8, 0, 0, 0, 0, time.UTC)
+	testCheckTime  = time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+)
+
+// createTestCRL builds CRL of issuer revoking given serial numbers (reason keyCompromise)
+func createTestCRL(t *testing.T, issuerKey *gost3410.PrivateKey, issuer pkix.RDNSequence, serials ...int64) []byte {
+	return createTestCRLAt(t, issuerKey, issuer, testThisUpdate, 42, serials...)
+}
+
+// createTestCRLAt builds CRL with given thisUpdate and CRL number
+func createTestCRLAt(t *testing.T, issuerKey *gost3410.PrivateKey, issuer pkix.RDNSequence, thisUpdate time.Time, number int64, serials ...int64) []byte {
+	reason, err := asn1.Marshal(asn1.Enumerated(1))
+	require.NoError(t, err)
+
+	var revoked []revokedCertificate
+	for _, serial := range serials {
+		revoked = append(revoked, revokedCertificate{
+			SerialNumber:   big.NewInt(serial),
+			RevocationTime: thisUpdate.Add(-time.Hour),
+			Extensions:     []pkix.Extension{{Id: OIDExtensionReasonCode, Value: reason}},
+		})
+	}
+
+	sigAlg := gosttest.SignatureAlgorithm(issuerKey)
+	tbsDER, err := asn1.Marshal(testTBSCertList{
+		Version:             1,
+		Signature:           sigAlg,
+		Issuer:              issuer,
+		ThisUpdate:          thisUpdate,
+		NextUpdate:          testNextUpdate,
+		RevokedCertificates: revoked,
+		Extensions:          []pkix.Extension{gosttest.Extension(t, OIDExtensionCRLNumber, false, big.NewInt(number))},
+	})
+	require.NoError(t, err)
+
+	return gosttest.Signed(t, tbsDER, sigAlg, issuerKey)
+}
+
+// createTestLeaf issues certificate by intermediate CA of pki with given CRL distribution point
+func createTestLeaf(t *testing.T, pki *testPKI, serial int64, crlURL string) *Certificate {
+	var extensions []pkix.Extension
+	if crlURL != "" {
+		crlDP := []distributionPoint{{DistributionPoint: distributionPointName{FullName: []asn1.RawValue{
+			{Class: asn1.ClassContextSpecific, Tag: generalNameURI, Bytes: []byte(crlURL)},
+		}}}}
+		extensions = append(extensions, gosttest.Extension(t, OIDExtensionCRLDistributionPoints, false, crlDP))
+	}
+	return mustParse(t, gosttest.Certificate(t, gosttest.Template{
+		Serial:     serial,
+		Subject:    gosttest.Name("Leaf"),
+		Issuer:     gosttest.Name("Test Intermediate CA"),
+		Extensions: extensions,
+	}, gosttest.PrivateKey(t), pki.intermediateKey))
+}
+
+func testClock() time.Time {
+	return testCheckTime
+}
+
+// go test -timeout 30s -run ^TestParseRevocationList$ github.com/LdDl/esia-potato/gostx509
+func TestParseRevocationList(t *testing.T) {
+	pki := createTestPKI(t)
+	der := createTestCRL(t, pki.intermediateKey, gosttest.Name("Test Intermediate CA"), 3, 10)
+
+	crl, err := ParseRevocationList(der)
+	require.NoError(t, err)
+	assert.Equal(t, "Test Intermediate CA", crl.Issuer.CommonName)
+	assert.Equal(t, testThisUpdate, crl.ThisUpdate)
+	assert.Equal(t, testNextUpdate, crl.NextUpdate)
+	assert.Equal(t, int64(42), crl.Number.Int64())
+	require.Len(t, crl.RevokedCertificates, 2)
+	assert.Equal(t, 1, crl.RevokedCertificates[0].ReasonCode)
+
+	entry, ok := crl.Find(big.NewInt(10))
+	require.True(t, ok)
+	assert.Equal(t, int64(10), entry.SerialNumber.Int64())
+	_, ok = crl.Find(big.NewInt(11))
+	assert.False(t, ok)
+
+	assert.True(t, crl.IsCurrent(testCheckTime))
+	assert.False(t, crl.IsCurrent(testNextUpdate.Add(time.Second)))
+
+	assert.NoError(t, crl.CheckSignatureFrom(pki.intermediate))
+	assert.ErrorIs(t, crl.CheckSignatureFrom(pki.root), ErrCRLIssuerMismatch)
+
+	// Same issuer name, different key
+	forged, err := ParseRevocationList(createTestCRL(t, gosttest.PrivateKey(t), gosttest.Name("Test Intermediate CA")))
+	require.NoError(t, err)
+	assert.ErrorIs(t, forged.CheckSignatureFrom(pki.intermediate), ErrSignatureInvalid)
+
+	// PEM form
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})
+	fromPEM, err := ParseRevocationListFile(pemData)
+	require.NoError(t, err)
+	assert.Equal(t, crl.Raw, fromPEM.Raw)
+
+	_, err = ParseRevocationList(der[:len(der)-1])
+	assert.ErrorIs(t, err, ErrCRLParse)
+}
+
+// go test -timeout 30s -run ^TestCRLCheckerLocal$ github.com/LdDl/esia-potato/gostx509
+func TestCRLCheckerLocal(t *testing.T) {
+	pki := createTestPKI(t)
+
+	dir := t.TempDir()
+	crlDER := createTestCRL(t, pki.intermediateKey, gosttest.Name("Test Intermediate CA"), 3)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "intermediate.crl"), crlDER, 0644))
+
+	checker := NewCRLChecker(nil)
+	checker.Now = testClock
+	require.NoError(t, checker.LoadDir(dir))
+
+	// Serial 3 is revoked
+	err := checker.CheckRevocation(context.Background(), pki.leaf, pki.intermediate)
+	assert.ErrorIs(t, err, ErrRevoked)
+
+	assert.NoError(t, checker.CheckRevocation(context.Background(), createTestLeaf(t, pki, 100, ""), pki.intermediate))
+
+	// No CRL of root
+	err = checker.CheckRevocation(context.Background(), pki.intermediate, pki.root)
+	assert.ErrorIs(t, err, ErrRevocationUnknown)
+
+	// Outdated CRL is not used
+	checker.Now = func() time.Time { return testNextUpdate.Add(time.Hour) }
+	err = checker.CheckRevocation(context.Background(), pki.leaf, pki.intermediate)
+	assert.ErrorIs(t, err, ErrRevocationUnknown)
+}
+
+// go test -timeout 30s -run ^TestCRLCheckerNewest$ github.com/LdDl/esia-potato/gostx509
+func TestCRLCheckerNewest(t *testing.T) {
+	pki := createTestPKI(t)
+	issuer := gosttest.Name("Test Intermediate CA")
+	parse := func(der []byte) *RevocationList {
+		crl, err := ParseRevocationList(der)
+		require.NoError(t, err)
+		return crl
+	}
+	older := parse(createTestCRLAt(t, pki.intermediateKey, issuer, testThisUpdate, 42, 3))
+	newer := parse(createTestCRLAt(t, pki.intermediateKey, issuer, testThisUpdate.Add(12*time.Hour), 43, 3, 100))
+	sameTime := parse(createTestCRLAt(t, pki.intermediateKey, issuer, testThisUpdate, 43, 3, 100))
+	leaf := createTestLeaf(t, pki, 100, "")
+
+	tests := []struct {
+		name string
+		crls []*RevocationList
+	}{
+		{"newer added last", []*RevocationList{older, newer}},
+		{"newer added first", []*RevocationList{newer, older}},
+		{"same thisUpdate, greater number", []*RevocationList{older, sameTime}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Zero value is usable
+			checker := &CRLChecker{Now: testClock}
+			for _, crl := range tt.crls {
+				checker.AddCRL(crl)
+			}
+			err := checker.CheckRevocation(context.Background(), leaf, pki.intermediate)
+			assert.ErrorIs(t, err, ErrRevoked, "serial 100 is listed only in newer CRL")
+		})
+	}
+}
+
+// staticFetcher returns the same CRL for any URL
+type staticFetcher []byte
+
+func (f staticFetcher) FetchCRL(ctx context.Context, url string) ([]byte, error) {
+	return f, nil
+}
+
+// go test -timeout 30s -run ^TestCRLCheckerZeroValue$ github.com/LdDl/esia-potato/gostx509
+func TestCRLCheckerZeroValue(t *testing.T) {
+	pki := createTestPKI(t)
+	leaf := createTestLeaf(t, pki, 101, "http://ca.example.com/ca.crl")
+
+	// Default clock, no CRLs
+	err := (&CRLChecker{}).CheckRevocation(context.Background(), leaf, pki.intermediate)
+	assert.ErrorIs(t, err, ErrRevocationUnknown)
+
+	// Downloaded CRL is cached without NewCRLChecker
+	checker := &CRLChecker{
+		Fetcher: staticFetcher(createTestCRL(t, pki.intermediateKey, gosttest.Name("Test Intermediate CA"), 101)),
+		Now:     testClock,
+	}
+	err = checker.CheckRevocation(context.Background(), leaf, pki.intermediate)
+	assert.ErrorIs(t, err, ErrRevoked)
+	assert.Len(t, checker.cache, 1)
+}
+
+// go test -timeout 30s -run ^TestCRLCheckerFetch$ github.com/LdDl/esia-potato/gostx509
+func TestCRLCheckerFetch(t *testing.T) {
+	pki := createTestPKI(t)
+	crlDER := createTestCRL(t, pki.intermediateKey, gosttest.Name("Test Intermediate CA"), 101)
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.URL.Path != "/ca.crl" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pkix-crl")
+		w.Write(crlDER)
+	}))
+	defer server.Close()
+
+	checker := NewCRLChecker(&HTTPCRLFetcher{Client: server.Client()})
+	checker.Now = testClock
+
+	assert.NoError(t, checker.CheckRevocation(context.Background(), createTestLeaf(t, pki, 100, server.URL+"/ca.crl"), pki.intermediate))
+	err := checker.CheckRevocation(context.Background(), createTestLeaf(t, pki, 101, server.URL+"/ca.crl"), pki.intermediate)
+	assert.ErrorIs(t, err, ErrRevoked)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests), "CRL should be cached until next update")
+
+	err = checker.CheckRevocation(context.Background(), createTestLeaf(t, pki, 102, server.URL+"/missing.crl"), pki.intermediate)
+	assert.ErrorIs(t, err, ErrRevocationUnknown)
+
+	// CRL signed by another key is rejected
+	forged := NewCRLChecker(&HTTPCRLFetcher{Client: server.Client()})
+	forged.Now = testClock
+	err = forged.CheckRevocation(context.Background(), createTestLeaf(t, pki, 100, server.URL+"/ca.crl"), pki.root)
+	assert.ErrorIs(t, err, ErrRevocationUnknown)
+}
+
+// blockingFetcher holds every download until release is closed
+type blockingFetcher struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (f *blockingFetcher) FetchCRL(ctx context.Context, url string) ([]byte, error) {
+	close(f.started)
+	<-f.release
+	return nil, errors.New("unavailable")
+}
+
+// go test -timeout 30s -run ^TestCRLCheckerSlowFetch$ github.com/LdDl/esia-potato/gostx509
+func TestCRLCheckerSlowFetch(t *testing.T) {
+	pki := createTestPKI(t)
+	crl, err := ParseRevocationList(createTestCRL(t, pki.intermediateKey, gosttest.Name("Test Intermediate CA"), 3))
+	require.NoError(t, err)
+
+	fetcher := &blockingFetcher{started: make(chan struct{}), release: make(chan struct{})}
+	checker := NewCRLChecker(fetcher)
+	checker.Now = testClock
+
+	// Root CRL is downloaded from slow distribution point
+	leaf := createTestLeaf(t, pki, 100, "http://slow.example.com/ca.crl")
+	slow := make(chan error, 1)
+	go func() {
+		slow <- checker.CheckRevocation(context.Background(), leaf, pki.root)
+	}()
+	<-fetcher.started
+
+	// Check with local CRL is not blocked by download in progress
+	checker.AddCRL(crl)
+	assert.ErrorIs(t, checker.CheckRevocation(context.Background(), pki.leaf, pki.intermediate), ErrRevoked)
+
+	close(fetcher.release)
+	assert.ErrorIs(t, <-slow, ErrRevocationUnknown)
+}
+
+// go test -timeout 30s -run ^TestRevocationCheckers$ github.com/LdDl/esia-potato/gostx509
+func TestRevocationCheckers(t *testing.T) {
+	pki := createTestPKI(t)
+
+	empty := NewCRLChecker(nil)
+	empty.Now = testClock
+	local := NewCRLChecker(nil)
+	local.Now = testClock
+	crl, err := ParseRevocationList(createTestCRL(t, pki.intermediateKey, gosttest.Name("Test Intermediate CA"), 3))
+	require.NoError(t, err)
+	local.AddCRL(crl)
+
+	// Unknown status of first checker falls through to next one
+	checkers := RevocationCheckers{empty, local}
+	assert.ErrorIs(t, checkers.CheckRevocation(context.Background(), pki.leaf, pki.intermediate), ErrRevoked)
+	assert.NoError(t, checkers.CheckRevocation(context.Background(), createTestLeaf(t, pki, 100, ""), pki.intermediate))
+
+	err = RevocationCheckers{empty}.CheckRevocation(context.Background(), pki.leaf, pki.intermediate)
+	assert.ErrorIs(t, err, ErrRevocationUnknown)
+	err = RevocationCheckers{}.CheckRevocation(context.Background(), pki.leaf, pki.intermediate)
+	assert.ErrorIs(t, err, ErrRevocationUnknown)
+}
@@ -0,0 +1,169 @@
+package gostx509
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+
+	"github.com/pkg/errors"
+)
+
+// OIDs of certificate extensions
+var (
+	OIDExtensionSubjectKeyId          = asn1.ObjectIdentifier{2, 5, 29, 14}
+	OIDExtensionKeyUsage              = asn1.ObjectIdentifier{2, 5, 29, 15}
+	OIDExtensionBasicConstraints      = asn1.ObjectIdentifier{2, 5, 29, 19}
+	OIDExtensionCRLDistributionPoints = asn1.ObjectIdentifier{2, 5, 29, 31}
+	OIDExtensionCertificatePolicies   = asn1.ObjectIdentifier{2, 5, 29, 32}
+	OIDExtensionAuthorityKeyId        = asn1.ObjectIdentifier{2, 5, 29, 35}
+	OIDExtensionExtendedKeyUsage      = asn1.ObjectIdentifier{2, 5, 29, 37}
+	OIDExtensionAuthorityInfoAccess   = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 1}
+	// Signature tool of certificate owner
+	OIDExtensionSubjectSignTool = asn1.ObjectIdentifier{1, 2, 643, 100, 111}
+	// Signature tool and CA tool of issuer
+	OIDExtensionIssuerSignTool = asn1.ObjectIdentifier{1, 2, 643, 100, 112}
+
+	OIDAuthorityInfoAccessOCSP      = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1}
+	OIDAuthorityInfoAccessCAIssuers = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 2}
+)
+
+// OIDs of extended key usages
+var (
+	OIDExtKeyUsageAny             = asn1.ObjectIdentifier{2, 5, 29, 37, 0}
+	OIDExtKeyUsageServerAuth      = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 1}
+	OIDExtKeyUsageClientAuth      = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 2}
+	OIDExtKeyUsageEmailProtection = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 4}
+	OIDExtKeyUsageTimeStamping    = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 8}
+	OIDExtKeyUsageOCSPSigning     = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 9}
+)
+
+type basicConstraints struct {
+	IsCA       bool `asn1:"optional"`
+	MaxPathLen int  `asn1:"optional,default:-1"`
+}
+
+type authKeyId struct {
+	ID []byte `asn1:"optional,tag:0"`
+}
+
+type distributionPoint struct {
+	DistributionPoint distributionPointName `asn1:"optional,tag:0"`
+	Reason            asn1.BitString        `asn1:"optional,tag:1"`
+	CRLIssuer         asn1.RawValue         `asn1:"optional,tag:2"`
+}
+
+type distributionPointName struct {
+	FullName     []asn1.RawValue  `asn1:"optional,tag:0"`
+	RelativeName pkix.RDNSequence `asn1:"optional,tag:1"`
+}
+
+type accessDescription struct {
+	Method   asn1.ObjectIdentifier
+	Location asn1.RawValue
+}
+
+type policyInformation struct {
+	Policy     asn1.ObjectIdentifier
+	Qualifiers asn1.RawValue `asn1:"optional"`
+}
+
+// GeneralName uniformResourceIdentifier tag
+const generalNameURI = 6
+
+func (c *Certificate) parseExtensions() error {
+	for _, ext := range c.Extensions {
+		var err error
+		switch {
+		case ext.Id.Equal(OIDExtensionKeyUsage):
+			err = c.parseKeyUsage(ext.Value)
+		case ext.Id.Equal(OIDExtensionExtendedKeyUsage):
+			_, err = asn1.Unmarshal(ext.Value, &c.ExtKeyUsage)
+		case ext.Id.Equal(OIDExtensionBasicConstraints):
+			var bc basicConstraints
+			if _, err = asn1.Unmarshal(ext.Value, &bc); err == nil {
+				c.BasicConstraintsValid = true
+				c.IsCA = bc.IsCA
+				c.MaxPathLen = bc.MaxPathLen
+			}
+		case ext.Id.Equal(OIDExtensionSubjectKeyId):
+			_, err = asn1.Unmarshal(ext.Value, &c.SubjectKeyId)
+		case ext.Id.Equal(OIDExtensionAuthorityKeyId):
+			var aki authKeyId
+			if _, err = asn1.Unmarshal(ext.Value, &aki); err == nil {
+				c.AuthorityKeyId = aki.ID
+			}
+		case ext.Id.Equal(OIDExtensionCRLDistributionPoints):
+			err = c.parseCRLDistributionPoints(ext.Value)
+		case ext.Id.Equal(OIDExtensionAuthorityInfoAccess):
+			err = c.parseAuthorityInfoAccess(ext.Value)
+		case ext.Id.Equal(OIDExtensionCertificatePolicies):
+			var policies []policyInformation
+			if _, err = asn1.Unmarshal(ext.Value, &policies); err == nil {
+				for _, p := range policies {
+					c.PolicyIdentifiers = append(c.PolicyIdentifiers, p.Policy)
+				}
+			}
+		case ext.Id.Equal(OIDExtensionSubjectSignTool):
+			_, err = asn1.Unmarshal(ext.Value, &c.SubjectSignTool)
+		case ext.Id.Equal(OIDExtensionIssuerSignTool):
+			// Informational only
+		default:
+			if ext.Critical {
+				c.UnhandledCriticalExtensions = append(c.UnhandledCriticalExtensions, ext.Id)
+			}
+		}
+		if err != nil {
+			return errors.Wrapf(ErrParse, "extension %s: %s", ext.Id, err.Error())
+		}
+	}
+	return nil
+}
+
+func (c *Certificate) parseKeyUsage(value []byte) error {
+	var bits asn1.BitString
+	if _, err := asn1.Unmarshal(value, &bits); err != nil {
+		return err
+	}
+	var usage x509.KeyUsage
+	for i := 0; i < 9; i++ {
+		if bits.At(i) != 0 {
+			usage |= 1 << uint(i)
+		}
+	}
+	c.KeyUsage = usage
+	return nil
+}
+
+func (c *Certificate) parseCRLDistributionPoints(value []byte) error {
+	var points []distributionPoint
+	if _, err := asn1.Unmarshal(value, &points); err != nil {
+		return err
+	}
+	for _, dp := range points {
+		for _, name := range dp.DistributionPoint.FullName {
+			if name.Class == asn1.ClassContextSpecific && name.Tag == generalNameURI {
+				c.CRLDistributionPoints = append(c.CRLDistributionPoints, string(name.Bytes))
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Certificate) parseAuthorityInfoAccess(value []byte) error {
+	var descriptions []accessDescription
+	if _, err := asn1.Unmarshal(value, &descriptions); err != nil {
+		return err
+	}
+	for _, ad := range descriptions {
+		if ad.Location.Class != asn1.ClassContextSpecific || ad.Location.Tag != generalNameURI {
+			continue
+		}
+		switch {
+		case ad.Method.Equal(OIDAuthorityInfoAccessOCSP):
+			c.OCSPServer = append(c.OCSPServer, string(ad.Location.Bytes))
+		case ad.Method.Equal(OIDAuthorityInfoAccessCAIssuers):
+			c.IssuingCertificateURL = append(c.IssuingCertificateURL, string(ad.Location.Bytes))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package gostx509
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"strings"
+)
+
+// OIDs of name attributes
+var (
+	OIDCommonName         = asn1.ObjectIdentifier{2, 5, 4, 3}
+	OIDSurname            = asn1.ObjectIdentifier{2, 5, 4, 4}
+	OIDSerialNumber       = asn1.ObjectIdentifier{2, 5, 4, 5}
+	OIDCountry            = asn1.ObjectIdentifier{2, 5, 4, 6}
+	OIDLocality           = asn1.ObjectIdentifier{2, 5, 4, 7}
+	OIDProvince           = asn1.ObjectIdentifier{2, 5, 4, 8}
+	OIDStreetAddress      = asn1.ObjectIdentifier{2, 5, 4, 9}
+	OIDOrganization       = asn1.ObjectIdentifier{2, 5, 4, 10}
+	OIDOrganizationalUnit = asn1.ObjectIdentifier{2, 5, 4, 11}
+	OIDTitle              = asn1.ObjectIdentifier{2, 5, 4, 12}
+	OIDGivenName          = asn1.ObjectIdentifier{2, 5, 4, 42}
+	OIDEmailAddress       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 1}
+
+	// Russian attributes of qualified certificates (FSB order No. 795)
+	// INN of individual
+	OIDINN = asn1.ObjectIdentifier{1, 2, 643, 3, 131, 1, 1}
+	// INN of legal entity
+	OIDINNLE = asn1.ObjectIdentifier{1, 2, 643, 100, 4}
+	// OGRN of legal entity
+	OIDOGRN = asn1.ObjectIdentifier{1, 2, 643, 100, 1}
+	// OGRNIP of individual entrepreneur
+	OIDOGRNIP = asn1.ObjectIdentifier{1, 2, 643, 100, 5}
+	// SNILS
+	OIDSNILS = asn1.ObjectIdentifier{1, 2, 643, 100, 3}
+)
+
+// Name is a distinguished name of qualified certificate subject or issuer
+type Name struct {
+	CommonName         string
+	Surname            string
+	GivenName          string
+	Title              string
+	Organization       string
+	OrganizationalUnit string
+	Country            string
+	Province           string
+	Locality           string
+	StreetAddress      string
+	SerialNumber       string
+	Email              string
+
+	INN    string
+	INNLE  string
+	OGRN   string
+	OGRNIP string
+	SNILS  string
+
+	// All attributes in order of appearance, including unknown ones
+	Names []pkix.AttributeTypeAndValue
+}
+
+// nameFields maps attribute OIDs to short names (used by String) and Name fields
+var nameFields = []struct {
+	oid   asn1.ObjectIdentifier
+	short string
+	field func(n *Name) *string
+}{
+	{OIDCommonName, "CN", func(n *Name) *string { return &n.CommonName }},
+	{OIDSurname, "SN", func(n *Name) *string { return &n.Surname }},
+	{OIDGivenName, "G", func(n *Name) *string { return &n.GivenName }},
+	{OIDTitle, "T", func(n *Name) *string { return &n.Title }},
+	{OIDOrganization, "O", func(n *Name) *string { return &n.Organization }},
+	{OIDOrganizationalUnit, "OU", func(n *Name) *string { return &n.OrganizationalUnit }},
+	{OIDCountry, "C", func(n *Name) *string { return &n.Country }},
+	{OIDProvince, "S", func(n *Name) *string { return &n.Province }},
+	{OIDLocality, "L", func(n *Name) *string { return &n.Locality }},
+	{OIDStreetAddress, "STREET", func(n *Name) *string { return &n.StreetAddress }},
+	{OIDSerialNumber, "SERIALNUMBER", func(n *Name) *string { return &n.SerialNumber }},
+	{OIDEmailAddress, "E", func(n *Name) *string { return &n.Email }},
+	{OIDINN, "INN", func(n *Name) *string { return &n.INN }},
+	{OIDINNLE, "INNLE", func(n *Name) *string { return &n.INNLE }},
+	{OIDOGRN, "OGRN", func(n *Name) *string { return &n.OGRN }},
+	{OIDOGRNIP, "OGRNIP", func(n *Name) *string { return &n.OGRNIP }},
+	{OIDSNILS, "SNILS", func(n *Name) *string { return &n.SNILS }},
+}
+
+// parseName parses DER-encoded Name (RDNSequence)
+func parseName(der []byte) (Name, error) {
+	var rdns pkix.RDNSequence
+	rest, err := asn1.Unmarshal(der, &rdns)
+	if err != nil {
+		return Name{}, err
+	}
+	if len(rest) > 0 {
+		return Name{}, fmt.Errorf("trailing data after name")
+	}
+
+	var name Name
+	for _, rdn := range rdns {
+		for _, atv := range rdn {
+			name.Names = append(name.Names, atv)
+			value, ok := atv.Value.(string)
+			if !ok {
+				continue
+			}
+			for _, f := range nameFields {
+				if atv.Type.Equal(f.oid) {
+					*f.field(&name) = value
+					break
+				}
+			}
+		}
+	}
+	return name, nil
+}
+
+// String returns name in "CN=..., O=..., INN=..." form
+func (n Name) String() string {
+	parts := make([]string, 0, len(n.Names))
+	for _, atv := range n.Names {
+		key := atv.Type.String()
+		for _, f := range nameFields {
+			if atv.Type.Equal(f.oid) {
+				key = f.short
+				break
+			}
+		}
+		parts = append(parts, fmt.Sprintf("%s=%v", key, atv.Value))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,170 @@
+package gostx509
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// CertPool is a set of certificates indexed by subject
+type CertPool struct {
+	certs     []*Certificate
+	bySubject map[string][]*Certificate
+}
+
+// NewCertPool creates empty pool
+func NewCertPool() *CertPool {
+	return &CertPool{
+		bySubject: make(map[string][]*Certificate),
+	}
+}
+
+// AddCert adds certificate to pool. Duplicates are ignored
+func (p *CertPool) AddCert(cert *Certificate) {
+	for _, c := range p.bySubject[string(cert.RawSubject)] {
+		if bytes.Equal(c.Raw, cert.Raw) {
+			return
+		}
+	}
+	p.certs = append(p.certs, cert)
+	p.bySubject[string(cert.RawSubject)] = append(p.bySubject[string(cert.RawSubject)], cert)
+}
+
+// Certificates returns all certificates of pool
+func (p *CertPool) Certificates() []*Certificate {
+	return append([]*Certificate(nil), p.certs...)
+}
+
+// Len returns number of certificates in pool
+func (p *CertPool) Len() int {
+	return len(p.certs)
+}
+
+// findIssuers returns candidate issuers of cert. Candidates with subject key id
+// matching cert authority key id go first
+func (p *CertPool) findIssuers(cert *Certificate) []*Certificate {
+	candidates := p.bySubject[string(cert.RawIssuer)]
+	if len(cert.AuthorityKeyId) == 0 {
+		return candidates
+	}
+	sorted := make([]*Certificate, 0, len(candidates))
+	var rest []*Certificate
+	for _, c := range candidates {
+		if bytes.Equal(c.SubjectKeyId, cert.AuthorityKeyId) {
+			sorted = append(sorted, c)
+		} else {
+			rest = append(rest, c)
+		}
+	}
+	return append(sorted, rest...)
+}
+
+// ParseCertificatesFile parses certificates from DER, PEM (one or more CERTIFICATE blocks)
+// or bare base64 data, as exported by CryptoPro and Windows certificate manager
+func ParseCertificatesFile(data []byte) ([]*Certificate, error) {
+	trimmed := bytes.TrimSpace(data)
+
+	if bytes.HasPrefix(trimmed, []byte("-----BEGIN")) {
+		var certs []*Certificate
+		rest := trimmed
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			cert, err := ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			certs = append(certs, cert)
+		}
+		if len(certs) == 0 {
+			return nil, errors.Wrap(ErrParse, "no CERTIFICATE blocks in PEM")
+		}
+		return certs, nil
+	}
+
+	if len(trimmed) > 0 && trimmed[0] != 0x30 {
+		der, err := base64.StdEncoding.DecodeString(string(bytes.Join(bytes.Fields(trimmed), nil)))
+		if err != nil {
+			return nil, errors.Wrap(ErrParse, "neither DER, PEM nor base64")
+		}
+		return ParseCertificates(der)
+	}
+	return ParseCertificates(data)
+}
+
+// TrustStore holds trusted roots and intermediate CA certificates
+type TrustStore struct {
+	Roots         *CertPool
+	Intermediates *CertPool
+}
+
+// NewTrustStore creates empty trust store
+func NewTrustStore() *TrustStore {
+	return &TrustStore{
+		Roots:         NewCertPool(),
+		Intermediates: NewCertPool(),
+	}
+}
+
+// LoadTrustStore reads every file of dir (DER or PEM) and adds its certificates to trust store.
+// Self-signed certificates become roots, the rest become intermediates. Subdirectories are ignored.
+func LoadTrustStore(dir string) (*TrustStore, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read trust store directory")
+	}
+
+	ts := NewTrustStore()
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, f.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", path)
+		}
+		certs, err := ParseCertificatesFile(data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "file %s", path)
+		}
+		for _, cert := range certs {
+			ts.AddCert(cert)
+		}
+	}
+	return ts, nil
+}
+
+// AddCert adds self-signed certificate to roots, other certificates to intermediates
+func (ts *TrustStore) AddCert(cert *Certificate) {
+	if cert.IsSelfSigned() {
+		ts.Roots.AddCert(cert)
+		return
+	}
+	ts.Intermediates.AddCert(cert)
+}
+
+// Verify validates chain of cert at given time. Extra untrusted intermediates
+// (e.g. certificates embedded into CMS) may be provided
+func (ts *TrustStore) Verify(cert *Certificate, opts VerifyOptions, extra ...*Certificate) ([]*Certificate, error) {
+	intermediates := NewCertPool()
+	for _, c := range ts.Intermediates.certs {
+		intermediates.AddCert(c)
+	}
+	for _, c := range extra {
+		intermediates.AddCert(c)
+	}
+	opts.Roots = ts.Roots
+	opts.Intermediates = intermediates
+	return cert.Verify(opts)
+}
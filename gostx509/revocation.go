@@ -0,0 +1,234 @@
+package gostx509
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Sentinel errors of revocation checking
+var (
+	ErrRevoked           = fmt.Errorf("certificate is revoked")
+	ErrRevocationUnknown = fmt.Errorf("revocation status is unknown")
+)
+
+// Largest CRL accepted from network
+const maxCRLSize = 64 << 20
+
+// RevocationChecker checks revocation status of certificate issued by issuer.
+// It returns error wrapping ErrRevoked for revoked certificates and ErrRevocationUnknown
+// if status can't be determined.
+type RevocationChecker interface {
+	CheckRevocation(ctx context.Context, cert, issuer *Certificate) error
+}
+
+// RevocationCheckers tries checkers in order and returns first definite answer:
+// nil for good certificate or ErrRevoked. If every checker fails, last error is returned.
+type RevocationCheckers []RevocationChecker
+
+// CheckRevocation implements RevocationChecker
+func (rc RevocationCheckers) CheckRevocation(ctx context.Context, cert, issuer *Certificate) error {
+	err := errors.Wrap(ErrRevocationUnknown, "no revocation checkers")
+	for _, checker := range rc {
+		err = checker.CheckRevocation(ctx, cert, issuer)
+		if err == nil || errors.Is(err, ErrRevoked) {
+			return err
+		}
+	}
+	return err
+}
+
+// CRLFetcher downloads DER-encoded CRL from distribution point URL
+type CRLFetcher interface {
+	FetchCRL(ctx context.Context, url string) ([]byte, error)
+}
+
+// HTTPCRLFetcher downloads CRLs over HTTP
+type HTTPCRLFetcher struct {
+	// Defaults to http.DefaultClient
+	Client *http.Client
+}
+
+// FetchCRL downloads CRL with GET request
+func (f *HTTPCRLFetcher) FetchCRL(ctx context.Context, url string) ([]byte, error) {
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create CRL request")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to download CRL")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("failed to download CRL: status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCRLSize))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read CRL")
+	}
+	return data, nil
+}
+
+// CRLChecker checks revocation with CRLs. Locally loaded CRLs are used first;
+// if there is no current CRL of the issuer and Fetcher is set, CRL is downloaded
+// from certificate distribution points and cached until its NextUpdate.
+type CRLChecker struct {
+	// Optional fetcher for CRL distribution points
+	Fetcher CRLFetcher
+	// Clock, defaults to time.Now
+	Now func() time.Time
+
+	mu    sync.Mutex
+	local []*RevocationList
+	// Downloaded CRLs by URL
+	cache map[string]*RevocationList
+}
+
+// NewCRLChecker creates checker. Fetcher may be nil to use local CRLs only.
+// Zero value of CRLChecker is usable as well
+func NewCRLChecker(fetcher CRLFetcher) *CRLChecker {
+	return &CRLChecker{
+		Fetcher: fetcher,
+		Now:     time.Now,
+		cache:   make(map[string]*RevocationList),
+	}
+}
+
+// AddCRL adds locally available CRL. Its signature is checked against issuer when used
+func (c *CRLChecker) AddCRL(crl *RevocationList) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.local = append(c.local, crl)
+}
+
+// LoadDir adds every CRL file (DER or PEM) of dir. Subdirectories are ignored
+func (c *CRLChecker) LoadDir(dir string) error {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return errors.Wrap(err, "failed to read CRL directory")
+	}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, f.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", path)
+		}
+		crl, err := ParseRevocationListFile(data)
+		if err != nil {
+			return errors.Wrapf(err, "file %s", path)
+		}
+		c.AddCRL(crl)
+	}
+	return nil
+}
+
+// now returns time of checker clock
+func (c *CRLChecker) now() time.Time {
+	if c.Now == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
+
+// CheckRevocation looks up certificate serial number in current CRL of issuer
+func (c *CRLChecker) CheckRevocation(ctx context.Context, cert, issuer *Certificate) error {
+	now := c.now()
+
+	crl, err := c.findCRL(ctx, cert, issuer, now)
+	if err != nil {
+		return err
+	}
+
+	if entry, ok := crl.Find(cert.SerialNumber); ok && !entry.RevocationTime.After(now) {
+		return errors.Wrapf(ErrRevoked, "certificate %q (serial %s) revoked at %s, reason %d",
+			cert.Subject.CommonName, cert.SerialNumber.Text(16), entry.RevocationTime.Format(time.RFC3339), entry.ReasonCode)
+	}
+	return nil
+}
+
+// findCRL returns current CRL of issuer signed by it.
+// Lock is not held while downloading, so slow distribution points don't block other checks.
+func (c *CRLChecker) findCRL(ctx context.Context, cert, issuer *Certificate, now time.Time) (*RevocationList, error) {
+	c.mu.Lock()
+	local := append([]*RevocationList(nil), c.local...)
+	cached := make(map[string]*RevocationList, len(cert.CRLDistributionPoints))
+	for _, url := range cert.CRLDistributionPoints {
+		if crl, ok := c.cache[url]; ok {
+			cached[url] = crl
+		}
+	}
+	c.mu.Unlock()
+
+	// Several current CRLs of issuer may be added, the newest one lists most revocations
+	var lastErr error
+	var newest *RevocationList
+	for _, crl := range local {
+		if !crl.IsCurrent(now) {
+			continue
+		}
+		if err := crl.CheckSignatureFrom(issuer); err != nil {
+			lastErr = err
+			continue
+		}
+		if newest == nil || crl.newerThan(newest) {
+			newest = crl
+		}
+	}
+	if newest != nil {
+		return newest, nil
+	}
+
+	if c.Fetcher != nil {
+		for _, url := range cert.CRLDistributionPoints {
+			if crl, ok := cached[url]; ok && crl.IsCurrent(now) {
+				return crl, nil
+			}
+
+			data, err := c.Fetcher.FetchCRL(ctx, url)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			crl, err := ParseRevocationListFile(data)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			if err := crl.CheckSignatureFrom(issuer); err != nil {
+				lastErr = err
+				continue
+			}
+			if !crl.IsCurrent(now) {
+				lastErr = errors.Errorf("CRL from %s is outdated (next update %s)", url, crl.NextUpdate.Format(time.RFC3339))
+				continue
+			}
+			c.mu.Lock()
+			if c.cache == nil {
+				c.cache = make(map[string]*RevocationList)
+			}
+			c.cache[url] = crl
+			c.mu.Unlock()
+			return crl, nil
+		}
+	}
+
+	if lastErr != nil {
+		return nil, errors.Wrapf(ErrRevocationUnknown, "certificate %q: %s", cert.Subject.CommonName, lastErr.Error())
+	}
+	return nil, errors.Wrapf(ErrRevocationUnknown, "certificate %q: no CRL of %q", cert.Subject.CommonName, issuer.Subject.CommonName)
+}
@@ -0,0 +1,224 @@
+package gostx509
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"hash"
+	"time"
+
+	"github.com/LdDl/esia-potato/utils"
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/ddulesov/gogost/gost34112012512"
+	"github.com/pkg/errors"
+)
+
+// Sentinel errors of chain validation
+var (
+	ErrUnsupportedSignatureAlgorithm = fmt.Errorf("unsupported signature algorithm")
+	ErrSignatureInvalid              = fmt.Errorf("certificate signature is invalid")
+	ErrUnknownAuthority              = fmt.Errorf("certificate signed by unknown authority")
+	ErrNotCA                         = fmt.Errorf("issuer is not a CA")
+	ErrKeyUsage                      = fmt.Errorf("key usage does not permit operation")
+	ErrExtKeyUsage                   = fmt.Errorf("extended key usage does not permit operation")
+	ErrPathLength                    = fmt.Errorf("path length constraint violated")
+	ErrUnhandledCriticalExtension    = fmt.Errorf("unhandled critical extension")
+)
+
+// Longest chain (including leaf and root) that is built
+const maxChainLength = 10
+
+// VerifyOptions configures chain validation
+type VerifyOptions struct {
+	// Trusted root certificates
+	Roots *CertPool
+	// Untrusted certificates that may be used to build the chain
+	Intermediates *CertPool
+	// Time to check validity periods against. Zero means time.Now()
+	CurrentTime time.Time
+	// Key usage bits the leaf must have (if it has key usage extension). Zero means not checked
+	KeyUsage x509.KeyUsage
+	// Extended key usages the leaf must allow (any of them). Empty means not checked
+	ExtKeyUsages []asn1.ObjectIdentifier
+}
+
+// signatureHash returns hash function for certificate signature algorithm
+func signatureHash(oid asn1.ObjectIdentifier) (func() hash.Hash, error) {
+	switch {
+	case oid.Equal(OIDSignatureGostR341012256):
+		return func() hash.Hash { return gost34112012256.New() }, nil
+	case oid.Equal(OIDSignatureGostR341012512):
+		return func() hash.Hash { return gost34112012512.New() }, nil
+	default:
+		return nil, errors.Wrapf(ErrUnsupportedSignatureAlgorithm, "oid: %s", oid)
+	}
+}
+
+// CheckSignature verifies GOST signature of signed data with certificate public key.
+// Digest is reversed before verification like in cms package (GOST-engine compatibility).
+func (c *Certificate) CheckSignature(algorithm asn1.ObjectIdentifier, signed, signature []byte) error {
+	newHash, err := signatureHash(algorithm)
+	if err != nil {
+		return err
+	}
+	pub, err := c.PublicKey()
+	if err != nil {
+		return err
+	}
+
+	h := newHash()
+	h.Write(signed)
+	valid, err := pub.VerifyDigest(utils.ReverseBytes(h.Sum(nil)), signature)
+	if err != nil {
+		return errors.Wrap(ErrSignatureInvalid, err.Error())
+	}
+	if !valid {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
+
+// CheckSignatureFrom verifies that certificate is signed by parent's key
+func (c *Certificate) CheckSignatureFrom(parent *Certificate) error {
+	return parent.CheckSignature(c.SignatureAlgorithm, c.RawTBSCertificate, c.Signature)
+}
+
+// IsSelfSigned reports whether certificate is issued by itself (subject equals issuer and signature matches own key)
+func (c *Certificate) IsSelfSigned() bool {
+	return bytes.Equal(c.RawSubject, c.RawIssuer) && c.CheckSignatureFrom(c) == nil
+}
+
+// Verify builds chain from certificate to one of opts.Roots and validates every link:
+// signature, validity period, basic constraints, key usage and path length.
+// The chain is returned starting with the certificate itself and ending with the root.
+func (c *Certificate) Verify(opts VerifyOptions) ([]*Certificate, error) {
+	if opts.CurrentTime.IsZero() {
+		opts.CurrentTime = time.Now()
+	}
+	if opts.Roots == nil {
+		return nil, errors.Wrap(ErrUnknownAuthority, "no roots")
+	}
+
+	if err := c.checkLeaf(&opts); err != nil {
+		return nil, err
+	}
+
+	// Certificate itself is trusted
+	for _, root := range opts.Roots.bySubject[string(c.RawSubject)] {
+		if bytes.Equal(root.Raw, c.Raw) {
+			return []*Certificate{c}, nil
+		}
+	}
+
+	chain, err := buildChain([]*Certificate{c}, &opts)
+	if err != nil {
+		return nil, err
+	}
+	return chain, nil
+}
+
+// checkLeaf checks certificate being verified
+func (c *Certificate) checkLeaf(opts *VerifyOptions) error {
+	if err := c.checkCommon(opts.CurrentTime); err != nil {
+		return err
+	}
+	if opts.KeyUsage != 0 && c.KeyUsage != 0 && c.KeyUsage&opts.KeyUsage == 0 {
+		return errors.Wrapf(ErrKeyUsage, "certificate %q", c.Subject.CommonName)
+	}
+	if len(opts.ExtKeyUsages) > 0 {
+		allowed := false
+		for _, usage := range opts.ExtKeyUsages {
+			if c.HasExtKeyUsage(usage) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return errors.Wrapf(ErrExtKeyUsage, "certificate %q", c.Subject.CommonName)
+		}
+	}
+	return nil
+}
+
+// checkCommon checks properties required from every certificate of chain
+func (c *Certificate) checkCommon(now time.Time) error {
+	if err := c.CheckValidity(now); err != nil {
+		return errors.Wrapf(err, "certificate %q", c.Subject.CommonName)
+	}
+	if len(c.UnhandledCriticalExtensions) > 0 {
+		return errors.Wrapf(ErrUnhandledCriticalExtension, "certificate %q: %s", c.Subject.CommonName, c.UnhandledCriticalExtensions[0])
+	}
+	return nil
+}
+
+// checkIssuer checks that parent may issue child at given depth (number of intermediate CAs below parent)
+func checkIssuer(child, parent *Certificate, intermediates int, now time.Time) error {
+	if err := parent.checkCommon(now); err != nil {
+		return err
+	}
+	if !parent.BasicConstraintsValid || !parent.IsCA {
+		return errors.Wrapf(ErrNotCA, "certificate %q", parent.Subject.CommonName)
+	}
+	if parent.KeyUsage != 0 && parent.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return errors.Wrapf(ErrKeyUsage, "certificate %q can't sign certificates", parent.Subject.CommonName)
+	}
+	if parent.MaxPathLen >= 0 && intermediates > parent.MaxPathLen {
+		return errors.Wrapf(ErrPathLength, "certificate %q", parent.Subject.CommonName)
+	}
+	if err := child.CheckSignatureFrom(parent); err != nil {
+		return errors.Wrapf(err, "certificate %q issued by %q", child.Subject.CommonName, parent.Subject.CommonName)
+	}
+	return nil
+}
+
+// buildChain extends chain (ending with not yet trusted certificate) up to a root
+func buildChain(chain []*Certificate, opts *VerifyOptions) ([]*Certificate, error) {
+	current := chain[len(chain)-1]
+	// Number of CA certificates between leaf and candidate issuer
+	intermediates := len(chain) - 1
+
+	var lastErr error
+	for _, root := range opts.Roots.findIssuers(current) {
+		if inChain(chain, root) {
+			continue
+		}
+		if err := checkIssuer(current, root, intermediates, opts.CurrentTime); err != nil {
+			lastErr = err
+			continue
+		}
+		return append(chain, root), nil
+	}
+
+	if len(chain) < maxChainLength-1 && opts.Intermediates != nil {
+		for _, parent := range opts.Intermediates.findIssuers(current) {
+			if inChain(chain, parent) {
+				continue
+			}
+			if err := checkIssuer(current, parent, intermediates, opts.CurrentTime); err != nil {
+				lastErr = err
+				continue
+			}
+			next := append(append([]*Certificate{}, chain...), parent)
+			full, err := buildChain(next, opts)
+			if err == nil {
+				return full, nil
+			}
+			lastErr = err
+		}
+	}
+
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, errors.Wrapf(ErrUnknownAuthority, "issuer %q of %q not found", current.Issuer.CommonName, current.Subject.CommonName)
+}
+
+func inChain(chain []*Certificate, cert *Certificate) bool {
+	for _, c := range chain {
+		if bytes.Equal(c.Raw, cert.Raw) {
+			return true
+		}
+	}
+	return false
+}
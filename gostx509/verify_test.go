@@ -0,0 +1,220 @@
+package gostx509
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LdDl/esia-potato/internal/gosttest"
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPKI is root CA -> intermediate CA -> leaf
+type testPKI struct {
+	rootKey, intermediateKey, leafKey *gost3410.PrivateKey
+	root, intermediate, leaf          *Certificate
+}
+
+func keyUsageExtension(t *testing.T, usage x509.KeyUsage) pkix.Extension {
+	// Bits are numbered from the most significant bit of the first byte
+	var b [2]byte
+	for i := 0; i < 9; i++ {
+		if usage&(1<<uint(i)) != 0 {
+			b[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	return gosttest.Extension(t, OIDExtensionKeyUsage, true, asn1.BitString{Bytes: b[:], BitLength: 9})
+}
+
+func caExtensions(t *testing.T, maxPathLen int) []pkix.Extension {
+	return []pkix.Extension{
+		gosttest.Extension(t, OIDExtensionBasicConstraints, true, basicConstraints{IsCA: true, MaxPathLen: maxPathLen}),
+		keyUsageExtension(t, x509.KeyUsageCertSign|x509.KeyUsageCRLSign),
+	}
+}
+
+func mustParse(t *testing.T, der []byte) *Certificate {
+	cert, err := ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+func createTestPKI(t *testing.T) *testPKI {
+	pki := &testPKI{
+		rootKey:         gosttest.PrivateKey(t),
+		intermediateKey: gosttest.PrivateKey(t),
+		leafKey:         gosttest.PrivateKey(t),
+	}
+	pki.root = mustParse(t, gosttest.Certificate(t, gosttest.Template{
+		Serial:     1,
+		Subject:    gosttest.Name("Test Root CA"),
+		Extensions: caExtensions(t, -1),
+	}, pki.rootKey, pki.rootKey))
+	pki.intermediate = mustParse(t, gosttest.Certificate(t, gosttest.Template{
+		Serial:     2,
+		Subject:    gosttest.Name("Test Intermediate CA"),
+		Issuer:     gosttest.Name("Test Root CA"),
+		Extensions: caExtensions(t, 0),
+	}, pki.intermediateKey, pki.rootKey))
+	pki.leaf = mustParse(t, gosttest.Certificate(t, gosttest.Template{
+		Serial:  3,
+		Subject: gosttest.Name("Test Leaf"),
+		Issuer:  gosttest.Name("Test Intermediate CA"),
+		Extensions: []pkix.Extension{
+			keyUsageExtension(t, x509.KeyUsageDigitalSignature|x509.KeyUsageContentCommitment),
+		},
+	}, pki.leafKey, pki.intermediateKey))
+	return pki
+}
+
+func (pki *testPKI) options() VerifyOptions {
+	roots := NewCertPool()
+	roots.AddCert(pki.root)
+	intermediates := NewCertPool()
+	intermediates.AddCert(pki.intermediate)
+	return VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+// go test -timeout 30s -run ^TestVerifyChain$ github.com/LdDl/esia-potato/gostx509
+func TestVerifyChain(t *testing.T) {
+	pki := createTestPKI(t)
+
+	assert.True(t, pki.root.IsSelfSigned())
+	assert.False(t, pki.intermediate.IsSelfSigned())
+	assert.NoError(t, pki.leaf.CheckSignatureFrom(pki.intermediate))
+	assert.ErrorIs(t, pki.leaf.CheckSignatureFrom(pki.root), ErrSignatureInvalid)
+
+	opts := pki.options()
+	opts.KeyUsage = x509.KeyUsageDigitalSignature
+	opts.ExtKeyUsages = []asn1.ObjectIdentifier{OIDExtKeyUsageClientAuth}
+	chain, err := pki.leaf.Verify(opts)
+	require.NoError(t, err)
+	require.Len(t, chain, 3)
+	assert.Equal(t, pki.leaf, chain[0])
+	assert.Equal(t, pki.intermediate, chain[1])
+	assert.Equal(t, pki.root, chain[2])
+
+	// Root itself is trusted
+	chain, err = pki.root.Verify(pki.options())
+	require.NoError(t, err)
+	assert.Len(t, chain, 1)
+}
+
+// go test -timeout 30s -run ^TestVerifyChainFailures$ github.com/LdDl/esia-potato/gostx509
+func TestVerifyChainFailures(t *testing.T) {
+	pki := createTestPKI(t)
+
+	t.Run("missing intermediate", func(t *testing.T) {
+		opts := pki.options()
+		opts.Intermediates = nil
+		_, err := pki.leaf.Verify(opts)
+		assert.ErrorIs(t, err, ErrUnknownAuthority)
+	})
+
+	t.Run("no roots", func(t *testing.T) {
+		opts := pki.options()
+		opts.Roots = NewCertPool()
+		_, err := pki.leaf.Verify(opts)
+		assert.ErrorIs(t, err, ErrUnknownAuthority)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		opts := pki.options()
+		opts.CurrentTime = time.Date(2035, 1, 1, 0, 0, 0, 0, time.UTC)
+		_, err := pki.leaf.Verify(opts)
+		assert.ErrorIs(t, err, ErrCertificateExpired)
+	})
+
+	t.Run("key usage", func(t *testing.T) {
+		opts := pki.options()
+		opts.KeyUsage = x509.KeyUsageKeyAgreement
+		_, err := pki.leaf.Verify(opts)
+		assert.ErrorIs(t, err, ErrKeyUsage)
+	})
+
+	t.Run("not CA", func(t *testing.T) {
+		// Leaf certificate issued by another leaf
+		fake := mustParse(t, gosttest.Certificate(t, gosttest.Template{
+			Serial:  4,
+			Subject: gosttest.Name("Fake"),
+			Issuer:  gosttest.Name("Test Leaf"),
+		}, gosttest.PrivateKey(t), pki.leafKey))
+		opts := pki.options()
+		opts.Intermediates.AddCert(pki.leaf)
+		_, err := fake.Verify(opts)
+		assert.ErrorIs(t, err, ErrNotCA)
+	})
+
+	t.Run("path length", func(t *testing.T) {
+		// Intermediate allows no more CAs below it
+		subKey := gosttest.PrivateKey(t)
+		sub := mustParse(t, gosttest.Certificate(t, gosttest.Template{
+			Serial:     5,
+			Subject:    gosttest.Name("Test Sub CA"),
+			Issuer:     gosttest.Name("Test Intermediate CA"),
+			Extensions: caExtensions(t, -1),
+		}, subKey, pki.intermediateKey))
+		leaf := mustParse(t, gosttest.Certificate(t, gosttest.Template{
+			Serial:  6,
+			Subject: gosttest.Name("Deep Leaf"),
+			Issuer:  gosttest.Name("Test Sub CA"),
+		}, gosttest.PrivateKey(t), subKey))
+		opts := pki.options()
+		opts.Intermediates.AddCert(sub)
+		_, err := leaf.Verify(opts)
+		assert.ErrorIs(t, err, ErrPathLength)
+	})
+
+	t.Run("forged signature", func(t *testing.T) {
+		forged := mustParse(t, gosttest.Certificate(t, gosttest.Template{
+			Serial:  7,
+			Subject: gosttest.Name("Forged"),
+			Issuer:  gosttest.Name("Test Intermediate CA"),
+		}, gosttest.PrivateKey(t), gosttest.PrivateKey(t)))
+		_, err := forged.Verify(pki.options())
+		assert.ErrorIs(t, err, ErrSignatureInvalid)
+	})
+}
+
+// go test -timeout 30s -run ^TestLoadTrustStore$ github.com/LdDl/esia-potato/gostx509
+func TestLoadTrustStore(t *testing.T) {
+	pki := createTestPKI(t)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "root.cer"), pki.root.Raw, 0644))
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: pki.intermediate.Raw})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "intermediate.pem"), pemData, 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "subdir"), 0755))
+
+	ts, err := LoadTrustStore(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 1, ts.Roots.Len())
+	assert.Equal(t, 1, ts.Intermediates.Len())
+
+	chain, err := ts.Verify(pki.leaf, VerifyOptions{CurrentTime: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)})
+	require.NoError(t, err)
+	assert.Len(t, chain, 3)
+
+	// Intermediate may come from outside the trust store (e.g. from CMS)
+	onlyRoot := NewTrustStore()
+	onlyRoot.AddCert(pki.root)
+	_, err = onlyRoot.Verify(pki.leaf, VerifyOptions{CurrentTime: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)})
+	assert.ErrorIs(t, err, ErrUnknownAuthority)
+	_, err = onlyRoot.Verify(pki.leaf, VerifyOptions{CurrentTime: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}, pki.intermediate)
+	assert.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.cer"), []byte("not a certificate"), 0644))
+	_, err = LoadTrustStore(dir)
+	assert.ErrorIs(t, err, ErrParse)
+}
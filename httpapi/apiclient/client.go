@@ -0,0 +1,233 @@
+// Package apiclient is a Go client for the cryptopro_extract_service HTTP API (see package httpapi).
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/LdDl/esia-potato/httpapi"
+)
+
+// DefaultTimeout limits a whole request (including reading the response) when no HTTP client is given
+const DefaultTimeout = 30 * time.Second
+
+// Largest response body accepted from the service
+const maxResponseSize = 10 << 20
+
+// API endpoints
+const (
+	extractPath = "/api/v1/extract"
+	signPath    = "/api/v1/sign"
+	keysPath    = "/api/v1/keys"
+	healthPath  = "/health"
+)
+
+// Error is a non-2xx response of the service. Message is decoded from httpapi.ErrorResponse
+// or holds the raw body if it is not JSON.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("api error (status %d): %s", e.StatusCode, e.Message)
+}
+
+// Option configures Client
+type Option func(*Client)
+
+// WithHTTPClient sets HTTP client used for requests (its timeout replaces DefaultTimeout)
+func WithHTTPClient(c *http.Client) Option {
+	return func(cl *Client) {
+		cl.httpClient = c
+	}
+}
+
+// WithTimeout sets timeout of every request
+func WithTimeout(d time.Duration) Option {
+	return func(cl *Client) {
+		cl.timeout = d
+	}
+}
+
+// Client calls the extract, sign, keys and health endpoints of the service
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	timeout    time.Duration
+}
+
+// NewClient creates client of the service listening at baseURL, e.g. http://localhost:8080
+func NewClient(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: DefaultTimeout},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// ExtractRequest is the input of Client.Extract
+type ExtractRequest struct {
+	// Container archive (.zip or .tar.gz)
+	Archive io.Reader
+	// Archive file name, its extension selects archive format. container.tar.gz by default
+	Filename string
+	// Container PIN code
+	PIN string
+	// Save extracted key in the service key store under this id; private keys are not returned then
+	KeyID string
+	// Replace key already stored under KeyID. Otherwise the service responds 409
+	Overwrite bool
+}
+
+// Extract uploads container archive to /api/v1/extract
+func (c *Client) Extract(ctx context.Context, req *ExtractRequest) (*httpapi.ExtractResponse, error) {
+	filename := req.Filename
+	if filename == "" {
+		filename = "container.tar.gz"
+	}
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	if err := writer.WriteField("pin", req.PIN); err != nil {
+		return nil, fmt.Errorf("failed to write pin field: %w", err)
+	}
+	if req.KeyID != "" {
+		if err := writer.WriteField("key_id", req.KeyID); err != nil {
+			return nil, fmt.Errorf("failed to write key_id field: %w", err)
+		}
+	}
+	if req.Overwrite {
+		if err := writer.WriteField("overwrite", "true"); err != nil {
+			return nil, fmt.Errorf("failed to write overwrite field: %w", err)
+		}
+	}
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := io.Copy(part, req.Archive); err != nil {
+		return nil, fmt.Errorf("failed to write file data: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close writer: %w", err)
+	}
+
+	var resp httpapi.ExtractResponse
+	if err := c.do(ctx, http.MethodPost, extractPath, writer.FormDataContentType(), &buf, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Sign calls /api/v1/sign and returns DER encoded CMS SignedData
+func (c *Client) Sign(ctx context.Context, req *httpapi.SignRequest) ([]byte, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	var resp httpapi.SignResponse
+	if err := c.do(ctx, http.MethodPost, signPath, "application/json", bytes.NewReader(body), &resp); err != nil {
+		return nil, err
+	}
+	signature, err := base64.StdEncoding.DecodeString(resp.SignatureB64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode signature: %w", err)
+	}
+	return signature, nil
+}
+
+// Keys lists keys saved in the service key store
+func (c *Client) Keys(ctx context.Context) ([]httpapi.KeyInfo, error) {
+	var resp httpapi.KeyListResponse
+	if err := c.do(ctx, http.MethodGet, keysPath, "", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Keys, nil
+}
+
+// Key returns info of single stored key
+func (c *Client) Key(ctx context.Context, keyID string) (*httpapi.KeyInfo, error) {
+	var resp httpapi.KeyInfo
+	if err := c.do(ctx, http.MethodGet, keysPath+"?key_id="+url.QueryEscape(keyID), "", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// DeleteKey removes key from the service key store
+func (c *Client) DeleteKey(ctx context.Context, keyID string) error {
+	return c.do(ctx, http.MethodDelete, keysPath+"?key_id="+url.QueryEscape(keyID), "", nil, nil)
+}
+
+// Health calls /health
+func (c *Client) Health(ctx context.Context) (*httpapi.HealthResponse, error) {
+	var resp httpapi.HealthResponse
+	if err := c.do(ctx, http.MethodGet, healthPath, "", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// do sends request and decodes JSON response into out (if not nil)
+func (c *Client) do(ctx context.Context, method, path, contentType string, body io.Reader, out interface{}) error {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &Error{StatusCode: resp.StatusCode}
+		var errResp httpapi.ErrorResponse
+		if err := json.Unmarshal(data, &errResp); err == nil && errResp.Error != "" {
+			apiErr.Message = errResp.Error
+		} else {
+			apiErr.Message = strings.TrimSpace(string(data))
+		}
+		if apiErr.Message == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return apiErr
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
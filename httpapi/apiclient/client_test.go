@@ -0,0 +1,209 @@
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LdDl/esia-potato/cms"
+	"github.com/LdDl/esia-potato/esia"
+	"github.com/LdDl/esia-potato/httpapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeService records sign requests and answers them with fixed signature
+type fakeService struct {
+	t         *testing.T
+	signature []byte
+	signed    []httpapi.SignRequest
+}
+
+func (f *fakeService) server() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/sign", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(f.t, http.MethodPost, r.Method)
+		assert.Equal(f.t, "application/json", r.Header.Get("Content-Type"))
+		var req httpapi.SignRequest
+		require.NoError(f.t, json.NewDecoder(r.Body).Decode(&req))
+		f.signed = append(f.signed, req)
+		if req.KeyID == "missing" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(httpapi.ErrorResponse{Error: "failed to load key: key not found"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(httpapi.SignResponse{
+			SignatureB64: base64.StdEncoding.EncodeToString(f.signature),
+		})
+	})
+	mux.HandleFunc("/api/v1/extract", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(f.t, r.ParseMultipartForm(1<<20))
+		assert.Equal(f.t, "1234", r.FormValue("pin"))
+		assert.Equal(f.t, "esia-prod", r.FormValue("key_id"))
+		file, header, err := r.FormFile("file")
+		require.NoError(f.t, err)
+		defer file.Close()
+		assert.Equal(f.t, "container.zip", header.Filename)
+		data, err := io.ReadAll(file)
+		require.NoError(f.t, err)
+		assert.Equal(f.t, "archive", string(data))
+		_ = json.NewEncoder(w).Encode(httpapi.ExtractResponse{
+			KeyID:        "esia-prod",
+			PublicKeyHex: "abcd",
+			Fingerprint:  "0123456789abcdef",
+			CurveOID:     "1.2.643.2.2.36.0",
+		})
+	})
+	mux.HandleFunc("/api/v1/keys", func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodDelete:
+			assert.Equal(f.t, "esia-prod", r.URL.Query().Get("key_id"))
+			_ = json.NewEncoder(w).Encode(map[string]string{"status": "deleted"})
+		case r.URL.Query().Get("key_id") != "":
+			_ = json.NewEncoder(w).Encode(httpapi.KeyInfo{KeyID: r.URL.Query().Get("key_id"), HasCertificate: true})
+		default:
+			_ = json.NewEncoder(w).Encode(httpapi.KeyListResponse{Keys: []httpapi.KeyInfo{{KeyID: "esia-prod"}}})
+		}
+	})
+	mux.HandleFunc("/health", httpapi.HandleHealth)
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	})
+	mux.HandleFunc("/plain", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	})
+	server := httptest.NewServer(mux)
+	f.t.Cleanup(server.Close)
+	return server
+}
+
+// go test -timeout 30s -run ^TestClient$ github.com/LdDl/esia-potato/httpapi/apiclient
+func TestClient(t *testing.T) {
+	fake := &fakeService{t: t, signature: []byte{0x30, 0x03, 0x02, 0x01, 0x01}}
+	server := fake.server()
+	client := NewClient(server.URL + "/")
+	ctx := context.Background()
+
+	health, err := client.Health(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", health.Status)
+
+	extracted, err := client.Extract(ctx, &ExtractRequest{
+		Archive:  strings.NewReader("archive"),
+		Filename: "container.zip",
+		PIN:      "1234",
+		KeyID:    "esia-prod",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "esia-prod", extracted.KeyID)
+	assert.Equal(t, "1.2.643.2.2.36.0", extracted.CurveOID)
+
+	signature, err := client.Sign(ctx, &httpapi.SignRequest{KeyID: "esia-prod", Message: "hello"})
+	require.NoError(t, err)
+	assert.Equal(t, fake.signature, signature)
+	require.Len(t, fake.signed, 1)
+	assert.Equal(t, "hello", fake.signed[0].Message)
+
+	keys, err := client.Keys(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, "esia-prod", keys[0].KeyID)
+
+	key, err := client.Key(ctx, "esia-prod")
+	require.NoError(t, err)
+	assert.True(t, key.HasCertificate)
+
+	require.NoError(t, client.DeleteKey(ctx, "esia-prod"))
+}
+
+// go test -timeout 30s -run ^TestClientErrors$ github.com/LdDl/esia-potato/httpapi/apiclient
+func TestClientErrors(t *testing.T) {
+	fake := &fakeService{t: t}
+	server := fake.server()
+
+	client := NewClient(server.URL)
+	_, err := client.Sign(context.Background(), &httpapi.SignRequest{KeyID: "missing", Message: "hello"})
+	var apiErr *Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, "failed to load key: key not found", apiErr.Message)
+
+	err = client.do(context.Background(), http.MethodGet, "/plain", "", nil, nil)
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, http.StatusBadGateway, apiErr.StatusCode)
+	assert.Equal(t, "bad gateway", apiErr.Message)
+
+	client = NewClient(server.URL, WithTimeout(50*time.Millisecond))
+	err = client.do(context.Background(), http.MethodGet, "/slow", "", nil, nil)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	client = NewClient(server.URL)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = client.Health(ctx)
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+// go test -timeout 30s -run ^TestRemoteSigner$ github.com/LdDl/esia-potato/httpapi/apiclient
+func TestRemoteSigner(t *testing.T) {
+	fake := &fakeService{t: t, signature: []byte{0x30, 0x03, 0x02, 0x01, 0x01}}
+	server := fake.server()
+	certDER := []byte{0x30, 0x00}
+
+	signer := NewRemoteSigner(NewClient(server.URL), "esia-prod", certDER)
+	assert.Equal(t, cms.CertificateHash(certDER), signer.CertificateHash())
+	assert.Empty(t, NewRemoteSigner(NewClient(server.URL), "esia-prod", nil).CertificateHash())
+
+	client, err := esia.NewClient(esia.Config{
+		ClientID:    "CLIENT_ID",
+		RedirectURI: "https://example.com/callback",
+		Scopes:      []string{"openid"},
+		Signer:      signer,
+		Version:     esia.V2,
+	})
+	require.NoError(t, err)
+
+	authURL, err := client.AuthCodeURL("state")
+	require.NoError(t, err)
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, cms.CertificateHash(certDER), u.Query().Get("client_certificate_hash"))
+
+	secret, err := base64.URLEncoding.DecodeString(u.Query().Get("client_secret"))
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(fake.signature, secret))
+
+	require.Len(t, fake.signed, 1)
+	assert.Equal(t, "esia-prod", fake.signed[0].KeyID)
+	assert.Empty(t, fake.signed[0].PrivateKeyHex)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(certDER), fake.signed[0].CertificateB64)
+	assert.Empty(t, fake.signed[0].Message)
+	message, err := base64.StdEncoding.DecodeString(fake.signed[0].MessageB64)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(message), "CLIENT_IDopenid"))
+	// esia.Client puts timestamp of request into signingTime
+	require.NotNil(t, fake.signed[0].SigningTime)
+	assert.Contains(t, string(message), fake.signed[0].SigningTime.UTC().Format(esia.TimestampLayout))
+
+	// Binary content is not mangled on the way
+	binary := []byte{0x00, 0xff, 0xfe, 0x80}
+	_, err = signer.Sign(binary)
+	require.NoError(t, err)
+	require.Len(t, fake.signed, 2)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(binary), fake.signed[1].MessageB64)
+	assert.Nil(t, fake.signed[1].SigningTime)
+}
@@ -0,0 +1,77 @@
+package apiclient
+
+import (
+	"context"
+	"encoding/base64"
+	"time"
+
+	"github.com/LdDl/esia-potato/cms"
+	"github.com/LdDl/esia-potato/esia"
+	"github.com/LdDl/esia-potato/httpapi"
+)
+
+var (
+	_ esia.Signer            = (*RemoteSigner)(nil)
+	_ esia.TimeSigner        = (*RemoteSigner)(nil)
+	_ esia.CertificateHasher = (*RemoteSigner)(nil)
+)
+
+// RemoteSigner signs with a key kept in the service key store, so the caller never holds the private key.
+// It implements esia.Signer and esia.TimeSigner and can be used as esia.Config.Signer.
+type RemoteSigner struct {
+	client *Client
+	keyID  string
+	// DER certificate, optional if it is stored with the key.
+	// Needed for client_certificate_hash of ESIA v2 flow.
+	certDER []byte
+}
+
+// NewRemoteSigner creates signer using stored key keyID. certDER may be nil
+func NewRemoteSigner(client *Client, keyID string, certDER []byte) *RemoteSigner {
+	return &RemoteSigner{
+		client:  client,
+		keyID:   keyID,
+		certDER: certDER,
+	}
+}
+
+// Sign returns detached CMS signature of content
+func (s *RemoteSigner) Sign(content []byte) ([]byte, error) {
+	return s.SignContext(context.Background(), content)
+}
+
+// SignContext is Sign with context
+func (s *RemoteSigner) SignContext(ctx context.Context, content []byte) ([]byte, error) {
+	return s.sign(ctx, content, nil)
+}
+
+// SignAt is like Sign but the service puts signingTime t instead of its current time
+func (s *RemoteSigner) SignAt(content []byte, t time.Time) ([]byte, error) {
+	return s.SignAtContext(context.Background(), content, t)
+}
+
+// SignAtContext is SignAt with context
+func (s *RemoteSigner) SignAtContext(ctx context.Context, content []byte, t time.Time) ([]byte, error) {
+	return s.sign(ctx, content, &t)
+}
+
+// sign sends content base64-encoded, so binary content reaches the service unchanged
+func (s *RemoteSigner) sign(ctx context.Context, content []byte, signingTime *time.Time) ([]byte, error) {
+	req := &httpapi.SignRequest{
+		KeyID:       s.keyID,
+		MessageB64:  base64.StdEncoding.EncodeToString(content),
+		SigningTime: signingTime,
+	}
+	if s.certDER != nil {
+		req.CertificateB64 = base64.StdEncoding.EncodeToString(s.certDER)
+	}
+	return s.client.Sign(ctx, req)
+}
+
+// CertificateHash returns client_certificate_hash of certificate or empty string if certificate is not set
+func (s *RemoteSigner) CertificateHash() string {
+	if s.certDER == nil {
+		return ""
+	}
+	return cms.CertificateHash(s.certDER)
+}
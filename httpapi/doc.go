@@ -31,4 +31,7 @@
 //
 // @tag.name Signing
 // @tag.description Sign messages with GOST cryptography
+//
+// @tag.name Key Store
+// @tag.description Manage keys stored on the server
 package httpapi
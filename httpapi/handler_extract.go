@@ -4,30 +4,41 @@ package httpapi
 import (
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 
 	"github.com/LdDl/esia-potato/cryptopro"
+	"github.com/LdDl/esia-potato/keystore"
 )
 
 const maxUploadSize = 10 << 20 // 10 MB
 
 // HandleExtract Extract key from CryptoPro container
 // @Summary Extract key from CryptoPro container
-// @Description Extracts private key, public key and certificate from uploaded CryptoPro container archive
+// @Description Extracts private key, public key and certificate from uploaded CryptoPro container archive.
+// @Description If the container holds a secondary key pair (masks2.key / primary2.key) it is returned as secondary_key.
+// @Description If key_id is set, keys are saved in the server key store (secondary key as <key_id>-secondary)
+// @Description and private keys are omitted from the response; use key_id in /api/v1/sign afterwards.
+// @Description Existing key with the same key_id is not replaced (409) unless overwrite=true.
+// @Description Container certificate is stored only with the key whose public key it carries.
 // @Tags Key Extraction
 // @Accept multipart/form-data
 // @Produce json
 // @Param file formData file true "Container archive (.zip or .tar.gz)"
 // @Param pin formData string false "Container PIN code"
+// @Param key_id formData string false "Save extracted key in key store under this id"
+// @Param overwrite formData bool false "Replace key already stored under key_id"
 // @Success 200 {object} httpapi.ExtractResponse
 // @Failure 400 {object} httpapi.ErrorResponse
 // @Failure 405 {object} httpapi.ErrorResponse
+// @Failure 409 {object} httpapi.ErrorResponse
 // @Failure 500 {object} httpapi.ErrorResponse
 // @Router /api/v1/extract [POST]
-func HandleExtract(w http.ResponseWriter, r *http.Request) {
+func (s *Server) HandleExtract(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
@@ -44,6 +55,30 @@ func HandleExtract(w http.ResponseWriter, r *http.Request) {
 	// Get PIN
 	pin := r.FormValue("pin")
 
+	// Get key id (optional)
+	keyID := r.FormValue("key_id")
+	secondaryKeyID := keyID + secondaryKeySuffix
+	if keyID != "" {
+		if s.Store == nil {
+			writeError(w, http.StatusBadRequest, "key store is not configured")
+			return
+		}
+		if err := keystore.ValidateID(secondaryKeyID); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid key_id: "+err.Error())
+			return
+		}
+	}
+
+	// Stored keys are replaced only on explicit request
+	overwrite := false
+	if v := r.FormValue("overwrite"); v != "" {
+		var err error
+		if overwrite, err = strconv.ParseBool(v); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid overwrite: "+err.Error())
+			return
+		}
+	}
+
 	// Get file
 	file, header, err := r.FormFile("file")
 	if err != nil {
@@ -98,14 +133,79 @@ func HandleExtract(w http.ResponseWriter, r *http.Request) {
 		CurveOID:      keyData.CurveOID,
 	}
 
+	// Extract secondary key if container has one
+	var secondaryData *cryptopro.KeyData
+	if container.HasSecondaryKey() {
+		secondaryData, err = container.ExtractSecondaryKey(pin)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "failed to extract secondary key: "+err.Error())
+			return
+		}
+
+		slog.Info("secondary key extracted successfully",
+			"curve_oid", secondaryData.CurveOID,
+			"fingerprint", hex.EncodeToString(secondaryData.Fingerprint),
+		)
+
+		resp.SecondaryKey = &KeyResponse{
+			PrivateKeyHex: hex.EncodeToString(secondaryData.PrivateKey),
+			PublicKeyHex:  hex.EncodeToString(secondaryData.PublicKey),
+			Fingerprint:   hex.EncodeToString(secondaryData.Fingerprint),
+			CurveOID:      secondaryData.CurveOID,
+		}
+	}
+
 	// Try to find and read certificate
 	certPath := filepath.Join(containerPath, "certificate.cer")
-	if certData, err := os.ReadFile(certPath); err == nil {
+	certData, err := os.ReadFile(certPath)
+	if err == nil {
 		resp.CertificateBase64 = base64.StdEncoding.EncodeToString(certData)
 		slog.Info("certificate found", "path", "certificate.cer")
 	} else {
 		slog.Warn("certificate not found", "path", certPath)
 	}
 
+	// Save keys in key store instead of returning private keys
+	if keyID != "" {
+		// Check secondary id first, so primary key is not stored alone
+		if secondaryData != nil && !overwrite {
+			if _, err := s.Store.Get(secondaryKeyID); err == nil {
+				writeError(w, http.StatusConflict, "failed to store secondary key: "+keystore.ErrExists.Error())
+				return
+			}
+		}
+		// Remember replaced primary key to restore it if secondary key can't be stored
+		var previous *keystore.Entry
+		if secondaryData != nil && overwrite {
+			previous, err = s.Store.Get(keyID)
+			if err != nil && !errors.Is(err, keystore.ErrNotFound) {
+				writeError(w, statusForStoreError(err), "failed to load stored key: "+err.Error())
+				return
+			}
+		}
+		if err := s.storeKey(keyID, keyData, certData, overwrite); err != nil {
+			writeError(w, statusForStoreError(err), "failed to store key: "+err.Error())
+			return
+		}
+		resp.KeyID = keyID
+		resp.PrivateKeyHex = ""
+
+		if secondaryData != nil {
+			if err := s.storeKey(secondaryKeyID, secondaryData, certData, overwrite); err != nil {
+				if previous != nil {
+					_ = s.Store.Put(keyID, previous)
+				} else {
+					_ = s.Store.Delete(keyID)
+				}
+				writeError(w, statusForStoreError(err), "failed to store secondary key: "+err.Error())
+				return
+			}
+			resp.SecondaryKey.KeyID = secondaryKeyID
+			resp.SecondaryKey.PrivateKeyHex = ""
+		}
+
+		slog.Info("key stored", "key_id", keyID, "secondary", secondaryData != nil)
+	}
+
 	writeJSON(w, http.StatusOK, resp)
 }
@@ -0,0 +1,131 @@
+package httpapi
+
+import (
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/LdDl/esia-potato/cryptopro"
+	"github.com/LdDl/esia-potato/keystore"
+)
+
+// Suffix of key id under which secondary key is stored
+const secondaryKeySuffix = "-secondary"
+
+// HandleKeys List, inspect or delete stored keys
+// @Summary Manage stored keys
+// @Description GET without key_id lists stored keys, GET with key_id returns single key info.
+// @Description DELETE removes key with given key_id. Private keys are never returned.
+// @Tags Key Store
+// @Produce json
+// @Param key_id query string false "Key id"
+// @Success 200 {object} httpapi.KeyListResponse
+// @Failure 400 {object} httpapi.ErrorResponse
+// @Failure 404 {object} httpapi.ErrorResponse
+// @Failure 405 {object} httpapi.ErrorResponse
+// @Failure 500 {object} httpapi.ErrorResponse
+// @Router /api/v1/keys [GET]
+// @Router /api/v1/keys [DELETE]
+func (s *Server) HandleKeys(w http.ResponseWriter, r *http.Request) {
+	if s.Store == nil {
+		writeError(w, http.StatusBadRequest, "key store is not configured")
+		return
+	}
+
+	keyID := r.URL.Query().Get("key_id")
+
+	switch r.Method {
+	case http.MethodGet:
+		if keyID == "" {
+			s.listKeys(w)
+			return
+		}
+		entry, status, err := s.loadKey(keyID)
+		if err != nil {
+			writeError(w, status, "failed to get key: "+err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, newKeyInfo(keyID, entry))
+	case http.MethodDelete:
+		if keyID == "" {
+			writeError(w, http.StatusBadRequest, "key_id is required")
+			return
+		}
+		if err := s.Store.Delete(keyID); err != nil {
+			writeError(w, statusForStoreError(err), "failed to delete key: "+err.Error())
+			return
+		}
+		slog.Info("key deleted", "key_id", keyID)
+		writeJSON(w, http.StatusOK, HealthResponse{Status: "ok"})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) listKeys(w http.ResponseWriter) {
+	ids, err := s.Store.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list keys: "+err.Error())
+		return
+	}
+
+	resp := KeyListResponse{
+		Keys: make([]KeyInfo, 0, len(ids)),
+	}
+	for _, id := range ids {
+		entry, err := s.Store.Get(id)
+		if err != nil {
+			writeError(w, statusForStoreError(err), "failed to get key "+id+": "+err.Error())
+			return
+		}
+		resp.Keys = append(resp.Keys, newKeyInfo(id, entry))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// storeKey saves extracted key with certificate, if certificate public key matches the key
+func (s *Server) storeKey(keyID string, keyData *cryptopro.KeyData, certDER []byte, overwrite bool) error {
+	// Container certificate belongs to one key only (usually primary one)
+	if certDER != nil {
+		if _, err := certificatePrivateKey(keyData.PrivateKey, keyData.CurveOID, certDER); err != nil {
+			slog.Info("certificate is not stored with key", "key_id", keyID, "reason", err.Error())
+			certDER = nil
+		}
+	}
+	entry := &keystore.Entry{
+		KeyData:     *keyData,
+		Certificate: certDER,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if overwrite {
+		return s.Store.Put(keyID, entry)
+	}
+	return s.Store.Create(keyID, entry)
+}
+
+func statusForStoreError(err error) int {
+	switch {
+	case errors.Is(err, keystore.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, keystore.ErrExists):
+		return http.StatusConflict
+	case errors.Is(err, keystore.ErrInvalidID):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func newKeyInfo(keyID string, entry *keystore.Entry) KeyInfo {
+	return KeyInfo{
+		KeyID:          keyID,
+		PublicKeyHex:   hex.EncodeToString(entry.PublicKey),
+		Fingerprint:    hex.EncodeToString(entry.Fingerprint),
+		CurveOID:       entry.CurveOID,
+		HasCertificate: len(entry.Certificate) > 0,
+		CreatedAt:      entry.CreatedAt,
+	}
+}
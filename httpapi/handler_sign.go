@@ -2,29 +2,40 @@
 package httpapi
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
 
 	"github.com/LdDl/esia-potato/cms"
+	"github.com/LdDl/esia-potato/cryptopro"
 	"github.com/ddulesov/gogost/gost3410"
 )
 
 // HandleSign Sign message with GOST signature
 // @Summary Sign message
-// @Description Signs a message using GOST R 34.10-2012 and returns CMS/PKCS#7 SignedData
+// @Description Signs a message using GOST R 34.10-2012 and returns CMS/PKCS#7 SignedData.
+// @Description Signature is detached by default; set attached=true to embed the message as eContent.
+// @Description Message is given either as UTF-8 text in message or as base64 in message_base64 (for binary data).
+// @Description signing_time sets signingTime attribute (e.g. to match the timestamp of ESIA request); server clock is used if empty.
+// @Description Key is taken either from private_key_hex or from key store by key_id (see /api/v1/keys).
+// @Description Curve is taken from curve_oid, from the stored key or from the certificate public key parameters;
+// @Description private key must match the certificate public key.
+// @Description If the server has a trust store, certificate chain is validated (and checked against CRLs if configured) before signing.
 // @Tags Signing
 // @Accept json
 // @Produce json
 // @Param request body httpapi.SignRequest true "Sign request"
 // @Success 200 {object} httpapi.SignResponse
 // @Failure 400 {object} httpapi.ErrorResponse
+// @Failure 404 {object} httpapi.ErrorResponse
 // @Failure 405 {object} httpapi.ErrorResponse
 // @Failure 500 {object} httpapi.ErrorResponse
 // @Router /api/v1/sign [POST]
-func HandleSign(w http.ResponseWriter, r *http.Request) {
+func (s *Server) HandleSign(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 		return
@@ -36,44 +47,104 @@ func HandleSign(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Decode private key
-	keyBytes, err := hex.DecodeString(req.PrivateKeyHex)
-	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid private key hex: "+err.Error())
-		return
+	message := []byte(req.Message)
+	if req.MessageB64 != "" {
+		if req.Message != "" {
+			writeError(w, http.StatusBadRequest, "message and message_base64 are mutually exclusive")
+			return
+		}
+		var err error
+		message, err = base64.StdEncoding.DecodeString(req.MessageB64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid message base64: "+err.Error())
+			return
+		}
 	}
 
-	// Decode certificate
-	certDER, err := base64.StdEncoding.DecodeString(req.CertificateB64)
-	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid certificate base64: "+err.Error())
+	var keyBytes, certDER []byte
+	curveOID := req.CurveOID
+	if req.KeyID != "" {
+		if req.PrivateKeyHex != "" {
+			writeError(w, http.StatusBadRequest, "key_id and private_key_hex are mutually exclusive")
+			return
+		}
+		// Load key from key store
+		entry, status, err := s.loadKey(req.KeyID)
+		if err != nil {
+			writeError(w, status, "failed to load key: "+err.Error())
+			return
+		}
+		keyBytes = entry.PrivateKey
+		certDER = entry.Certificate
+		if curveOID == "" {
+			curveOID = entry.CurveOID
+		}
+	} else {
+		// Decode private key
+		var err error
+		keyBytes, err = hex.DecodeString(req.PrivateKeyHex)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid private key hex: "+err.Error())
+			return
+		}
+	}
+
+	// Decode certificate (overrides stored one)
+	if req.CertificateB64 != "" {
+		var err error
+		certDER, err = base64.StdEncoding.DecodeString(req.CertificateB64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid certificate base64: "+err.Error())
+			return
+		}
+	}
+	if len(certDER) == 0 {
+		writeError(w, http.StatusBadRequest, "certificate is required")
 		return
 	}
 
-	// Create private key (using default curve - CryptoPro A)
-	curve := gost3410.CurveIdGostR34102001CryptoProAParamSet()
-	prv, err := gost3410.NewPrivateKey(curve, gost3410.Mode2001, keyBytes)
+	// Create private key on the key curve and check it against certificate
+	prv, err := certificatePrivateKey(keyBytes, curveOID, certDER)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "failed to create private key: "+err.Error())
+		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// Create signer
-	signer, err := cms.NewSigner(prv, certDER)
+	var opts []cms.Option
+	if s.TrustStore != nil {
+		opts = append(opts, cms.WithTrustStore(s.TrustStore))
+	}
+	if s.RevocationChecker != nil {
+		opts = append(opts, cms.WithRevocationChecker(s.RevocationChecker))
+	}
+	signer, err := cms.NewSigner(prv, certDER, opts...)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, "failed to create signer: "+err.Error())
 		return
 	}
 
 	// Sign message
-	cmsDER, err := signer.Sign([]byte(req.Message))
+	var cmsDER []byte
+	switch {
+	case req.Attached && req.SigningTime != nil:
+		cmsDER, err = signer.SignAttachedAt(message, *req.SigningTime)
+	case req.Attached:
+		cmsDER, err = signer.SignAttached(message)
+	case req.SigningTime != nil:
+		cmsDER, err = signer.SignAt(message, *req.SigningTime)
+	default:
+		cmsDER, err = signer.Sign(message)
+	}
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to sign: "+err.Error())
 		return
 	}
 
 	slog.Info("message signed",
-		"message_len", len(req.Message),
+		"key_id", req.KeyID,
+		"message_len", len(message),
+		"attached", req.Attached,
 		"signature_len", len(cmsDER),
 	)
 
@@ -83,3 +154,38 @@ func HandleSign(w http.ResponseWriter, r *http.Request) {
 
 	writeJSON(w, http.StatusOK, resp)
 }
+
+// certificatePrivateKey creates private key for signing with given certificate.
+// If curveOID is empty the curve is taken from certificate public key parameters.
+func certificatePrivateKey(keyBytes []byte, curveOID string, certDER []byte) (*gost3410.PrivateKey, error) {
+	certPub, certCurveOID, err := cms.CertificatePublicKey(certDER)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate public key: %w", err)
+	}
+	if curveOID == "" {
+		curveOID = certCurveOID
+	}
+
+	curve, ok := cryptopro.CurveOID[curveOID]
+	if !ok {
+		return nil, fmt.Errorf("unknown curve_oid: %s", curveOID)
+	}
+	if len(keyBytes) != cryptopro.KeySize(curveOID) {
+		return nil, fmt.Errorf("private key is %d bytes, curve %s needs %d", len(keyBytes), curveOID, cryptopro.KeySize(curveOID))
+	}
+
+	prv, err := gost3410.NewPrivateKey(curve, cryptopro.KeyMode(curveOID), keyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create private key: %w", err)
+	}
+	pub, err := prv.PublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive public key: %w", err)
+	}
+
+	// Catches both wrong curve and key from another container
+	if !bytes.Equal(pub.Raw(), certPub.Raw()) {
+		return nil, fmt.Errorf("private key does not match certificate (key curve %s, certificate curve %s)", curveOID, certCurveOID)
+	}
+	return prv, nil
+}
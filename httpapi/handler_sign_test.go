@@ -0,0 +1,177 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LdDl/esia-potato/cms"
+	"github.com/LdDl/esia-potato/cryptopro"
+	"github.com/LdDl/esia-potato/internal/gosttest"
+	"github.com/LdDl/esia-potato/keystore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testCurveA      = "1.2.643.2.2.35.1"
+	testCurveB      = "1.2.643.2.2.35.2"
+	testCurve512A   = "1.2.643.7.1.2.1.2.1"
+	testSignMessage = "openid2025.01.01 12:00:00 +0000CLIENT_ID12345"
+)
+
+func sign(t *testing.T, server *Server, req SignRequest) *httptest.ResponseRecorder {
+	body, err := json.Marshal(req)
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	server.HandleSign(rec, httptest.NewRequest(http.MethodPost, "/api/v1/sign", bytes.NewReader(body)))
+	return rec
+}
+
+// go test -timeout 30s -run ^TestHandleSignKeyCertificate$ github.com/LdDl/esia-potato/httpapi
+func TestHandleSignKeyCertificate(t *testing.T) {
+	key := gosttest.PrivateKey(t)
+	cert := gosttest.SelfSigned(t, key, "Test Signer", 1)
+	key512 := gosttest.PrivateKey512(t)
+	cert512 := gosttest.SelfSigned(t, key512, "Test Signer 512", 2)
+	otherCert := gosttest.SelfSigned(t, gosttest.PrivateKey(t), "Other Signer", 3)
+
+	keyHex := hex.EncodeToString(key.Raw())
+	certB64 := base64.StdEncoding.EncodeToString(cert)
+
+	store := keystore.NewMemoryStore()
+	stored := func(id, curveOID string, prv []byte, certDER []byte) {
+		require.NoError(t, store.Put(id, &keystore.Entry{
+			KeyData:     cryptopro.KeyData{PrivateKey: prv, CurveOID: curveOID},
+			Certificate: certDER,
+		}))
+	}
+	stored("esia", testCurveA, key.Raw(), cert)
+	stored("esia-no-curve", "", key.Raw(), cert)
+	stored("esia-wrong-curve", testCurveB, key.Raw(), cert)
+	stored("esia-no-cert", testCurveA, key.Raw(), nil)
+	server := NewServer(store)
+
+	tests := []struct {
+		name   string
+		req    SignRequest
+		status int
+		// Expected error message (exact) for failures
+		err string
+	}{
+		{
+			name:   "curve from certificate",
+			req:    SignRequest{PrivateKeyHex: keyHex, CertificateB64: certB64},
+			status: http.StatusOK,
+		},
+		{
+			name:   "curve_oid",
+			req:    SignRequest{PrivateKeyHex: keyHex, CertificateB64: certB64, CurveOID: testCurveA},
+			status: http.StatusOK,
+		},
+		{
+			name:   "512-bit curve from certificate",
+			req:    SignRequest{PrivateKeyHex: hex.EncodeToString(key512.Raw()), CertificateB64: base64.StdEncoding.EncodeToString(cert512)},
+			status: http.StatusOK,
+		},
+		{
+			name:   "stored key",
+			req:    SignRequest{KeyID: "esia"},
+			status: http.StatusOK,
+		},
+		{
+			name:   "stored key without curve",
+			req:    SignRequest{KeyID: "esia-no-curve"},
+			status: http.StatusOK,
+		},
+		{
+			name:   "curve_oid overrides stored curve",
+			req:    SignRequest{KeyID: "esia-wrong-curve", CurveOID: testCurveA},
+			status: http.StatusOK,
+		},
+		{
+			name:   "curve_oid of another curve",
+			req:    SignRequest{PrivateKeyHex: keyHex, CertificateB64: certB64, CurveOID: testCurveB},
+			status: http.StatusBadRequest,
+			err:    "private key does not match certificate (key curve " + testCurveB + ", certificate curve " + testCurveA + ")",
+		},
+		{
+			name:   "stored curve of another curve",
+			req:    SignRequest{KeyID: "esia-wrong-curve"},
+			status: http.StatusBadRequest,
+			err:    "private key does not match certificate (key curve " + testCurveB + ", certificate curve " + testCurveA + ")",
+		},
+		{
+			name:   "unknown curve_oid",
+			req:    SignRequest{PrivateKeyHex: keyHex, CertificateB64: certB64, CurveOID: "1.2.3"},
+			status: http.StatusBadRequest,
+			err:    "unknown curve_oid: 1.2.3",
+		},
+		{
+			name:   "512-bit key for 256-bit certificate",
+			req:    SignRequest{PrivateKeyHex: hex.EncodeToString(key512.Raw()), CertificateB64: certB64},
+			status: http.StatusBadRequest,
+			err:    "private key is 64 bytes, curve " + testCurveA + " needs 32",
+		},
+		{
+			name:   "256-bit key for 512-bit certificate",
+			req:    SignRequest{PrivateKeyHex: keyHex, CertificateB64: base64.StdEncoding.EncodeToString(cert512)},
+			status: http.StatusBadRequest,
+			err:    "private key is 32 bytes, curve " + testCurve512A + " needs 64",
+		},
+		{
+			name:   "certificate of another key",
+			req:    SignRequest{PrivateKeyHex: keyHex, CertificateB64: base64.StdEncoding.EncodeToString(otherCert)},
+			status: http.StatusBadRequest,
+			err:    "private key does not match certificate (key curve " + testCurveA + ", certificate curve " + testCurveA + ")",
+		},
+		{
+			name:   "stored key with certificate of another key",
+			req:    SignRequest{KeyID: "esia", CertificateB64: base64.StdEncoding.EncodeToString(otherCert)},
+			status: http.StatusBadRequest,
+			err:    "private key does not match certificate (key curve " + testCurveA + ", certificate curve " + testCurveA + ")",
+		},
+		{
+			name:   "stored key without certificate",
+			req:    SignRequest{KeyID: "esia-no-cert"},
+			status: http.StatusBadRequest,
+			err:    "certificate is required",
+		},
+		{
+			name:   "unknown key_id",
+			req:    SignRequest{KeyID: "missing"},
+			status: http.StatusNotFound,
+			err:    "failed to load key: " + keystore.ErrNotFound.Error(),
+		},
+		{
+			name:   "key_id with private_key_hex",
+			req:    SignRequest{KeyID: "esia", PrivateKeyHex: keyHex},
+			status: http.StatusBadRequest,
+			err:    "key_id and private_key_hex are mutually exclusive",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.Message = testSignMessage
+			rec := sign(t, server, tt.req)
+			require.Equal(t, tt.status, rec.Code, rec.Body.String())
+
+			if tt.status != http.StatusOK {
+				var resp ErrorResponse
+				require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+				assert.Equal(t, tt.err, resp.Error)
+				return
+			}
+			var resp SignResponse
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+			signature, err := base64.StdEncoding.DecodeString(resp.SignatureB64)
+			require.NoError(t, err)
+			_, err = cms.Verify(signature, []byte(testSignMessage))
+			assert.NoError(t, err)
+		})
+	}
+}
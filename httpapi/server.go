@@ -0,0 +1,51 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/LdDl/esia-potato/gostx509"
+	"github.com/LdDl/esia-potato/keystore"
+)
+
+// Server holds shared state of HTTP handlers
+type Server struct {
+	// Key store for extracted keys. Nil disables key_id support
+	Store keystore.Store
+	// Trusted roots and intermediates. If set, signer certificate chain is validated before signing
+	TrustStore *gostx509.TrustStore
+	// Revocation checker for signer certificate chain (requires TrustStore)
+	RevocationChecker gostx509.RevocationChecker
+}
+
+// NewServer creates server backed by given key store (may be nil)
+func NewServer(store keystore.Store) *Server {
+	return &Server{
+		Store: store,
+	}
+}
+
+// defaultServer serves package-level handlers (no key store)
+var defaultServer = &Server{}
+
+// HandleExtract extracts key using server without key store
+func HandleExtract(w http.ResponseWriter, r *http.Request) {
+	defaultServer.HandleExtract(w, r)
+}
+
+// HandleSign signs message using server without key store
+func HandleSign(w http.ResponseWriter, r *http.Request) {
+	defaultServer.HandleSign(w, r)
+}
+
+// loadKey returns stored key with HTTP status to report on failure
+func (s *Server) loadKey(keyID string) (*keystore.Entry, int, error) {
+	if s.Store == nil {
+		return nil, http.StatusBadRequest, errors.New("key store is not configured")
+	}
+	entry, err := s.Store.Get(keyID)
+	if err != nil {
+		return nil, statusForStoreError(err), err
+	}
+	return entry, http.StatusOK, nil
+}
@@ -4,13 +4,16 @@ import (
 	"encoding/json"
 	"log/slog"
 	"net/http"
+	"time"
 )
 
 // ExtractResponse is the JSON response for /api/v1/extract
 // swagger:model
 type ExtractResponse struct {
-	// Private key in hexadecimal format
-	PrivateKeyHex string `json:"private_key_hex" example:"a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c1d2e3f4a5b6c7d8e9f0a1b2"`
+	// Key store id (if key was saved in key store)
+	KeyID string `json:"key_id,omitempty" example:"esia-prod"`
+	// Private key in hexadecimal format (omitted if key was saved in key store)
+	PrivateKeyHex string `json:"private_key_hex,omitempty" example:"a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c1d2e3f4a5b6c7d8e9f0a1b2"`
 	// Public key in hexadecimal format
 	PublicKeyHex string `json:"public_key_hex" example:"e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c1d2e3f4a5b6c7d8e9f0a1b2c3d4e5f6"`
 	// Key fingerprint
@@ -19,17 +22,44 @@ type ExtractResponse struct {
 	CurveOID string `json:"curve_oid" example:"1.2.643.2.2.36.0"`
 	// Certificate in base64 format (if found in container)
 	CertificateBase64 string `json:"certificate_base64,omitempty" example:"MIIBkTCB..."`
+	// Secondary key pair (if found in container)
+	SecondaryKey *KeyResponse `json:"secondary_key,omitempty"`
+}
+
+// KeyResponse describes a single extracted key pair
+// swagger:model
+type KeyResponse struct {
+	// Key store id (if key was saved in key store)
+	KeyID string `json:"key_id,omitempty" example:"esia-prod-secondary"`
+	// Private key in hexadecimal format (omitted if key was saved in key store)
+	PrivateKeyHex string `json:"private_key_hex,omitempty" example:"a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c1d2e3f4a5b6c7d8e9f0a1b2"`
+	// Public key in hexadecimal format
+	PublicKeyHex string `json:"public_key_hex" example:"e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c1d2e3f4a5b6c7d8e9f0a1b2c3d4e5f6"`
+	// Key fingerprint
+	Fingerprint string `json:"fingerprint" example:"0123456789abcdef"`
+	// Elliptic curve OID
+	CurveOID string `json:"curve_oid" example:"1.2.643.2.2.36.0"`
 }
 
 // SignRequest is the JSON request for /api/v1/sign
 // swagger:model
 type SignRequest struct {
+	// Id of key saved in key store (alternative to private_key_hex)
+	KeyID string `json:"key_id,omitempty" example:"esia-prod"`
 	// Private key in hexadecimal format
-	PrivateKeyHex string `json:"private_key_hex" example:"a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c1d2e3f4a5b6c7d8e9f0a1b2"`
-	// Certificate in base64 format
-	CertificateB64 string `json:"certificate_base64" example:"MIIBkTCB..."`
-	// Message to sign
-	Message string `json:"message" example:"openid2025.01.01 12:00:00 +0000CLIENT_ID12345"`
+	PrivateKeyHex string `json:"private_key_hex,omitempty" example:"a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c1d2e3f4a5b6c7d8e9f0a1b2"`
+	// Certificate in base64 format (optional with key_id if certificate is stored with key)
+	CertificateB64 string `json:"certificate_base64,omitempty" example:"MIIBkTCB..."`
+	// Elliptic curve OID of private key (as returned by /api/v1/extract). Taken from stored key or certificate if empty
+	CurveOID string `json:"curve_oid,omitempty" example:"1.2.643.2.2.36.0"`
+	// Message to sign (UTF-8 text)
+	Message string `json:"message,omitempty" example:"openid2025.01.01 12:00:00 +0000CLIENT_ID12345"`
+	// Message to sign in base64 format, for binary data (alternative to message)
+	MessageB64 string `json:"message_base64,omitempty" example:"b3BlbmlkMjAyNS4wMS4wMQ=="`
+	// Embed message into SignedData (attached signature). Detached signature is produced by default
+	Attached bool `json:"attached,omitempty" example:"false"`
+	// Time put into signingTime attribute (RFC 3339). Server clock is used if empty
+	SigningTime *time.Time `json:"signing_time,omitempty" example:"2025-01-01T12:00:00Z"`
 }
 
 // SignResponse is the JSON response for /api/v1/sign
@@ -39,6 +69,30 @@ type SignResponse struct {
 	SignatureB64 string `json:"signature_base64" example:"MIIBygYJKoZIhvcNAQc..."`
 }
 
+// KeyInfo describes stored key without private material
+// swagger:model
+type KeyInfo struct {
+	// Key store id
+	KeyID string `json:"key_id" example:"esia-prod"`
+	// Public key in hexadecimal format
+	PublicKeyHex string `json:"public_key_hex" example:"e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c1d2e3f4a5b6c7d8e9f0a1b2c3d4e5f6"`
+	// Key fingerprint
+	Fingerprint string `json:"fingerprint" example:"0123456789abcdef"`
+	// Elliptic curve OID
+	CurveOID string `json:"curve_oid" example:"1.2.643.2.2.36.0"`
+	// Whether certificate is stored with key
+	HasCertificate bool `json:"has_certificate" example:"true"`
+	// Time key was stored
+	CreatedAt time.Time `json:"created_at" example:"2025-01-01T12:00:00Z"`
+}
+
+// KeyListResponse is the JSON response for GET /api/v1/keys
+// swagger:model
+type KeyListResponse struct {
+	// Stored keys
+	Keys []KeyInfo `json:"keys"`
+}
+
 // ErrorResponse is the JSON error response
 // swagger:model
 type ErrorResponse struct {
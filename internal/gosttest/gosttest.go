@@ -0,0 +1,218 @@
+// Package gosttest builds GOST R 34.10-2012 keys and certificates for tests.
+// It produces plain DER, so packages which parse certificates (gostx509 included) can use it without import cycles
+package gosttest
+
+import (
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"hash"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/LdDl/esia-potato/utils"
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/ddulesov/gogost/gost34112012512"
+	"github.com/stretchr/testify/require"
+)
+
+// OIDs used in built certificates
+var (
+	oidPublicKey256 = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 1, 1}
+	oidPublicKey512 = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 1, 2}
+	oidSignature256 = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 3, 2}
+	oidSignature512 = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 3, 3}
+	oidDigest256    = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 2, 2}
+	oidDigest512    = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 2, 3}
+	// id-GostR3410-2001-CryptoPro-A-ParamSet
+	oidParamSetCryptoProA = asn1.ObjectIdentifier{1, 2, 643, 2, 2, 35, 1}
+	// id-tc26-gost-3410-12-512-paramSetA
+	oidParamSet512A = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 2, 1, 2, 1}
+)
+
+// Default validity of built certificates
+var (
+	NotBefore = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	NotAfter  = time.Date(2034, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// Template describes certificate built by Certificate
+type Template struct {
+	Serial  int64
+	Subject pkix.RDNSequence
+	// Issuer defaults to Subject
+	Issuer pkix.RDNSequence
+	// NotBefore and NotAfter default to package NotBefore and NotAfter
+	NotBefore  time.Time
+	NotAfter   time.Time
+	Extensions []pkix.Extension
+}
+
+type tbsCertificate struct {
+	Version            int `asn1:"optional,explicit,default:0,tag:0"`
+	SerialNumber       *big.Int
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Issuer             pkix.RDNSequence
+	Validity           validity
+	Subject            pkix.RDNSequence
+	PublicKey          publicKeyInfo
+	Extensions         []pkix.Extension `asn1:"omitempty,optional,explicit,tag:3"`
+}
+
+type validity struct {
+	NotBefore, NotAfter time.Time
+}
+
+type publicKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+type publicKeyParameters struct {
+	PublicKeyParamSet asn1.ObjectIdentifier
+	DigestParamSet    asn1.ObjectIdentifier `asn1:"optional"`
+}
+
+type certificate struct {
+	TBSCertificate     asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	SignatureValue     asn1.BitString
+}
+
+func newPrivateKey(t *testing.T, curve *gost3410.Curve, mode gost3410.Mode) *gost3410.PrivateKey {
+	keyBytes := make([]byte, int(mode))
+	// Keep the key well below curve order
+	_, err := rand.Read(keyBytes[:31])
+	require.NoError(t, err)
+	keyBytes[0] |= 0x01
+
+	prv, err := gost3410.NewPrivateKey(curve, mode, keyBytes)
+	require.NoError(t, err)
+	return prv
+}
+
+// PrivateKey generates 256-bit key on CryptoPro A curve
+func PrivateKey(t *testing.T) *gost3410.PrivateKey {
+	return newPrivateKey(t, gost3410.CurveIdGostR34102001CryptoProAParamSet(), gost3410.Mode2001)
+}
+
+// PrivateKey512 generates 512-bit key on tc26 paramSetA curve
+func PrivateKey512(t *testing.T) *gost3410.PrivateKey {
+	return newPrivateKey(t, gost3410.CurveIdtc26gost341012512paramSetA(), gost3410.Mode2012)
+}
+
+// Name returns distinguished name with common name only
+func Name(commonName string) pkix.RDNSequence {
+	return pkix.Name{CommonName: commonName}.ToRDNSequence()
+}
+
+// Extension marshals value into certificate (or CRL) extension
+func Extension(t *testing.T, oid asn1.ObjectIdentifier, critical bool, value interface{}) pkix.Extension {
+	der, err := asn1.Marshal(value)
+	require.NoError(t, err)
+	return pkix.Extension{Id: oid, Critical: critical, Value: der}
+}
+
+// SignatureAlgorithm returns GOST R 34.10-2012 signature algorithm matching key size
+func SignatureAlgorithm(key *gost3410.PrivateKey) pkix.AlgorithmIdentifier {
+	if len(key.Raw()) == 64 {
+		return pkix.AlgorithmIdentifier{Algorithm: oidSignature512}
+	}
+	return pkix.AlgorithmIdentifier{Algorithm: oidSignature256}
+}
+
+// Sign hashes data with GOST R 34.11-2012 of key size and signs the digest.
+// Result is a signature value as it appears in certificates, CRLs and OCSP responses
+func Sign(t *testing.T, key *gost3410.PrivateKey, data []byte) []byte {
+	var h hash.Hash = gost34112012256.New()
+	if len(key.Raw()) == 64 {
+		h = gost34112012512.New()
+	}
+	h.Write(data)
+	signature, err := key.SignDigest(utils.ReverseBytes(h.Sum(nil)), rand.Reader)
+	require.NoError(t, err)
+	return signature
+}
+
+// Certificate builds DER certificate for subjectKey signed by issuerKey
+func Certificate(t *testing.T, tmpl Template, subjectKey, issuerKey *gost3410.PrivateKey) []byte {
+	pub, err := subjectKey.PublicKey()
+	require.NoError(t, err)
+	pubRaw, err := asn1.Marshal(pub.Raw())
+	require.NoError(t, err)
+
+	keyParams := publicKeyParameters{PublicKeyParamSet: oidParamSetCryptoProA, DigestParamSet: oidDigest256}
+	keyAlg := oidPublicKey256
+	if len(subjectKey.Raw()) == 64 {
+		keyParams = publicKeyParameters{PublicKeyParamSet: oidParamSet512A, DigestParamSet: oidDigest512}
+		keyAlg = oidPublicKey512
+	}
+	params, err := asn1.Marshal(keyParams)
+	require.NoError(t, err)
+
+	if tmpl.Issuer == nil {
+		tmpl.Issuer = tmpl.Subject
+	}
+	if tmpl.NotBefore.IsZero() {
+		tmpl.NotBefore = NotBefore
+	}
+	if tmpl.NotAfter.IsZero() {
+		tmpl.NotAfter = NotAfter
+	}
+
+	sigAlg := SignatureAlgorithm(issuerKey)
+	tbsDER, err := asn1.Marshal(tbsCertificate{
+		Version:            2,
+		SerialNumber:       big.NewInt(tmpl.Serial),
+		SignatureAlgorithm: sigAlg,
+		Issuer:             tmpl.Issuer,
+		Validity:           validity{NotBefore: tmpl.NotBefore, NotAfter: tmpl.NotAfter},
+		Subject:            tmpl.Subject,
+		PublicKey: publicKeyInfo{
+			Algorithm: pkix.AlgorithmIdentifier{Algorithm: keyAlg, Parameters: asn1.RawValue{FullBytes: params}},
+			PublicKey: asn1.BitString{Bytes: pubRaw, BitLength: len(pubRaw) * 8},
+		},
+		Extensions: tmpl.Extensions,
+	})
+	require.NoError(t, err)
+
+	return Signed(t, tbsDER, sigAlg, issuerKey)
+}
+
+// Signed wraps DER of to-be-signed structure into SEQUENCE { tbs, algorithm, signature }
+// which is shared by certificates and CRLs
+func Signed(t *testing.T, tbsDER []byte, sigAlg pkix.AlgorithmIdentifier, key *gost3410.PrivateKey) []byte {
+	signature := Sign(t, key, tbsDER)
+	der, err := asn1.Marshal(certificate{
+		TBSCertificate:     asn1.RawValue{FullBytes: tbsDER},
+		SignatureAlgorithm: sigAlg,
+		SignatureValue:     asn1.BitString{Bytes: signature, BitLength: len(signature) * 8},
+	})
+	require.NoError(t, err)
+	return der
+}
+
+// SelfSigned builds self-signed DER certificate for key
+func SelfSigned(t *testing.T, key *gost3410.PrivateKey, commonName string, serial int64, extensions ...pkix.Extension) []byte {
+	return Certificate(t, Template{Serial: serial, Subject: Name(commonName), Extensions: extensions}, key, key)
+}
+
+// CA is a self-signed test certificate authority
+type CA struct {
+	Key  *gost3410.PrivateKey
+	Name pkix.RDNSequence
+	DER  []byte
+}
+
+// NewCA creates CA with 256-bit key
+func NewCA(t *testing.T, commonName string, extensions ...pkix.Extension) *CA {
+	key := PrivateKey(t)
+	return &CA{Key: key, Name: Name(commonName), DER: SelfSigned(t, key, commonName, 1, extensions...)}
+}
+
+// Issue builds DER certificate for key signed by ca
+func (ca *CA) Issue(t *testing.T, serial int64, commonName string, key *gost3410.PrivateKey, extensions ...pkix.Extension) []byte {
+	return Certificate(t, Template{Serial: serial, Subject: Name(commonName), Issuer: ca.Name, Extensions: extensions}, key, ca.Key)
+}
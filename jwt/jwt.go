@@ -0,0 +1,310 @@
+// Package jwt verifies ESIA-issued JWT (compact JWS) signed with GOST R 34.10-2012
+package jwt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"hash"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/LdDl/esia-potato/cms"
+	"github.com/LdDl/esia-potato/utils"
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/ddulesov/gogost/gost34112012512"
+	"github.com/pkg/errors"
+)
+
+// Supported JWS algorithms
+const (
+	// GOST R 34.10-2012 256-bit with Streebog-256
+	AlgGOST3410_2012_256 = "GOST3410_2012_256"
+	// GOST R 34.10-2012 512-bit with Streebog-512
+	AlgGOST3410_2012_512 = "GOST3410_2012_512"
+)
+
+// Sentinel errors
+var (
+	ErrMalformed        = fmt.Errorf("malformed token")
+	ErrAlgorithm        = fmt.Errorf("unsupported algorithm")
+	ErrSignatureInvalid = fmt.Errorf("token signature is invalid")
+	ErrExpired          = fmt.Errorf("token is expired")
+	ErrExpiryMissing    = fmt.Errorf("token has no exp claim")
+	ErrNotYetValid      = fmt.Errorf("token is not valid yet")
+	ErrIssuer           = fmt.Errorf("unexpected token issuer")
+	ErrClientID         = fmt.Errorf("token is issued for another client")
+	ErrPublicKeyMissing = fmt.Errorf("public key is required")
+)
+
+// Header is JOSE header of ESIA token
+type Header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	// ESIA token version
+	Ver int `json:"ver,omitempty"`
+	// ESIA token subtype: access, id, refresh
+	Sbt string `json:"sbt,omitempty"`
+}
+
+// Audience is "aud" claim which may be a string or an array of strings
+type Audience []string
+
+// UnmarshalJSON accepts both string and array forms
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// Contains reports whether audience includes the given value
+func (a Audience) Contains(value string) bool {
+	for _, v := range a {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Claims holds registered claims and common ESIA claims
+type Claims struct {
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	// Present in access tokens
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+	// ESIA user identifier (oid)
+	SubjectID int64 `json:"urn:esia:sbj_id,omitempty"`
+	// ESIA session identifier
+	SessionID string `json:"urn:esia:sid,omitempty"`
+}
+
+// Token is a parsed compact JWS
+type Token struct {
+	Raw       string
+	Header    Header
+	Claims    Claims
+	Payload   []byte
+	Signature []byte
+	// header.payload as it was signed
+	signingInput string
+}
+
+// DecodeClaims unmarshals token payload into v, e.g. to read claims not covered by Claims
+func (t *Token) DecodeClaims(v interface{}) error {
+	return json.Unmarshal(t.Payload, v)
+}
+
+// decodeSegment decodes base64url segment with or without padding
+func decodeSegment(seg string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(seg, "="))
+}
+
+// Parse decodes compact JWS without verifying it
+func Parse(token string) (*Token, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.Wrapf(ErrMalformed, "expected 3 segments, got %d", len(parts))
+	}
+
+	headerJSON, err := decodeSegment(parts[0])
+	if err != nil {
+		return nil, errors.Wrap(ErrMalformed, "header: "+err.Error())
+	}
+	payload, err := decodeSegment(parts[1])
+	if err != nil {
+		return nil, errors.Wrap(ErrMalformed, "payload: "+err.Error())
+	}
+	signature, err := decodeSegment(parts[2])
+	if err != nil {
+		return nil, errors.Wrap(ErrMalformed, "signature: "+err.Error())
+	}
+
+	t := &Token{
+		Raw:          token,
+		Payload:      payload,
+		Signature:    signature,
+		signingInput: parts[0] + "." + parts[1],
+	}
+	if err := json.Unmarshal(headerJSON, &t.Header); err != nil {
+		return nil, errors.Wrap(ErrMalformed, "header: "+err.Error())
+	}
+	if err := json.Unmarshal(payload, &t.Claims); err != nil {
+		return nil, errors.Wrap(ErrMalformed, "claims: "+err.Error())
+	}
+	return t, nil
+}
+
+// newHash returns digest for JWS algorithm and expected key size in bytes
+func newHash(alg string) (hash.Hash, int, error) {
+	switch alg {
+	case AlgGOST3410_2012_256:
+		return gost34112012256.New(), 32, nil
+	case AlgGOST3410_2012_512:
+		return gost34112012512.New(), 64, nil
+	default:
+		return nil, 0, errors.Wrapf(ErrAlgorithm, "alg %q", alg)
+	}
+}
+
+// VerifySignature checks token signature with the given public key
+func (t *Token) VerifySignature(pub *gost3410.PublicKey) error {
+	if pub == nil {
+		return ErrPublicKeyMissing
+	}
+	h, keySize, err := newHash(t.Header.Alg)
+	if err != nil {
+		return err
+	}
+	if len(pub.Raw()) != 2*keySize {
+		return errors.Wrapf(ErrAlgorithm, "alg %s does not match %d-bit key", t.Header.Alg, len(pub.Raw())*4)
+	}
+	if len(t.Signature) != 2*keySize {
+		return errors.Wrapf(ErrSignatureInvalid, "signature is %d bytes", len(t.Signature))
+	}
+
+	h.Write([]byte(t.signingInput))
+	// Same digest convention as in CMS: reversed before passing to gogost
+	digest := utils.ReverseBytes(h.Sum(nil))
+
+	valid, err := pub.VerifyDigest(digest, t.Signature)
+	if err != nil {
+		return errors.Wrap(err, "failed to verify signature")
+	}
+	if !valid {
+		// ESIA (CryptoPro CSP) emits signature in little-endian byte order,
+		// other GOST JOSE implementations use big-endian s||r as gogost does
+		valid, err = pub.VerifyDigest(digest, utils.ReverseBytes(t.Signature))
+		if err != nil {
+			return errors.Wrap(err, "failed to verify signature")
+		}
+	}
+	if !valid {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
+
+// Verifier verifies ESIA tokens: signature, lifetime, issuer and client
+type Verifier struct {
+	// ESIA public key
+	PublicKey *gost3410.PublicKey
+	// Expected "iss", skipped if empty.
+	// ESIA uses e.g. "http://esia-portal1.test.gosuslugi.ru/"
+	Issuer string
+	// Expected "client_id" of access tokens or "aud" of ID tokens, skipped if empty
+	ClientID string
+	// Allowed clock skew for exp and nbf
+	Leeway time.Duration
+	// Clock, time.Now if nil
+	Now func() time.Time
+}
+
+// NewVerifier creates verifier for tokens signed by the given key
+func NewVerifier(pub *gost3410.PublicKey, issuer, clientID string) *Verifier {
+	return &Verifier{
+		PublicKey: pub,
+		Issuer:    issuer,
+		ClientID:  clientID,
+	}
+}
+
+// NewVerifierFromCertificate creates verifier with ESIA public key loaded from certificate file (DER or PEM)
+func NewVerifierFromCertificate(path, issuer, clientID string) (*Verifier, error) {
+	certDER, err := LoadCertificate(path)
+	if err != nil {
+		return nil, err
+	}
+	pub, _, err := cms.CertificatePublicKey(certDER)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get ESIA public key")
+	}
+	return NewVerifier(pub, issuer, clientID), nil
+}
+
+// LoadCertificate reads DER or PEM encoded certificate and returns DER
+func LoadCertificate(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read certificate")
+	}
+	if block, _ := pem.Decode(data); block != nil {
+		return block.Bytes, nil
+	}
+	// Bare base64 without PEM armor is also common for ESIA certificates
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] != 0x30 {
+		if der, err := base64.StdEncoding.DecodeString(string(trimmed)); err == nil {
+			return der, nil
+		}
+	}
+	return data, nil
+}
+
+// Verify parses token and checks signature and claims
+func (v *Verifier) Verify(token string) (*Token, error) {
+	t, err := Parse(token)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.VerifySignature(v.PublicKey); err != nil {
+		return nil, err
+	}
+	if err := v.validateClaims(&t.Claims); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// validateClaims checks exp, nbf, iss and client
+func (v *Verifier) validateClaims(c *Claims) error {
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+
+	if c.ExpiresAt == 0 {
+		return ErrExpiryMissing
+	}
+	if !now.Before(time.Unix(c.ExpiresAt, 0).Add(v.Leeway)) {
+		return errors.Wrapf(ErrExpired, "expired at %s", time.Unix(c.ExpiresAt, 0).UTC())
+	}
+	if c.NotBefore != 0 && now.Add(v.Leeway).Before(time.Unix(c.NotBefore, 0)) {
+		return errors.Wrapf(ErrNotYetValid, "valid from %s", time.Unix(c.NotBefore, 0).UTC())
+	}
+	if v.Issuer != "" && c.Issuer != v.Issuer {
+		return errors.Wrapf(ErrIssuer, "got %q", c.Issuer)
+	}
+	if v.ClientID != "" {
+		// Access tokens carry client_id, ID tokens carry aud
+		switch {
+		case c.ClientID != "":
+			if c.ClientID != v.ClientID {
+				return errors.Wrapf(ErrClientID, "client_id %q", c.ClientID)
+			}
+		case len(c.Audience) > 0:
+			if !c.Audience.Contains(v.ClientID) {
+				return errors.Wrapf(ErrClientID, "aud %v", []string(c.Audience))
+			}
+		default:
+			return errors.Wrap(ErrClientID, "neither client_id nor aud present")
+		}
+	}
+	return nil
+}
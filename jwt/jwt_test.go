@@ -0,0 +1,155 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LdDl/esia-potato/utils"
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testNow = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func createTestKey(t *testing.T) *gost3410.PrivateKey {
+	keyBytes := make([]byte, 32)
+	_, err := rand.Read(keyBytes)
+	require.NoError(t, err)
+	prv, err := gost3410.NewPrivateKey(gost3410.CurveIdtc26gost34102012256paramSetA(), gost3410.Mode2001, keyBytes)
+	require.NoError(t, err)
+	return prv
+}
+
+// signTestToken builds compact JWS the way ESIA does
+func signTestToken(t *testing.T, prv *gost3410.PrivateKey, claims interface{}, littleEndian bool) string {
+	header, err := json.Marshal(Header{Alg: AlgGOST3410_2012_256, Typ: "JWT", Ver: 1, Sbt: "access"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	h, _, err := newHash(AlgGOST3410_2012_256)
+	require.NoError(t, err)
+	h.Write([]byte(signingInput))
+	signature, err := prv.SignDigest(utils.ReverseBytes(h.Sum(nil)), rand.Reader)
+	require.NoError(t, err)
+	if littleEndian {
+		utils.ReverseBytesInPlace(signature)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func createTestVerifier(t *testing.T, prv *gost3410.PrivateKey) *Verifier {
+	pub, err := prv.PublicKey()
+	require.NoError(t, err)
+	v := NewVerifier(pub, "http://esia-portal1.test.gosuslugi.ru/", "CLIENT_ID")
+	v.Now = func() time.Time { return testNow }
+	return v
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss":             "http://esia-portal1.test.gosuslugi.ru/",
+		"client_id":       "CLIENT_ID",
+		"scope":           "openid?oid=1000000000",
+		"exp":             testNow.Add(time.Hour).Unix(),
+		"nbf":             testNow.Add(-time.Minute).Unix(),
+		"iat":             testNow.Add(-time.Minute).Unix(),
+		"urn:esia:sbj_id": 1000000000,
+		"urn:esia:sid":    "session",
+	}
+}
+
+// go test -timeout 30s -run ^TestVerifyToken$ github.com/LdDl/esia-potato/jwt
+func TestVerifyToken(t *testing.T) {
+	prv := createTestKey(t)
+	verifier := createTestVerifier(t, prv)
+
+	for _, littleEndian := range []bool{false, true} {
+		token, err := verifier.Verify(signTestToken(t, prv, validClaims(), littleEndian))
+		require.NoError(t, err, "Verify failed (little-endian signature: %v)", littleEndian)
+		assert.Equal(t, "CLIENT_ID", token.Claims.ClientID)
+		assert.Equal(t, int64(1000000000), token.Claims.SubjectID)
+		assert.Equal(t, "session", token.Claims.SessionID)
+		assert.Equal(t, "access", token.Header.Sbt)
+
+		var custom struct {
+			Scope string `json:"scope"`
+		}
+		require.NoError(t, token.DecodeClaims(&custom))
+		assert.Equal(t, "openid?oid=1000000000", custom.Scope)
+	}
+}
+
+// go test -timeout 30s -run ^TestVerifyTokenFailures$ github.com/LdDl/esia-potato/jwt
+func TestVerifyTokenFailures(t *testing.T) {
+	prv := createTestKey(t)
+	verifier := createTestVerifier(t, prv)
+
+	_, err := verifier.Verify("not.a-token")
+	assert.ErrorIs(t, err, ErrMalformed)
+
+	// Signed by another key
+	_, err = verifier.Verify(signTestToken(t, createTestKey(t), validClaims(), false))
+	assert.ErrorIs(t, err, ErrSignatureInvalid)
+
+	// Tampered payload
+	parts := strings.Split(signTestToken(t, prv, validClaims(), false), ".")
+	claims := validClaims()
+	claims["client_id"] = "OTHER"
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+	_, err = verifier.Verify(strings.Join(parts, "."))
+	assert.ErrorIs(t, err, ErrSignatureInvalid)
+
+	cases := []struct {
+		name   string
+		modify func(map[string]interface{})
+		err    error
+	}{
+		{"expired", func(c map[string]interface{}) { c["exp"] = testNow.Add(-time.Second).Unix() }, ErrExpired},
+		{"no exp", func(c map[string]interface{}) { delete(c, "exp") }, ErrExpiryMissing},
+		{"not yet valid", func(c map[string]interface{}) { c["nbf"] = testNow.Add(time.Hour).Unix() }, ErrNotYetValid},
+		{"issuer", func(c map[string]interface{}) { c["iss"] = "https://evil.example.com/" }, ErrIssuer},
+		{"client_id", func(c map[string]interface{}) { c["client_id"] = "OTHER" }, ErrClientID},
+		{"no client", func(c map[string]interface{}) { delete(c, "client_id") }, ErrClientID},
+	}
+	for _, tc := range cases {
+		claims := validClaims()
+		tc.modify(claims)
+		_, err := verifier.Verify(signTestToken(t, prv, claims, false))
+		assert.ErrorIs(t, err, tc.err, tc.name)
+	}
+
+	// ID token: aud instead of client_id
+	claims = validClaims()
+	delete(claims, "client_id")
+	claims["aud"] = "CLIENT_ID"
+	_, err = verifier.Verify(signTestToken(t, prv, claims, false))
+	assert.NoError(t, err, "aud should be accepted for ID tokens")
+
+	// Leeway
+	claims = validClaims()
+	claims["exp"] = testNow.Add(-time.Second).Unix()
+	verifier.Leeway = time.Minute
+	_, err = verifier.Verify(signTestToken(t, prv, claims, false))
+	assert.NoError(t, err, "leeway should tolerate clock skew")
+}
+
+// go test -timeout 30s -run ^TestAudience$ github.com/LdDl/esia-potato/jwt
+func TestAudience(t *testing.T) {
+	var single Audience
+	require.NoError(t, json.Unmarshal([]byte(`"a"`), &single))
+	assert.Equal(t, Audience{"a"}, single)
+
+	var many Audience
+	require.NoError(t, json.Unmarshal([]byte(`["a","b"]`), &many))
+	assert.True(t, many.Contains("b"))
+	assert.False(t, many.Contains("c"))
+}
@@ -0,0 +1,278 @@
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// File with store-wide PBKDF2 salt
+	saltFile = "keystore.salt"
+	// Extension of entry files
+	entryExt = ".key"
+
+	saltSize         = 16
+	pbkdf2Iterations = 600000
+)
+
+// Sentinel errors of file store
+var (
+	ErrPassphraseRequired = fmt.Errorf("passphrase is required")
+	ErrDecrypt            = fmt.Errorf("failed to decrypt key (wrong passphrase or corrupted file)")
+)
+
+// FileStore keeps every entry in a separate AES-256-GCM encrypted file.
+// Encryption key is derived from passphrase with PBKDF2-SHA256 and a salt stored in the same directory.
+type FileStore struct {
+	dir  string
+	aead cipher.AEAD
+}
+
+// encryptedEntry is on-disk format of an entry
+type encryptedEntry struct {
+	Version    int    `json:"version"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// NewFileStore opens (or initializes) encrypted store in dir
+func NewFileStore(dir string, passphrase string) (*FileStore, error) {
+	if passphrase == "" {
+		return nil, ErrPassphraseRequired
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "failed to create key store directory")
+	}
+
+	salt, err := loadOrCreateSalt(filepath.Join(dir, saltFile))
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, pbkdf2Iterations, 32)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to derive encryption key")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create cipher")
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create GCM")
+	}
+
+	return &FileStore{
+		dir:  dir,
+		aead: aead,
+	}, nil
+}
+
+// loadOrCreateSalt reads store salt, generating it on first use
+func loadOrCreateSalt(path string) ([]byte, error) {
+	salt, err := os.ReadFile(path)
+	if err == nil {
+		if len(salt) != saltSize {
+			return nil, errors.Errorf("invalid salt file %s", path)
+		}
+		return salt, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "failed to read salt")
+	}
+
+	salt = make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errors.Wrap(err, "failed to generate salt")
+	}
+	if err := writeFileAtomic(path, salt); err != nil {
+		return nil, errors.Wrap(err, "failed to write salt")
+	}
+	return salt, nil
+}
+
+// writeFileAtomic writes data to temp file and renames it into place
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// writeFileExclusive writes data like writeFileAtomic but fails with os.ErrExist if path exists.
+// Complete file is hard linked to path, so readers never see partial data.
+func writeFileExclusive(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Link(tmp.Name(), path)
+}
+
+func (s *FileStore) entryPath(id string) string {
+	return filepath.Join(s.dir, id+entryExt)
+}
+
+// Put encrypts entry and writes it to <dir>/<id>.key
+func (s *FileStore) Put(id string, entry *Entry) error {
+	data, err := s.seal(id, entry)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.entryPath(id), data); err != nil {
+		return errors.Wrap(err, "failed to write entry")
+	}
+	return nil
+}
+
+// Create is Put failing with ErrExists if <dir>/<id>.key exists
+func (s *FileStore) Create(id string, entry *Entry) error {
+	data, err := s.seal(id, entry)
+	if err != nil {
+		return err
+	}
+	err = writeFileExclusive(s.entryPath(id), data)
+	if os.IsExist(err) {
+		return ErrExists
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to write entry")
+	}
+	return nil
+}
+
+// seal validates id and encrypts entry
+func (s *FileStore) seal(id string, entry *Entry) ([]byte, error) {
+	if err := ValidateID(id); err != nil {
+		return nil, err
+	}
+
+	plaintext, err := json.Marshal(entry)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal entry")
+	}
+
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "failed to generate nonce")
+	}
+
+	// Key id is bound as additional data so files can't be swapped
+	data, err := json.Marshal(encryptedEntry{
+		Version:    1,
+		Nonce:      nonce,
+		Ciphertext: s.aead.Seal(nil, nonce, plaintext, []byte(id)),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal encrypted entry")
+	}
+	return data, nil
+}
+
+// Get reads and decrypts entry
+func (s *FileStore) Get(id string) (*Entry, error) {
+	if err := ValidateID(id); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(s.entryPath(id))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read entry")
+	}
+
+	var encrypted encryptedEntry
+	if err := json.Unmarshal(data, &encrypted); err != nil {
+		return nil, errors.Wrap(err, "failed to parse entry")
+	}
+	if len(encrypted.Nonce) != s.aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+
+	plaintext, err := s.aead.Open(nil, encrypted.Nonce, encrypted.Ciphertext, []byte(id))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(plaintext, &entry); err != nil {
+		return nil, errors.Wrap(err, "failed to parse decrypted entry")
+	}
+	return &entry, nil
+}
+
+// Delete removes entry file
+func (s *FileStore) Delete(id string) error {
+	if err := ValidateID(id); err != nil {
+		return err
+	}
+	err := os.Remove(s.entryPath(id))
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to delete entry")
+	}
+	return nil
+}
+
+// List returns sorted ids of stored entries
+func (s *FileStore) List() ([]string, error) {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read key store directory")
+	}
+	ids := make([]string, 0, len(files))
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasSuffix(name, entryExt) {
+			continue
+		}
+		id := strings.TrimSuffix(name, entryExt)
+		if ValidateID(id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
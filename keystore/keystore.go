@@ -0,0 +1,60 @@
+// Package keystore persists extracted keys so they don't have to be sent with every signing request
+package keystore
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/LdDl/esia-potato/cryptopro"
+)
+
+// Sentinel errors
+var (
+	ErrNotFound  = fmt.Errorf("key not found")
+	ErrExists    = fmt.Errorf("key already exists")
+	ErrInvalidID = fmt.Errorf("invalid key id (allowed: letters, digits, '-', '_', up to 64 chars)")
+)
+
+// validID restricts key ids so they are safe to use as file names
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidateID checks that key id is acceptable for every backend
+func ValidateID(id string) error {
+	if !validID.MatchString(id) {
+		return ErrInvalidID
+	}
+	return nil
+}
+
+// Entry is a stored key with its certificate
+type Entry struct {
+	cryptopro.KeyData
+	// DER-encoded certificate (may be empty)
+	Certificate []byte
+	CreatedAt   time.Time
+}
+
+// Store keeps entries by key id
+type Store interface {
+	// Put saves entry under id, replacing existing one
+	Put(id string, entry *Entry) error
+	// Create saves entry under id or returns ErrExists if id is taken
+	Create(id string, entry *Entry) error
+	// Get returns entry or ErrNotFound
+	Get(id string) (*Entry, error)
+	// Delete removes entry or returns ErrNotFound
+	Delete(id string) error
+	// List returns ids of all stored entries
+	List() ([]string, error)
+}
+
+// copyEntry returns deep copy so callers can't modify stored data
+func copyEntry(e *Entry) *Entry {
+	clone := *e
+	clone.PrivateKey = append([]byte(nil), e.PrivateKey...)
+	clone.PublicKey = append([]byte(nil), e.PublicKey...)
+	clone.Fingerprint = append([]byte(nil), e.Fingerprint...)
+	clone.Certificate = append([]byte(nil), e.Certificate...)
+	return &clone
+}
@@ -0,0 +1,119 @@
+package keystore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LdDl/esia-potato/cryptopro"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEntry() *Entry {
+	return &Entry{
+		KeyData: cryptopro.KeyData{
+			PrivateKey:  []byte{0x01, 0x02, 0x03},
+			PublicKey:   []byte{0x04, 0x05},
+			Fingerprint: []byte{0x06, 0x07, 0x08, 0x09},
+			CurveOID:    "1.2.643.2.2.35.1",
+		},
+		Certificate: []byte{0x30, 0x00},
+		CreatedAt:   time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func testStore(t *testing.T, store Store) {
+	_, err := store.Get("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, store.Delete("missing"), ErrNotFound)
+	assert.ErrorIs(t, store.Put("../escape", testEntry()), ErrInvalidID)
+
+	entry := testEntry()
+	require.NoError(t, store.Put("key-b", entry))
+	require.NoError(t, store.Put("key_a", entry))
+
+	// Stored entry must not share memory with caller
+	entry.PrivateKey[0] = 0xff
+
+	got, err := store.Get("key-b")
+	require.NoError(t, err)
+	assert.Equal(t, testEntry(), got)
+
+	ids, err := store.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"key-b", "key_a"}, ids)
+
+	// Put replaces existing entry
+	replaced := testEntry()
+	replaced.CurveOID = "1.2.643.7.1.2.1.1.1"
+	require.NoError(t, store.Put("key-b", replaced))
+	got, err = store.Get("key-b")
+	require.NoError(t, err)
+	assert.Equal(t, replaced.CurveOID, got.CurveOID)
+
+	// Create never replaces
+	assert.ErrorIs(t, store.Create("key-b", testEntry()), ErrExists)
+	got, err = store.Get("key-b")
+	require.NoError(t, err)
+	assert.Equal(t, replaced.CurveOID, got.CurveOID)
+	assert.ErrorIs(t, store.Create("../escape", testEntry()), ErrInvalidID)
+	require.NoError(t, store.Create("key-c", testEntry()))
+	got, err = store.Get("key-c")
+	require.NoError(t, err)
+	assert.Equal(t, testEntry(), got)
+	require.NoError(t, store.Delete("key-c"))
+
+	require.NoError(t, store.Delete("key-b"))
+	_, err = store.Get("key-b")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	ids, err = store.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"key_a"}, ids)
+}
+
+// go test -timeout 30s -run ^TestMemoryStore$ github.com/LdDl/esia-potato/keystore
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+// go test -timeout 30s -run ^TestFileStore$ github.com/LdDl/esia-potato/keystore
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := NewFileStore(dir, "")
+	assert.ErrorIs(t, err, ErrPassphraseRequired)
+
+	store, err := NewFileStore(dir, "secret")
+	require.NoError(t, err)
+	testStore(t, store)
+
+	// Private key must not be stored in plaintext
+	data, err := os.ReadFile(filepath.Join(dir, "key_a.key"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "1.2.643.2.2.35.1")
+
+	info, err := os.Stat(filepath.Join(dir, "key_a.key"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// Reopening with same passphrase reads existing entries
+	reopened, err := NewFileStore(dir, "secret")
+	require.NoError(t, err)
+	got, err := reopened.Get("key_a")
+	require.NoError(t, err)
+	assert.Equal(t, testEntry(), got)
+
+	// Wrong passphrase fails to decrypt
+	wrong, err := NewFileStore(dir, "wrong")
+	require.NoError(t, err)
+	_, err = wrong.Get("key_a")
+	assert.ErrorIs(t, err, ErrDecrypt)
+
+	// Entry file renamed to another id fails authentication
+	require.NoError(t, os.Rename(filepath.Join(dir, "key_a.key"), filepath.Join(dir, "key_c.key")))
+	_, err = store.Get("key_c")
+	assert.ErrorIs(t, err, ErrDecrypt)
+}
@@ -0,0 +1,78 @@
+package keystore
+
+import (
+	"sort"
+	"sync"
+)
+
+// MemoryStore keeps entries in process memory. Entries are lost on restart.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	entries map[string]*Entry
+}
+
+// NewMemoryStore creates empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]*Entry),
+	}
+}
+
+// Put saves entry under id
+func (s *MemoryStore) Put(id string, entry *Entry) error {
+	if err := ValidateID(id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[id] = copyEntry(entry)
+	return nil
+}
+
+// Create saves entry under id if it is not taken
+func (s *MemoryStore) Create(id string, entry *Entry) error {
+	if err := ValidateID(id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[id]; ok {
+		return ErrExists
+	}
+	s.entries[id] = copyEntry(entry)
+	return nil
+}
+
+// Get returns entry by id
+func (s *MemoryStore) Get(id string) (*Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entry, ok := s.entries[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return copyEntry(entry), nil
+}
+
+// Delete removes entry by id
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.entries, id)
+	return nil
+}
+
+// List returns sorted ids
+func (s *MemoryStore) List() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]string, 0, len(s.entries))
+	for id := range s.entries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
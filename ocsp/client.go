@@ -0,0 +1,183 @@
+package ocsp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/LdDl/esia-potato/gostx509"
+	"github.com/pkg/errors"
+)
+
+var _ gostx509.RevocationChecker = (*Client)(nil)
+
+// Largest response accepted from responder
+const maxResponseSize = 1 << 20
+
+// Client queries OCSP responders and caches responses until their nextUpdate.
+// It implements gostx509.RevocationChecker.
+type Client struct {
+	// Defaults to http.DefaultClient
+	HTTPClient *http.Client
+	// Responder URL overriding OCSP URLs from certificates (AIA extension)
+	URL string
+	// Send nonce extension and require it in response. Responses with nonce are not cached.
+	UseNonce bool
+	// Clock, defaults to time.Now
+	Now func() time.Time
+	// Maximum age of response by its thisUpdate and producedAt, defaults to DefaultMaxAge.
+	// Limits responses without nextUpdate and cached ones.
+	MaxAge time.Duration
+
+	mu    sync.Mutex
+	cache map[string]*Response
+}
+
+// NewClient creates OCSP client.
+// Zero value of Client is usable as well
+func NewClient() *Client {
+	return &Client{
+		Now:   time.Now,
+		cache: make(map[string]*Response),
+	}
+}
+
+// now returns time of client clock
+func (c *Client) now() time.Time {
+	if c.Now == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
+
+// maxAge returns maximum age of accepted responses
+func (c *Client) maxAge() time.Duration {
+	if c.MaxAge <= 0 {
+		return DefaultMaxAge
+	}
+	return c.MaxAge
+}
+
+func cacheKey(id certID) string {
+	return hex.EncodeToString(id.IssuerKeyHash) + ":" + id.SerialNumber.Text(16)
+}
+
+// Check returns status of cert issued by issuer, from cache or from responder
+func (c *Client) Check(ctx context.Context, cert, issuer *gostx509.Certificate) (*Response, error) {
+	now := c.now()
+
+	id, err := newCertID(cert, issuer)
+	if err != nil {
+		return nil, err
+	}
+	key := cacheKey(id)
+
+	c.mu.Lock()
+	cached, ok := c.cache[key]
+	c.mu.Unlock()
+	if ok && cached.checkTime(now, c.maxAge()) == nil {
+		return cached, nil
+	}
+
+	urls := cert.OCSPServer
+	if c.URL != "" {
+		urls = []string{c.URL}
+	}
+	if len(urls) == 0 {
+		return nil, errors.Errorf("certificate %q has no OCSP responder", cert.Subject.CommonName)
+	}
+
+	var lastErr error
+	for _, url := range urls {
+		resp, err := c.query(ctx, url, cert, issuer)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if err := resp.checkTime(now, c.maxAge()); err != nil {
+			lastErr = err
+			continue
+		}
+		if !resp.NextUpdate.IsZero() && !c.UseNonce {
+			c.mu.Lock()
+			if c.cache == nil {
+				c.cache = make(map[string]*Response)
+			}
+			c.cache[key] = resp
+			c.mu.Unlock()
+		}
+		return resp, nil
+	}
+	return nil, lastErr
+}
+
+// query sends single OCSP request with POST
+func (c *Client) query(ctx context.Context, url string, cert, issuer *gostx509.Certificate) (*Response, error) {
+	var nonce []byte
+	if c.UseNonce {
+		nonce = make([]byte, 16)
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, errors.Wrap(err, "failed to generate nonce")
+		}
+	}
+
+	reqDER, err := CreateRequest(cert, issuer, nonce)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create OCSP request")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(reqDER))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create HTTP request")
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("Accept", "application/ocsp-response")
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	httpResp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "OCSP request failed")
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("OCSP responder %s returned status %d", url, httpResp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(httpResp.Body, maxResponseSize))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read OCSP response")
+	}
+
+	resp, err := ParseResponse(body, cert, issuer)
+	if err != nil {
+		return nil, err
+	}
+	if nonce != nil && !bytes.Equal(resp.Nonce, nonce) {
+		return nil, ErrNonceMismatch
+	}
+	return resp, nil
+}
+
+// CheckRevocation implements gostx509.RevocationChecker
+func (c *Client) CheckRevocation(ctx context.Context, cert, issuer *gostx509.Certificate) error {
+	resp, err := c.Check(ctx, cert, issuer)
+	if err != nil {
+		return errors.Wrapf(gostx509.ErrRevocationUnknown, "certificate %q: OCSP: %s", cert.Subject.CommonName, err.Error())
+	}
+	switch resp.Status {
+	case Good:
+		return nil
+	case Revoked:
+		return errors.Wrapf(gostx509.ErrRevoked, "certificate %q (serial %s) revoked at %s, reason %d",
+			cert.Subject.CommonName, cert.SerialNumber.Text(16), resp.RevokedAt.Format(time.RFC3339), resp.RevocationReason)
+	default:
+		return errors.Wrapf(gostx509.ErrRevocationUnknown, "certificate %q: OCSP status unknown", cert.Subject.CommonName)
+	}
+}
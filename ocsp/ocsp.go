@@ -0,0 +1,367 @@
+// Package ocsp implements OCSP (RFC 6960) client for GOST certificates: request builder,
+// parser of GOST-signed BasicOCSPResponse and caching revocation checker
+package ocsp
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/LdDl/esia-potato/gostx509"
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/pkg/errors"
+)
+
+// Sentinel errors
+var (
+	ErrMalformedResponse   = fmt.Errorf("malformed OCSP response")
+	ErrResponseStatus      = fmt.Errorf("OCSP responder returned error status")
+	ErrResponseType        = fmt.Errorf("unsupported OCSP response type")
+	ErrResponderNotAllowed = fmt.Errorf("OCSP responder is not authorized by issuer")
+	ErrCertIDMismatch      = fmt.Errorf("OCSP response is for another certificate")
+	ErrNonceMismatch       = fmt.Errorf("OCSP response nonce mismatch")
+	ErrResponseOutdated    = fmt.Errorf("OCSP response is outdated")
+)
+
+// OIDs
+var (
+	// GOST R 34.11-2012 256-bit hash, used in CertID
+	OIDGostR341112256 = asn1.ObjectIdentifier{1, 2, 643, 7, 1, 1, 2, 2}
+	// id-pkix-ocsp-basic
+	OIDBasicResponse = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+	// id-pkix-ocsp-nonce
+	OIDNonce = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 2}
+)
+
+// Status is certificate status from OCSP response
+type Status int
+
+const (
+	Good Status = iota
+	Revoked
+	Unknown
+)
+
+func (s Status) String() string {
+	switch s {
+	case Good:
+		return "good"
+	case Revoked:
+		return "revoked"
+	default:
+		return "unknown"
+	}
+}
+
+// ResponseStatus is OCSPResponseStatus
+type ResponseStatus int
+
+const (
+	Successful       ResponseStatus = 0
+	MalformedRequest ResponseStatus = 1
+	InternalError    ResponseStatus = 2
+	TryLater         ResponseStatus = 3
+	SigRequired      ResponseStatus = 5
+	Unauthorized     ResponseStatus = 6
+)
+
+// Allowed clock difference with responder
+const clockSkew = 5 * time.Minute
+
+// DefaultMaxAge is used by Client when MaxAge is not set
+const DefaultMaxAge = 24 * time.Hour
+
+type certID struct {
+	HashAlgorithm  pkix.AlgorithmIdentifier
+	IssuerNameHash []byte
+	IssuerKeyHash  []byte
+	SerialNumber   *big.Int
+}
+
+type ocspRequest struct {
+	TBSRequest tbsRequest
+}
+
+type tbsRequest struct {
+	Version           int              `asn1:"explicit,tag:0,default:0,optional"`
+	RequestList       []request        `asn1:""`
+	RequestExtensions []pkix.Extension `asn1:"explicit,tag:2,optional"`
+}
+
+type request struct {
+	Cert certID
+}
+
+type responseASN1 struct {
+	Status   asn1.Enumerated
+	Response responseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type responseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type basicResponse struct {
+	TBSResponseData    responseData
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type responseData struct {
+	Raw                asn1.RawContent
+	Version            int `asn1:"optional,default:0,explicit,tag:0"`
+	RawResponderID     asn1.RawValue
+	ProducedAt         time.Time `asn1:"generalized"`
+	Responses          []singleResponse
+	ResponseExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type singleResponse struct {
+	CertID           certID
+	Good             asn1.Flag        `asn1:"tag:0,optional"`
+	Revoked          revokedInfo      `asn1:"tag:1,optional"`
+	Unknown          asn1.Flag        `asn1:"tag:2,optional"`
+	ThisUpdate       time.Time        `asn1:"generalized"`
+	NextUpdate       time.Time        `asn1:"generalized,explicit,tag:0,optional"`
+	SingleExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type revokedInfo struct {
+	RevocationTime time.Time       `asn1:"generalized"`
+	Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional"`
+}
+
+// Response is a verified OCSP response for single certificate
+type Response struct {
+	// DER-encoded OCSPResponse
+	Raw          []byte
+	Status       Status
+	SerialNumber *big.Int
+	ProducedAt   time.Time
+	ThisUpdate   time.Time
+	// Zero if responder did not set it
+	NextUpdate       time.Time
+	RevokedAt        time.Time
+	RevocationReason int
+	// Certificate which signed the response (issuer or delegated responder)
+	Certificate *gostx509.Certificate
+	// Nonce echoed by responder (nil if absent)
+	Nonce []byte
+}
+
+// publicKeyBits returns value of subjectPublicKey BIT STRING of certificate
+func publicKeyBits(c *gostx509.Certificate) ([]byte, error) {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(c.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return nil, errors.Wrap(err, "failed to parse public key")
+	}
+	return spki.PublicKey.RightAlign(), nil
+}
+
+// newCertID builds CertID of cert with Streebog-256 hashes of issuer name and key
+func newCertID(cert, issuer *gostx509.Certificate) (certID, error) {
+	key, err := publicKeyBits(issuer)
+	if err != nil {
+		return certID{}, err
+	}
+
+	nameHash := gost34112012256.New()
+	nameHash.Write(issuer.RawSubject)
+	keyHash := gost34112012256.New()
+	keyHash.Write(key)
+
+	return certID{
+		HashAlgorithm:  pkix.AlgorithmIdentifier{Algorithm: OIDGostR341112256, Parameters: asn1.NullRawValue},
+		IssuerNameHash: nameHash.Sum(nil),
+		IssuerKeyHash:  keyHash.Sum(nil),
+		SerialNumber:   cert.SerialNumber,
+	}, nil
+}
+
+func (id certID) equal(other certID) bool {
+	return id.HashAlgorithm.Algorithm.Equal(other.HashAlgorithm.Algorithm) &&
+		bytes.Equal(id.IssuerNameHash, other.IssuerNameHash) &&
+		bytes.Equal(id.IssuerKeyHash, other.IssuerKeyHash) &&
+		id.SerialNumber.Cmp(other.SerialNumber) == 0
+}
+
+// CreateRequest builds DER-encoded OCSP request for cert issued by issuer.
+// Nonce is added as extension if not empty.
+func CreateRequest(cert, issuer *gostx509.Certificate, nonce []byte) ([]byte, error) {
+	id, err := newCertID(cert, issuer)
+	if err != nil {
+		return nil, err
+	}
+	req := ocspRequest{
+		TBSRequest: tbsRequest{
+			RequestList: []request{{Cert: id}},
+		},
+	}
+	if len(nonce) > 0 {
+		value, err := asn1.Marshal(nonce)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal nonce")
+		}
+		req.TBSRequest.RequestExtensions = []pkix.Extension{{Id: OIDNonce, Value: value}}
+	}
+	return asn1.Marshal(req)
+}
+
+// ParseResponse parses DER-encoded OCSP response for cert issued by issuer and verifies
+// its GOST signature. Response must be signed by issuer itself or by responder certificate
+// issued by issuer with id-kp-OCSPSigning extended key usage.
+func ParseResponse(der []byte, cert, issuer *gostx509.Certificate) (*Response, error) {
+	var resp responseASN1
+	rest, err := asn1.Unmarshal(der, &resp)
+	if err != nil {
+		return nil, errors.Wrap(ErrMalformedResponse, err.Error())
+	}
+	if len(rest) > 0 {
+		return nil, errors.Wrap(ErrMalformedResponse, "trailing data")
+	}
+	if status := ResponseStatus(resp.Status); status != Successful {
+		return nil, errors.Wrapf(ErrResponseStatus, "status %d", status)
+	}
+	if !resp.Response.ResponseType.Equal(OIDBasicResponse) {
+		return nil, errors.Wrapf(ErrResponseType, "oid: %s", resp.Response.ResponseType)
+	}
+
+	var basic basicResponse
+	if _, err := asn1.Unmarshal(resp.Response.Response, &basic); err != nil {
+		return nil, errors.Wrap(ErrMalformedResponse, err.Error())
+	}
+
+	signer, err := responderCertificate(&basic, issuer)
+	if err != nil {
+		return nil, err
+	}
+	if err := signer.CheckSignature(basic.SignatureAlgorithm.Algorithm, basic.TBSResponseData.Raw, basic.Signature.RightAlign()); err != nil {
+		return nil, errors.Wrap(err, "OCSP response signature")
+	}
+
+	id, err := newCertID(cert, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	var single *singleResponse
+	for i := range basic.TBSResponseData.Responses {
+		if basic.TBSResponseData.Responses[i].CertID.equal(id) {
+			single = &basic.TBSResponseData.Responses[i]
+			break
+		}
+	}
+	if single == nil {
+		return nil, errors.Wrapf(ErrCertIDMismatch, "serial %s", cert.SerialNumber.Text(16))
+	}
+
+	result := &Response{
+		Raw:          der,
+		SerialNumber: single.CertID.SerialNumber,
+		ProducedAt:   basic.TBSResponseData.ProducedAt,
+		ThisUpdate:   single.ThisUpdate,
+		NextUpdate:   single.NextUpdate,
+		Certificate:  signer,
+	}
+	switch {
+	case bool(single.Good):
+		result.Status = Good
+	case bool(single.Unknown):
+		result.Status = Unknown
+	default:
+		result.Status = Revoked
+		result.RevokedAt = single.Revoked.RevocationTime
+		result.RevocationReason = int(single.Revoked.Reason)
+	}
+
+	for _, ext := range basic.TBSResponseData.ResponseExtensions {
+		if ext.Id.Equal(OIDNonce) {
+			if _, err := asn1.Unmarshal(ext.Value, &result.Nonce); err != nil {
+				return nil, errors.Wrap(ErrMalformedResponse, "nonce: "+err.Error())
+			}
+		}
+	}
+	return result, nil
+}
+
+// responderCertificate picks certificate that signed response
+func responderCertificate(basic *basicResponse, issuer *gostx509.Certificate) (*gostx509.Certificate, error) {
+	responderID := basic.TBSResponseData.RawResponderID
+
+	// byName [1] Name or byKey [2] KeyHash
+	matches := func(c *gostx509.Certificate) bool {
+		switch responderID.Tag {
+		case 1:
+			return bytes.Equal(responderID.Bytes, c.RawSubject)
+		case 2:
+			var keyHash []byte
+			if _, err := asn1.Unmarshal(responderID.Bytes, &keyHash); err != nil {
+				return false
+			}
+			key, err := publicKeyBits(c)
+			if err != nil {
+				return false
+			}
+			// RFC 6960 defines SHA-1 key hash, some GOST responders use Streebog-256
+			sha := sha1.Sum(key)
+			streebog := gost34112012256.New()
+			streebog.Write(key)
+			return bytes.Equal(keyHash, sha[:]) || bytes.Equal(keyHash, streebog.Sum(nil))
+		}
+		return false
+	}
+
+	if matches(issuer) {
+		return issuer, nil
+	}
+
+	for _, raw := range basic.Certificates {
+		c, err := gostx509.ParseCertificate(raw.FullBytes)
+		if err != nil {
+			return nil, errors.Wrap(ErrMalformedResponse, err.Error())
+		}
+		if !matches(c) {
+			continue
+		}
+		// Delegated responder must be issued by issuer for OCSP signing
+		if err := c.CheckSignatureFrom(issuer); err != nil {
+			return nil, errors.Wrap(ErrResponderNotAllowed, err.Error())
+		}
+		if !c.ListsExtKeyUsage(gostx509.OIDExtKeyUsageOCSPSigning) {
+			// RFC 6960 4.2.2.2: id-kp-OCSPSigning must be present explicitly, anyExtendedKeyUsage is not enough
+			return nil, errors.Wrapf(ErrResponderNotAllowed, "certificate %q has no OCSPSigning usage", c.Subject.CommonName)
+		}
+		return c, nil
+	}
+	return nil, errors.Wrap(ErrResponderNotAllowed, "responder certificate not found")
+}
+
+// checkTime ensures response is current at now and not older than maxAge
+func (r *Response) checkTime(now time.Time, maxAge time.Duration) error {
+	if r.ThisUpdate.After(now.Add(clockSkew)) {
+		return errors.Wrapf(ErrResponseOutdated, "thisUpdate %s is in the future", r.ThisUpdate.Format(time.RFC3339))
+	}
+	// Response without nextUpdate would be current forever otherwise
+	if now.Sub(r.ThisUpdate) > maxAge+clockSkew {
+		return errors.Wrapf(ErrResponseOutdated, "thisUpdate %s is older than %s", r.ThisUpdate.Format(time.RFC3339), maxAge)
+	}
+	if now.Sub(r.ProducedAt) > maxAge+clockSkew {
+		return errors.Wrapf(ErrResponseOutdated, "producedAt %s is older than %s", r.ProducedAt.Format(time.RFC3339), maxAge)
+	}
+	if !r.NextUpdate.IsZero() && now.After(r.NextUpdate.Add(clockSkew)) {
+		return errors.Wrapf(ErrResponseOutdated, "nextUpdate %s has passed", r.NextUpdate.Format(time.RFC3339))
+	}
+	if err := r.Certificate.CheckValidity(now); err != nil {
+		return errors.Wrap(err, "OCSP responder certificate")
+	}
+	return nil
+}
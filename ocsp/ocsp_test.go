@@ -0,0 +1,381 @@
+package ocsp
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LdDl/esia-potato/gostx509"
+	"github.com/LdDl/esia-potato/internal/gosttest"
+	"github.com/ddulesov/gogost/gost3410"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testNow        = time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	testThisUpdate = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	testNextUpdate = time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC)
+)
+
+type testAccessDescription struct {
+	Method   asn1.ObjectIdentifier
+	Location asn1.RawValue
+}
+
+// createTestCertificate issues certificate for subjectKey signed by issuerKey
+func createTestCertificate(t *testing.T, serial int64, subject, issuer string, subjectKey, issuerKey *gost3410.PrivateKey, extensions ...pkix.Extension) *gostx509.Certificate {
+	der := gosttest.Certificate(t, gosttest.Template{
+		Serial:     serial,
+		Subject:    gosttest.Name(subject),
+		Issuer:     gosttest.Name(issuer),
+		Extensions: extensions,
+	}, subjectKey, issuerKey)
+	cert, err := gostx509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+// ocspExtension points certificate to OCSP responder
+func ocspExtension(t *testing.T, url string) pkix.Extension {
+	return gosttest.Extension(t, gostx509.OIDExtensionAuthorityInfoAccess, false, []testAccessDescription{{
+		Method:   gostx509.OIDAuthorityInfoAccessOCSP,
+		Location: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 6, Bytes: []byte(url)},
+	}})
+}
+
+// testResponder is in-process OCSP responder
+type testResponder struct {
+	t *testing.T
+	// Key and certificate signing responses
+	key  *gost3410.PrivateKey
+	cert *gostx509.Certificate
+	// Certificates included into response
+	certs   []*gostx509.Certificate
+	revoked map[int64]bool
+	unknown map[int64]bool
+	// Reply with malformedRequest status
+	fail bool
+	// thisUpdate and producedAt of responses, defaults to testThisUpdate
+	thisUpdate time.Time
+	// Omit nextUpdate
+	noNextUpdate bool
+	requests     int32
+}
+
+func (r *testResponder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	t := r.t
+	atomic.AddInt32(&r.requests, 1)
+	assert.Equal(t, "application/ocsp-request", req.Header.Get("Content-Type"))
+
+	if r.fail {
+		der, err := asn1.Marshal(struct{ Status asn1.Enumerated }{asn1.Enumerated(MalformedRequest)})
+		require.NoError(t, err)
+		w.Write(der)
+		return
+	}
+
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	var ocspReq ocspRequest
+	_, err = asn1.Unmarshal(body, &ocspReq)
+	require.NoError(t, err)
+	require.Len(t, ocspReq.TBSRequest.RequestList, 1)
+	id := ocspReq.TBSRequest.RequestList[0].Cert
+
+	thisUpdate := testThisUpdate
+	if !r.thisUpdate.IsZero() {
+		thisUpdate = r.thisUpdate
+	}
+	single := singleResponse{CertID: id, ThisUpdate: thisUpdate}
+	if !r.noNextUpdate {
+		single.NextUpdate = testNextUpdate
+	}
+	switch serial := id.SerialNumber.Int64(); {
+	case r.revoked[serial]:
+		single.Revoked = revokedInfo{RevocationTime: testThisUpdate.Add(-time.Hour), Reason: 1}
+	case r.unknown[serial]:
+		single.Unknown = true
+	default:
+		single.Good = true
+	}
+
+	data := responseData{
+		RawResponderID: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 1, IsCompound: true, Bytes: r.cert.RawSubject},
+		ProducedAt:     thisUpdate,
+		Responses:      []singleResponse{single},
+		// Echo nonce
+		ResponseExtensions: ocspReq.TBSRequest.RequestExtensions,
+	}
+	tbsDER, err := asn1.Marshal(data)
+	require.NoError(t, err)
+	data.Raw = tbsDER
+
+	signature := gosttest.Sign(t, r.key, tbsDER)
+	basic := basicResponse{
+		TBSResponseData:    data,
+		SignatureAlgorithm: gosttest.SignatureAlgorithm(r.key),
+		Signature:          asn1.BitString{Bytes: signature, BitLength: len(signature) * 8},
+	}
+	for _, c := range r.certs {
+		basic.Certificates = append(basic.Certificates, asn1.RawValue{FullBytes: c.Raw})
+	}
+	basicDER, err := asn1.Marshal(basic)
+	require.NoError(t, err)
+
+	der, err := asn1.Marshal(responseASN1{
+		Status:   asn1.Enumerated(Successful),
+		Response: responseBytes{ResponseType: OIDBasicResponse, Response: basicDER},
+	})
+	require.NoError(t, err)
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	w.Write(der)
+}
+
+type testCA struct {
+	key  *gost3410.PrivateKey
+	cert *gostx509.Certificate
+}
+
+func createTestCA(t *testing.T) *testCA {
+	key := gosttest.PrivateKey(t)
+	return &testCA{key: key, cert: createTestCertificate(t, 1, "Test CA", "Test CA", key, key)}
+}
+
+func newTestClient(server *httptest.Server) *Client {
+	client := NewClient()
+	client.HTTPClient = server.Client()
+	client.Now = func() time.Time { return testNow }
+	return client
+}
+
+// go test -timeout 30s -run ^TestCreateRequest$ github.com/LdDl/esia-potato/ocsp
+func TestCreateRequest(t *testing.T) {
+	ca := createTestCA(t)
+	leaf := createTestCertificate(t, 10, "Leaf", "Test CA", gosttest.PrivateKey(t), ca.key)
+
+	der, err := CreateRequest(leaf, ca.cert, []byte("0123456789abcdef"))
+	require.NoError(t, err)
+
+	var req ocspRequest
+	rest, err := asn1.Unmarshal(der, &req)
+	require.NoError(t, err)
+	assert.Empty(t, rest)
+	require.Len(t, req.TBSRequest.RequestList, 1)
+
+	id := req.TBSRequest.RequestList[0].Cert
+	assert.True(t, id.HashAlgorithm.Algorithm.Equal(OIDGostR341112256))
+	assert.Len(t, id.IssuerNameHash, 32)
+	assert.Len(t, id.IssuerKeyHash, 32)
+	assert.Equal(t, int64(10), id.SerialNumber.Int64())
+
+	require.Len(t, req.TBSRequest.RequestExtensions, 1)
+	assert.True(t, req.TBSRequest.RequestExtensions[0].Id.Equal(OIDNonce))
+}
+
+// go test -timeout 30s -run ^TestClient$ github.com/LdDl/esia-potato/ocsp
+func TestClient(t *testing.T) {
+	ca := createTestCA(t)
+	responder := &testResponder{t: t, key: ca.key, cert: ca.cert, revoked: map[int64]bool{11: true}, unknown: map[int64]bool{12: true}}
+	server := httptest.NewServer(responder)
+	defer server.Close()
+
+	newLeaf := func(serial int64) *gostx509.Certificate {
+		return createTestCertificate(t, serial, "Leaf", "Test CA", gosttest.PrivateKey(t), ca.key, ocspExtension(t, server.URL))
+	}
+	client := newTestClient(server)
+	ctx := context.Background()
+
+	good := newLeaf(10)
+	resp, err := client.Check(ctx, good, ca.cert)
+	require.NoError(t, err)
+	assert.Equal(t, Good, resp.Status)
+	assert.Equal(t, testNextUpdate, resp.NextUpdate)
+	assert.Equal(t, ca.cert, resp.Certificate)
+	assert.NoError(t, client.CheckRevocation(ctx, good, ca.cert))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&responder.requests), "response should be cached until next update")
+
+	revoked := newLeaf(11)
+	resp, err = client.Check(ctx, revoked, ca.cert)
+	require.NoError(t, err)
+	assert.Equal(t, Revoked, resp.Status)
+	assert.Equal(t, 1, resp.RevocationReason)
+	assert.ErrorIs(t, client.CheckRevocation(ctx, revoked, ca.cert), gostx509.ErrRevoked)
+
+	assert.ErrorIs(t, client.CheckRevocation(ctx, newLeaf(12), ca.cert), gostx509.ErrRevocationUnknown)
+
+	// Cached response expires at next update
+	requests := atomic.LoadInt32(&responder.requests)
+	client.Now = func() time.Time { return testNextUpdate.Add(time.Hour) }
+	assert.ErrorIs(t, client.CheckRevocation(ctx, good, ca.cert), gostx509.ErrRevocationUnknown)
+	assert.Equal(t, requests+1, atomic.LoadInt32(&responder.requests))
+
+	// Certificate without OCSP URL
+	noURL := createTestCertificate(t, 13, "Leaf", "Test CA", gosttest.PrivateKey(t), ca.key)
+	client.Now = func() time.Time { return testNow }
+	assert.ErrorIs(t, client.CheckRevocation(ctx, noURL, ca.cert), gostx509.ErrRevocationUnknown)
+	client.URL = server.URL
+	assert.NoError(t, client.CheckRevocation(ctx, noURL, ca.cert))
+}
+
+// go test -timeout 30s -run ^TestClientMaxAge$ github.com/LdDl/esia-potato/ocsp
+func TestClientMaxAge(t *testing.T) {
+	ca := createTestCA(t)
+	responder := &testResponder{t: t, key: ca.key, cert: ca.cert, noNextUpdate: true}
+	server := httptest.NewServer(responder)
+	defer server.Close()
+
+	leaf := createTestCertificate(t, 10, "Leaf", "Test CA", gosttest.PrivateKey(t), ca.key, ocspExtension(t, server.URL))
+	client := newTestClient(server)
+	ctx := context.Background()
+
+	resp, err := client.Check(ctx, leaf, ca.cert)
+	require.NoError(t, err)
+	assert.True(t, resp.NextUpdate.IsZero())
+
+	// Response without nextUpdate is limited by DefaultMaxAge
+	client.Now = func() time.Time { return testThisUpdate.Add(DefaultMaxAge + time.Hour) }
+	_, err = client.Check(ctx, leaf, ca.cert)
+	assert.ErrorIs(t, err, ErrResponseOutdated)
+	assert.ErrorIs(t, client.CheckRevocation(ctx, leaf, ca.cert), gostx509.ErrRevocationUnknown)
+
+	client.MaxAge = 48 * time.Hour
+	assert.NoError(t, client.CheckRevocation(ctx, leaf, ca.cert))
+
+	// Cached response with nextUpdate is limited too
+	responder.noNextUpdate = false
+	client.MaxAge = time.Hour
+	client.Now = func() time.Time { return testThisUpdate.Add(30 * time.Minute) }
+	require.NoError(t, client.CheckRevocation(ctx, leaf, ca.cert))
+	requests := atomic.LoadInt32(&responder.requests)
+	client.Now = func() time.Time { return testThisUpdate.Add(2 * time.Hour) }
+	_, err = client.Check(ctx, leaf, ca.cert)
+	assert.ErrorIs(t, err, ErrResponseOutdated)
+	assert.Equal(t, requests+1, atomic.LoadInt32(&responder.requests), "outdated cached response should be queried again")
+
+	// Fresh response is accepted again
+	responder.thisUpdate = testThisUpdate.Add(90 * time.Minute)
+	assert.NoError(t, client.CheckRevocation(ctx, leaf, ca.cert))
+}
+
+// go test -timeout 30s -run ^TestClientZeroValue$ github.com/LdDl/esia-potato/ocsp
+func TestClientZeroValue(t *testing.T) {
+	ca := createTestCA(t)
+	// Response is current at real clock
+	responder := &testResponder{t: t, key: ca.key, cert: ca.cert, thisUpdate: time.Now().UTC().Truncate(time.Second), noNextUpdate: true}
+	server := httptest.NewServer(responder)
+	defer server.Close()
+
+	leaf := createTestCertificate(t, 10, "Leaf", "Test CA", gosttest.PrivateKey(t), ca.key)
+	client := &Client{URL: server.URL}
+	assert.NoError(t, client.CheckRevocation(context.Background(), leaf, ca.cert))
+
+	// Response with nextUpdate is cached without NewClient
+	responder.thisUpdate = time.Time{}
+	responder.noNextUpdate = false
+	client = &Client{URL: server.URL, Now: func() time.Time { return testNow }}
+	assert.NoError(t, client.CheckRevocation(context.Background(), leaf, ca.cert))
+	assert.Len(t, client.cache, 1)
+}
+
+// go test -timeout 30s -run ^TestClientNonce$ github.com/LdDl/esia-potato/ocsp
+func TestClientNonce(t *testing.T) {
+	ca := createTestCA(t)
+	responder := &testResponder{t: t, key: ca.key, cert: ca.cert}
+	server := httptest.NewServer(responder)
+	defer server.Close()
+
+	leaf := createTestCertificate(t, 10, "Leaf", "Test CA", gosttest.PrivateKey(t), ca.key, ocspExtension(t, server.URL))
+	client := newTestClient(server)
+	client.UseNonce = true
+
+	resp, err := client.Check(context.Background(), leaf, ca.cert)
+	require.NoError(t, err)
+	assert.Len(t, resp.Nonce, 16)
+
+	// Responses with nonce are not cached
+	_, err = client.Check(context.Background(), leaf, ca.cert)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&responder.requests))
+}
+
+// go test -timeout 30s -run ^TestDelegatedResponder$ github.com/LdDl/esia-potato/ocsp
+func TestDelegatedResponder(t *testing.T) {
+	ca := createTestCA(t)
+	responderKey := gosttest.PrivateKey(t)
+	ocspSigning := gosttest.Extension(t, gostx509.OIDExtensionExtendedKeyUsage, false, []asn1.ObjectIdentifier{gostx509.OIDExtKeyUsageOCSPSigning})
+	responderCert := createTestCertificate(t, 2, "Test OCSP", "Test CA", responderKey, ca.key, ocspSigning)
+
+	responder := &testResponder{t: t, key: responderKey, cert: responderCert, certs: []*gostx509.Certificate{responderCert}}
+	server := httptest.NewServer(responder)
+	defer server.Close()
+
+	leaf := createTestCertificate(t, 10, "Leaf", "Test CA", gosttest.PrivateKey(t), ca.key, ocspExtension(t, server.URL))
+	resp, err := newTestClient(server).Check(context.Background(), leaf, ca.cert)
+	require.NoError(t, err)
+	assert.Equal(t, Good, resp.Status)
+	assert.Equal(t, "Test OCSP", resp.Certificate.Subject.CommonName)
+
+	// Responder without OCSPSigning usage
+	responder.cert = createTestCertificate(t, 3, "Test OCSP", "Test CA", responderKey, ca.key)
+	responder.certs = []*gostx509.Certificate{responder.cert}
+	_, err = newTestClient(server).Check(context.Background(), leaf, ca.cert)
+	assert.ErrorIs(t, err, ErrResponderNotAllowed)
+
+	// anyExtendedKeyUsage does not make certificate OCSP responder
+	anyUsage := gosttest.Extension(t, gostx509.OIDExtensionExtendedKeyUsage, false, []asn1.ObjectIdentifier{gostx509.OIDExtKeyUsageAny})
+	responder.cert = createTestCertificate(t, 5, "Test OCSP", "Test CA", responderKey, ca.key, anyUsage)
+	responder.certs = []*gostx509.Certificate{responder.cert}
+	_, err = newTestClient(server).Check(context.Background(), leaf, ca.cert)
+	assert.ErrorIs(t, err, ErrResponderNotAllowed)
+
+	// Responder issued by another CA
+	other := createTestCA(t)
+	responder.cert = createTestCertificate(t, 4, "Test OCSP", "Test CA", responderKey, other.key, ocspSigning)
+	responder.certs = []*gostx509.Certificate{responder.cert}
+	_, err = newTestClient(server).Check(context.Background(), leaf, ca.cert)
+	assert.ErrorIs(t, err, ErrResponderNotAllowed)
+
+	// Response signed by key not matching responder certificate
+	responder.key = gosttest.PrivateKey(t)
+	responder.cert = responderCert
+	responder.certs = []*gostx509.Certificate{responderCert}
+	_, err = newTestClient(server).Check(context.Background(), leaf, ca.cert)
+	assert.ErrorIs(t, err, gostx509.ErrSignatureInvalid)
+}
+
+// go test -timeout 30s -run ^TestParseResponseFailures$ github.com/LdDl/esia-potato/ocsp
+func TestParseResponseFailures(t *testing.T) {
+	ca := createTestCA(t)
+	responder := &testResponder{t: t, key: ca.key, cert: ca.cert, fail: true}
+	server := httptest.NewServer(responder)
+	defer server.Close()
+
+	leaf := createTestCertificate(t, 10, "Leaf", "Test CA", gosttest.PrivateKey(t), ca.key, ocspExtension(t, server.URL))
+	_, err := newTestClient(server).Check(context.Background(), leaf, ca.cert)
+	assert.ErrorIs(t, err, ErrResponseStatus)
+
+	_, err = ParseResponse([]byte{0x30, 0x00}, leaf, ca.cert)
+	assert.ErrorIs(t, err, ErrMalformedResponse)
+
+	// Response for another issuer
+	responder.fail = false
+	other := createTestCA(t)
+	otherLeaf := createTestCertificate(t, 10, "Leaf", "Test CA", gosttest.PrivateKey(t), other.key)
+	reqDER, err := CreateRequest(otherLeaf, other.cert, nil)
+	require.NoError(t, err)
+	httpResp, err := server.Client().Post(server.URL, "application/ocsp-request", bytes.NewReader(reqDER))
+	require.NoError(t, err)
+	defer httpResp.Body.Close()
+	body, err := io.ReadAll(httpResp.Body)
+	require.NoError(t, err)
+	_, err = ParseResponse(body, leaf, ca.cert)
+	assert.ErrorIs(t, err, ErrCertIDMismatch)
+}
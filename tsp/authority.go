@@ -0,0 +1,118 @@
+package tsp
+
+import (
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/LdDl/esia-potato/cms"
+	"github.com/pkg/errors"
+)
+
+// Authority is a minimal local time-stamp authority signing TSTInfo with cms.Signer.
+// It is intended for tests and development. Signer should be created with
+// cms.WithContentType(OIDContentTypeTSTInfo) and certificate with timeStamping usage.
+type Authority struct {
+	Signer *cms.Signer
+	// TSA policy put into tokens
+	Policy asn1.ObjectIdentifier
+	// Clock, defaults to time.Now
+	Now func() time.Time
+
+	mu     sync.Mutex
+	serial int64
+}
+
+// NewAuthority creates time-stamp authority
+func NewAuthority(signer *cms.Signer, policy asn1.ObjectIdentifier) *Authority {
+	return &Authority{
+		Signer: signer,
+		Policy: policy,
+		Now:    time.Now,
+	}
+}
+
+// Respond builds DER-encoded TimeStampResp for DER-encoded TimeStampReq.
+// Invalid requests get rejection response, error is returned only if response can't be built.
+func (a *Authority) Respond(reqDER []byte) ([]byte, error) {
+	var req timeStampReq
+	rest, err := asn1.Unmarshal(reqDER, &req)
+	if err != nil || len(rest) > 0 || req.Version != 1 {
+		return rejection(FailureBadDataFormat, "malformed request")
+	}
+	if _, err := newHash(req.MessageImprint.HashAlgorithm.Algorithm); err != nil {
+		return rejection(FailureBadAlg, "unsupported hash algorithm")
+	}
+	if len(req.ReqPolicy) > 0 && !req.ReqPolicy.Equal(a.Policy) {
+		return rejection(FailureUnacceptedPolicy, "unaccepted policy")
+	}
+	if len(req.Extensions) > 0 {
+		return rejection(FailureUnacceptedExtension, "extensions are not supported")
+	}
+
+	a.mu.Lock()
+	a.serial++
+	serial := a.serial
+	a.mu.Unlock()
+
+	now := time.Now
+	if a.Now != nil {
+		now = a.Now
+	}
+	info, err := asn1.Marshal(TSTInfo{
+		Version:        1,
+		Policy:         a.Policy,
+		MessageImprint: req.MessageImprint,
+		SerialNumber:   big.NewInt(serial),
+		GenTime:        now().UTC().Truncate(time.Second),
+		Nonce:          req.Nonce,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal TSTInfo")
+	}
+
+	token, err := a.Signer.SignAttached(info)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign TSTInfo")
+	}
+	return asn1.Marshal(timeStampResp{
+		Status:         pkiStatusInfo{Status: StatusGranted},
+		TimeStampToken: asn1.RawValue{FullBytes: token},
+	})
+}
+
+// rejection builds TimeStampResp with rejection status
+func rejection(failure int, text string) ([]byte, error) {
+	failInfo := asn1.BitString{Bytes: make([]byte, failure/8+1), BitLength: failure + 1}
+	failInfo.Bytes[failure/8] |= 0x80 >> uint(failure%8)
+	return asn1.Marshal(timeStampResp{
+		Status: pkiStatusInfo{
+			Status:       StatusRejection,
+			StatusString: []string{text},
+			FailInfo:     failInfo,
+		},
+	})
+}
+
+// ServeHTTP handles RFC 3161 requests over HTTP (POST application/timestamp-query)
+func (a *Authority) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	reqDER, err := io.ReadAll(io.LimitReader(r.Body, maxResponseSize))
+	if err != nil {
+		http.Error(w, "failed to read request", http.StatusBadRequest)
+		return
+	}
+	resp, err := a.Respond(reqDER)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/timestamp-reply")
+	w.Write(resp)
+}
@@ -0,0 +1,106 @@
+package tsp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"net/http"
+
+	"github.com/LdDl/esia-potato/cms"
+	"github.com/pkg/errors"
+)
+
+// Largest response accepted from TSA
+const maxResponseSize = 1 << 20
+
+var _ cms.Timestamper = (*Client)(nil)
+
+// Client requests timestamps from TSA over HTTP. It implements cms.Timestamper.
+type Client struct {
+	// TSA URL
+	URL string
+	// Defaults to http.DefaultClient
+	HTTPClient *http.Client
+	// Hash algorithm of message imprint, Streebog-256 by default
+	HashOID asn1.ObjectIdentifier
+	// Requested TSA policy (optional)
+	Policy asn1.ObjectIdentifier
+	// Options of token verification (e.g. cms.WithTrustStore)
+	VerifyOptions []cms.Option
+}
+
+// NewClient creates TSP client for TSA at url
+func NewClient(url string) *Client {
+	return &Client{
+		URL:     url,
+		HashOID: cms.OIDGostR341112256,
+	}
+}
+
+// Request timestamps data: its hash is sent to TSA and returned token is verified
+func (c *Client) Request(ctx context.Context, data []byte) (*Token, error) {
+	hashOID := c.HashOID
+	if hashOID == nil {
+		hashOID = cms.OIDGostR341112256
+	}
+	imprint, err := NewMessageImprint(hashOID, data)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate nonce")
+	}
+	reqDER, err := CreateRequest(imprint, c.Policy, nonce)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create time-stamp request")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(reqDER))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create HTTP request")
+	}
+	req.Header.Set("Content-Type", "application/timestamp-query")
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "time-stamp request failed")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("TSA %s returned status %d", c.URL, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read time-stamp response")
+	}
+
+	token, err := ParseResponse(body, c.VerifyOptions...)
+	if err != nil {
+		return nil, err
+	}
+	if err := token.Verify(data); err != nil {
+		return nil, err
+	}
+	if token.Info.Nonce == nil || token.Info.Nonce.Cmp(nonce) != 0 {
+		return nil, ErrNonceMismatch
+	}
+	return token, nil
+}
+
+// Timestamp implements cms.Timestamper
+func (c *Client) Timestamp(ctx context.Context, signature []byte) ([]byte, error) {
+	token, err := c.Request(ctx, signature)
+	if err != nil {
+		return nil, err
+	}
+	return token.Raw, nil
+}
@@ -0,0 +1,245 @@
+// Package tsp implements RFC 3161 Time-Stamp Protocol with GOST hashes and signatures:
+// request builder, token parser and validator, HTTP client and local time-stamp authority
+package tsp
+
+import (
+	"bytes"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"hash"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/LdDl/esia-potato/cms"
+	"github.com/LdDl/esia-potato/gostx509"
+	"github.com/ddulesov/gogost/gost34112012256"
+	"github.com/ddulesov/gogost/gost34112012512"
+	"github.com/pkg/errors"
+)
+
+// Sentinel errors
+var (
+	ErrMalformedResponse      = fmt.Errorf("malformed time-stamp response")
+	ErrRejected               = fmt.Errorf("time-stamp request rejected")
+	ErrUnsupportedHash        = fmt.Errorf("unsupported hash algorithm")
+	ErrContentType            = fmt.Errorf("time-stamp token content is not TSTInfo")
+	ErrMessageImprintMismatch = fmt.Errorf("time-stamp token message imprint mismatch")
+	ErrNonceMismatch          = fmt.Errorf("time-stamp token nonce mismatch")
+	ErrTSACertificate         = fmt.Errorf("certificate is not allowed for time-stamping")
+	ErrTimestampMissing       = fmt.Errorf("signature has no timestamp")
+)
+
+// OIDs
+var (
+	// id-ct-TSTInfo
+	OIDContentTypeTSTInfo = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+)
+
+// PKIStatus values of TimeStampResp
+const (
+	StatusGranted                = 0
+	StatusGrantedWithMods        = 1
+	StatusRejection              = 2
+	StatusWaiting                = 3
+	StatusRevocationWarning      = 4
+	StatusRevocationNotification = 5
+)
+
+// PKIFailureInfo bits
+const (
+	FailureBadAlg              = 0
+	FailureBadRequest          = 2
+	FailureBadDataFormat       = 5
+	FailureTimeNotAvailable    = 14
+	FailureUnacceptedPolicy    = 15
+	FailureUnacceptedExtension = 16
+	FailureAddInfoNotAvailable = 17
+	FailureSystemFailure       = 25
+)
+
+// MessageImprint is hash of time-stamped data
+type MessageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type timeStampReq struct {
+	Version        int
+	MessageImprint MessageImprint
+	ReqPolicy      asn1.ObjectIdentifier `asn1:"optional"`
+	Nonce          *big.Int              `asn1:"optional"`
+	CertReq        bool                  `asn1:"optional"`
+	Extensions     []pkix.Extension      `asn1:"tag:0,optional"`
+}
+
+type pkiStatusInfo struct {
+	Status       int
+	StatusString []string       `asn1:"optional"`
+	FailInfo     asn1.BitString `asn1:"optional"`
+}
+
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+// Accuracy of GenTime
+type Accuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"tag:0,optional"`
+	Micros  int `asn1:"tag:1,optional"`
+}
+
+// TSTInfo is content of time-stamp token
+type TSTInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint MessageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time        `asn1:"generalized"`
+	Accuracy       Accuracy         `asn1:"optional"`
+	Ordering       bool             `asn1:"optional"`
+	Nonce          *big.Int         `asn1:"optional"`
+	TSA            asn1.RawValue    `asn1:"tag:0,optional"`
+	Extensions     []pkix.Extension `asn1:"tag:1,optional"`
+}
+
+// Token is a verified time-stamp token
+type Token struct {
+	// DER-encoded ContentInfo with SignedData
+	Raw  []byte
+	Info TSTInfo
+	// TSA certificate
+	Certificate *gostx509.Certificate
+	// Validated chain of TSA certificate (only with cms.WithTrustStore option)
+	Chain []*gostx509.Certificate
+}
+
+// newHash returns hash function by GOST R 34.11-2012 OID
+func newHash(oid asn1.ObjectIdentifier) (hash.Hash, error) {
+	switch {
+	case oid.Equal(cms.OIDGostR341112256):
+		return gost34112012256.New(), nil
+	case oid.Equal(cms.OIDGostR341112512):
+		return gost34112012512.New(), nil
+	default:
+		return nil, errors.Wrapf(ErrUnsupportedHash, "oid: %s", oid)
+	}
+}
+
+// NewMessageImprint hashes data with Streebog identified by hashOID
+func NewMessageImprint(hashOID asn1.ObjectIdentifier, data []byte) (MessageImprint, error) {
+	h, err := newHash(hashOID)
+	if err != nil {
+		return MessageImprint{}, err
+	}
+	h.Write(data)
+	return MessageImprint{
+		HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: hashOID, Parameters: asn1.NullRawValue},
+		HashedMessage: h.Sum(nil),
+	}, nil
+}
+
+// Covers reports whether imprint is hash of data
+func (m MessageImprint) Covers(data []byte) bool {
+	h, err := newHash(m.HashAlgorithm.Algorithm)
+	if err != nil {
+		return false
+	}
+	h.Write(data)
+	return bytes.Equal(h.Sum(nil), m.HashedMessage)
+}
+
+// CreateRequest builds DER-encoded TimeStampReq for imprint.
+// Policy and nonce are optional, TSA certificate is always requested.
+func CreateRequest(imprint MessageImprint, policy asn1.ObjectIdentifier, nonce *big.Int) ([]byte, error) {
+	return asn1.Marshal(timeStampReq{
+		Version:        1,
+		MessageImprint: imprint,
+		ReqPolicy:      policy,
+		Nonce:          nonce,
+		CertReq:        true,
+	})
+}
+
+// ParseResponse parses DER-encoded TimeStampResp and verifies its token.
+// Options are passed to cms.Verify (e.g. cms.WithTrustStore to validate TSA certificate chain).
+func ParseResponse(der []byte, opts ...cms.Option) (*Token, error) {
+	var resp timeStampResp
+	rest, err := asn1.Unmarshal(der, &resp)
+	if err != nil {
+		return nil, errors.Wrap(ErrMalformedResponse, err.Error())
+	}
+	if len(rest) > 0 {
+		return nil, errors.Wrap(ErrMalformedResponse, "trailing data")
+	}
+	if resp.Status.Status != StatusGranted && resp.Status.Status != StatusGrantedWithMods {
+		return nil, errors.Wrapf(ErrRejected, "status %d, failure info %x: %s",
+			resp.Status.Status, resp.Status.FailInfo.Bytes, strings.Join(resp.Status.StatusString, "; "))
+	}
+	if len(resp.TimeStampToken.FullBytes) == 0 {
+		return nil, errors.Wrap(ErrMalformedResponse, "token is missing")
+	}
+	return ParseToken(resp.TimeStampToken.FullBytes, opts...)
+}
+
+// ParseToken parses DER-encoded time-stamp token (ContentInfo with SignedData over TSTInfo)
+// and verifies its GOST signature. TSA certificate must be embedded into token and
+// have id-kp-timeStamping extended key usage.
+func ParseToken(der []byte, opts ...cms.Option) (*Token, error) {
+	result, err := cms.Verify(der, nil, opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to verify time-stamp token")
+	}
+	if !result.ContentType.Equal(OIDContentTypeTSTInfo) {
+		return nil, errors.Wrapf(ErrContentType, "got %s", result.ContentType)
+	}
+
+	token := &Token{Raw: der, Chain: result.Chain}
+	rest, err := asn1.Unmarshal(result.Content, &token.Info)
+	if err != nil {
+		return nil, errors.Wrap(ErrMalformedResponse, "TSTInfo: "+err.Error())
+	}
+	if len(rest) > 0 {
+		return nil, errors.Wrap(ErrMalformedResponse, "trailing data after TSTInfo")
+	}
+
+	token.Certificate, err = gostx509.ParseCertificate(result.Certificate)
+	if err != nil {
+		return nil, err
+	}
+	// RFC 3161 2.3: timeStamping usage must be present explicitly, anyExtendedKeyUsage is not enough
+	if !token.Certificate.ListsExtKeyUsage(gostx509.OIDExtKeyUsageTimeStamping) {
+		return nil, errors.Wrapf(ErrTSACertificate, "certificate %q has no timeStamping usage", token.Certificate.Subject.CommonName)
+	}
+	if err := token.Certificate.CheckValidity(token.Info.GenTime); err != nil {
+		return nil, errors.Wrap(err, "TSA certificate")
+	}
+	return token, nil
+}
+
+// Verify checks that token is a timestamp of data
+func (t *Token) Verify(data []byte) error {
+	if !t.Info.MessageImprint.Covers(data) {
+		return ErrMessageImprintMismatch
+	}
+	return nil
+}
+
+// VerifyTimestamp verifies signatureTimeStampToken of signer verified by cms.Verify (CAdES-T)
+// and returns the token; GenTime of its Info is the time signature existed at.
+func VerifyTimestamp(result *cms.VerifyResult, opts ...cms.Option) (*Token, error) {
+	if len(result.TimestampToken) == 0 {
+		return nil, ErrTimestampMissing
+	}
+	token, err := ParseToken(result.TimestampToken, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := token.Verify(result.Signature); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
@@ -0,0 +1,160 @@
+package tsp
+
+import (
+	"context"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/LdDl/esia-potato/cms"
+	"github.com/LdDl/esia-potato/gostx509"
+	"github.com/LdDl/esia-potato/internal/gosttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testPolicy  = asn1.ObjectIdentifier{1, 2, 643, 100, 1, 2, 3}
+	testGenTime = time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+)
+
+// createTestAuthority creates local TSA with timeStamping certificate and fixed clock
+func createTestAuthority(t *testing.T, extensions ...pkix.Extension) *Authority {
+	if extensions == nil {
+		eku, err := asn1.Marshal([]asn1.ObjectIdentifier{gostx509.OIDExtKeyUsageTimeStamping})
+		require.NoError(t, err)
+		extensions = []pkix.Extension{{Id: gostx509.OIDExtensionExtendedKeyUsage, Critical: true, Value: eku}}
+	}
+	prv := gosttest.PrivateKey(t)
+	signer, err := cms.NewSigner(prv, gosttest.SelfSigned(t, prv, "Test TSA", 100, extensions...),
+		cms.WithContentType(OIDContentTypeTSTInfo), cms.WithProfile(cms.ProfileCAdESBES))
+	require.NoError(t, err)
+
+	authority := NewAuthority(signer, testPolicy)
+	authority.Now = func() time.Time { return testGenTime }
+	return authority
+}
+
+// go test -timeout 30s -run ^TestCreateRequest$ github.com/LdDl/esia-potato/tsp
+func TestCreateRequest(t *testing.T) {
+	imprint, err := NewMessageImprint(cms.OIDGostR341112512, []byte("data"))
+	require.NoError(t, err)
+	assert.Len(t, imprint.HashedMessage, 64)
+	assert.True(t, imprint.Covers([]byte("data")))
+	assert.False(t, imprint.Covers([]byte("other")))
+
+	der, err := CreateRequest(imprint, testPolicy, big.NewInt(42))
+	require.NoError(t, err)
+
+	var req timeStampReq
+	_, err = asn1.Unmarshal(der, &req)
+	require.NoError(t, err)
+	assert.Equal(t, 1, req.Version)
+	assert.Equal(t, imprint.HashedMessage, req.MessageImprint.HashedMessage)
+	assert.True(t, req.ReqPolicy.Equal(testPolicy))
+	assert.Equal(t, int64(42), req.Nonce.Int64())
+	assert.True(t, req.CertReq)
+
+	_, err = NewMessageImprint(asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}, []byte("data"))
+	assert.ErrorIs(t, err, ErrUnsupportedHash)
+}
+
+// go test -timeout 30s -run ^TestClient$ github.com/LdDl/esia-potato/tsp
+func TestClient(t *testing.T) {
+	server := httptest.NewServer(createTestAuthority(t))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	client.HTTPClient = server.Client()
+	client.Policy = testPolicy
+
+	data := []byte("signature value")
+	token, err := client.Request(context.Background(), data)
+	require.NoError(t, err)
+	assert.Equal(t, testGenTime, token.Info.GenTime)
+	assert.True(t, token.Info.Policy.Equal(testPolicy))
+	assert.Equal(t, int64(1), token.Info.SerialNumber.Int64())
+	assert.Equal(t, "Test TSA", token.Certificate.Subject.CommonName)
+	assert.NoError(t, token.Verify(data))
+	assert.ErrorIs(t, token.Verify([]byte("other")), ErrMessageImprintMismatch)
+
+	parsed, err := ParseToken(token.Raw)
+	require.NoError(t, err)
+	assert.Equal(t, token.Info.SerialNumber, parsed.Info.SerialNumber)
+
+	// Unknown policy is rejected by TSA
+	client.Policy = asn1.ObjectIdentifier{1, 2, 3}
+	_, err = client.Request(context.Background(), data)
+	assert.ErrorIs(t, err, ErrRejected)
+}
+
+// go test -timeout 30s -run ^TestTSACertificateUsage$ github.com/LdDl/esia-potato/tsp
+func TestTSACertificateUsage(t *testing.T) {
+	eku, err := asn1.Marshal([]asn1.ObjectIdentifier{gostx509.OIDExtKeyUsageClientAuth})
+	require.NoError(t, err)
+	authority := createTestAuthority(t, pkix.Extension{Id: gostx509.OIDExtensionExtendedKeyUsage, Value: eku})
+
+	imprint, err := NewMessageImprint(cms.OIDGostR341112256, []byte("data"))
+	require.NoError(t, err)
+	reqDER, err := CreateRequest(imprint, nil, nil)
+	require.NoError(t, err)
+	respDER, err := authority.Respond(reqDER)
+	require.NoError(t, err)
+
+	_, err = ParseResponse(respDER)
+	assert.ErrorIs(t, err, ErrTSACertificate)
+
+	// anyExtendedKeyUsage does not make certificate TSA certificate
+	eku, err = asn1.Marshal([]asn1.ObjectIdentifier{gostx509.OIDExtKeyUsageAny})
+	require.NoError(t, err)
+	authority = createTestAuthority(t, pkix.Extension{Id: gostx509.OIDExtensionExtendedKeyUsage, Value: eku})
+	respDER, err = authority.Respond(reqDER)
+	require.NoError(t, err)
+	_, err = ParseResponse(respDER)
+	assert.ErrorIs(t, err, ErrTSACertificate)
+}
+
+// go test -timeout 30s -run ^TestSignCAdEST$ github.com/LdDl/esia-potato/tsp
+func TestSignCAdEST(t *testing.T) {
+	server := httptest.NewServer(createTestAuthority(t))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	client.HTTPClient = server.Client()
+
+	prv := gosttest.PrivateKey(t)
+	signer, err := cms.NewSigner(prv, gosttest.SelfSigned(t, prv, "Test Signer", 1),
+		cms.WithProfile(cms.ProfileCAdESBES), cms.WithTimestamper(client))
+	require.NoError(t, err)
+
+	message := []byte("document")
+	cmsDER, err := signer.Sign(message)
+	require.NoError(t, err)
+
+	result, err := cms.Verify(cmsDER, message)
+	require.NoError(t, err)
+	require.NotEmpty(t, result.TimestampToken)
+
+	token, err := VerifyTimestamp(result)
+	require.NoError(t, err)
+	assert.Equal(t, testGenTime, token.Info.GenTime)
+	assert.NoError(t, token.Verify(result.Signature))
+
+	// Signature without timestamp
+	plain, err := cms.NewSigner(prv, gosttest.SelfSigned(t, prv, "Test Signer", 1))
+	require.NoError(t, err)
+	cmsDER, err = plain.Sign(message)
+	require.NoError(t, err)
+	result, err = cms.Verify(cmsDER, message)
+	require.NoError(t, err)
+	_, err = VerifyTimestamp(result)
+	assert.ErrorIs(t, err, ErrTimestampMissing)
+
+	// Token of another signature
+	result.TimestampToken = token.Raw
+	_, err = VerifyTimestamp(result)
+	assert.ErrorIs(t, err, ErrMessageImprintMismatch)
+}